@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"location-backend/internal/app"
 )
 
@@ -16,7 +17,19 @@ import (
 //	}
 //}
 
+// Usage:
+//
+//	main                    run the server, pending migrations are applied on startup
+//	main migrate up [n]     apply n pending migrations, all by default
+//	main migrate down [n]   revert n applied migrations, one by default
+//	main migrate version    print the database schema version
 func main() {
+	flag.Parse()
+	if flag.Arg(0) == "migrate" {
+		app.Migrate(flag.Args()[1:])
+		return
+	}
+
 	s := app.New()
 	s.Run()
 }
@@ -1,8 +1,9 @@
 module location-backend
 
-go 1.22.0
+go 1.22.2
 
 require (
+	github.com/HugoSmits86/nativewebp v0.9.3
 	github.com/caarlos0/env/v10 v10.0.0
 	github.com/gofiber/contrib/jwt v1.0.8
 	github.com/gofiber/fiber/v2 v2.52.4
@@ -1,12 +1,13 @@
 package app
 
 import (
+	"context"
 	"github.com/rs/zerolog/log"
+	"location-backend/internal/centrifugo"
 	"location-backend/internal/config"
 	"location-backend/internal/db"
 	"location-backend/internal/logger"
 	"location-backend/internal/server"
-	"os"
 	"os/signal"
 	"syscall"
 )
@@ -20,27 +21,30 @@ func New() App {
 	logger.Setup()
 	config.Init()
 	postgres := db.New()
-	app := App{DB: postgres, Fiber: server.New(postgres)}
+	publisher := centrifugo.New(config.Centrifugo.URL, config.Centrifugo.APIKey)
+	app := App{DB: postgres, Fiber: server.New(postgres, publisher)}
 	return app
 }
 
+// Run serves the API and runs the job workers until a termination signal, the workers finish the jobs
+// they are computing before it returns
 func (app App) Run() {
-	var err error
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
+
 	app.Fiber.RegisterFiberRoutes()
-	err = app.Fiber.App.Listen(":" + config.App.Port)
+	app.Fiber.StartJobWorkers(ctx, config.App.JobWorkers)
+	go func() {
+		<-ctx.Done()
+		log.Info().Msg("Shutdown app")
+		if err := app.Fiber.App.Shutdown(); err != nil {
+			log.Error().Err(err).Msg("Failed to shut down fiber server")
+		}
+	}()
+	err := app.Fiber.App.Listen(":" + config.App.Port)
 	if err != nil {
 		log.Error().Err(err).Msg("Cannot start fiber server")
 	}
-}
-
-// TODO
-func GracefulShutdown(app App) {
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-
-	//s := <-sig
-	//log.WithField("signal", s).
-	//	Debug("received signal")
-	//utils.WaitFor(app.Stop, config.App.ShutdownTimeout)
-	log.Printf("Shutdown app")
+	stop()
+	app.Fiber.WaitJobWorkers()
 }
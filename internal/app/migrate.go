@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/config"
+	"location-backend/internal/db"
+	"location-backend/internal/logger"
+	"strconv"
+)
+
+// Migrate runs the migrate command: up [n], down [n] or version
+func Migrate(args []string) {
+	logger.Setup()
+	config.Init()
+
+	if len(args) == 0 {
+		log.Fatal().Msg("Usage: migrate up [n] | down [n] | version")
+	}
+	n := 0
+	if args[0] == "down" {
+		n = 1
+	}
+	if len(args) > 1 {
+		var err error
+		n, err = strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			log.Fatal().Msgf("Invalid number of migrations %q", args[1])
+		}
+	}
+
+	pool := db.Connect()
+	defer pool.Close()
+	migrator, err := db.NewMigrator(pool)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load migrations")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(n)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to apply migrations")
+		}
+		log.Info().Msgf("Applied %d migrations", applied)
+	case "down":
+		reverted, err := migrator.Down(n)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to revert migrations")
+		}
+		log.Info().Msgf("Reverted %d migrations", reverted)
+	case "version":
+		v, err := migrator.Version()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to get schema version")
+		}
+		fmt.Printf("database: %d, application: %d\n", v, migrator.Latest())
+	default:
+		log.Fatal().Msgf("Unknown migrate command %q", args[0])
+	}
+}
@@ -0,0 +1,144 @@
+package centrifugo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Lifetime of connection and subscription tokens
+const TokenTTL = time.Hour
+
+// Number of messages waiting to be published before new ones are dropped
+const queueLength = 1024
+
+// Client publishes messages over the Centrifugo HTTP server API
+type Client struct {
+	URL    string
+	APIKey string
+	HTTP   *http.Client
+	queue  chan message
+}
+
+type message struct {
+	channel string
+	data    any
+}
+
+// New creates a Centrifugo API client, an empty url disables publishing
+func New(url, apiKey string) *Client {
+	c := &Client{
+		URL:    strings.TrimRight(url, "/"),
+		APIKey: apiKey,
+		HTTP:   &http.Client{Timeout: 5 * time.Second},
+		queue:  make(chan message, queueLength),
+	}
+	if c.Enabled() {
+		go c.run()
+	}
+	return c
+}
+
+// run publishes queued messages one by one, so they reach the channel in order
+func (c *Client) run() {
+	for m := range c.queue {
+		if err := c.Publish(m.channel, m.data); err != nil {
+			log.Error().Err(err).Msgf("Failed to publish to %s", m.channel)
+		}
+	}
+}
+
+// PublishAsync queues data to be published to the channel without waiting for Centrifugo.
+// The message is dropped if the client is disabled or the queue is full.
+func (c *Client) PublishAsync(channel string, data any) {
+	if !c.Enabled() {
+		return
+	}
+	select {
+	case c.queue <- message{channel: channel, data: data}:
+	default:
+		log.Error().Msgf("Centrifugo queue is full, message to %s dropped", channel)
+	}
+}
+
+// Enabled reports whether the client has a Centrifugo address to publish to
+func (c *Client) Enabled() bool {
+	return c != nil && c.URL != ""
+}
+
+// FloorChannel returns the channel of the floor namespace
+func FloorChannel(floorID uuid.UUID) string {
+	return "floor:" + floorID.String()
+}
+
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Publish sends data to the channel, it does nothing if the client is disabled
+func (c *Client) Publish(channel string, data any) (err error) {
+	if !c.Enabled() {
+		return
+	}
+	body, err := json.Marshal(map[string]any{
+		"channel": channel,
+		"data":    data,
+	})
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.URL+"/api/publish", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", c.APIKey)
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("centrifugo publish: unexpected status %s", resp.Status)
+	}
+
+	var result struct {
+		Error *apiError `json:"error"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("centrifugo publish: %w", err)
+	}
+	if result.Error != nil {
+		return fmt.Errorf("centrifugo publish: %d %s", result.Error.Code, result.Error.Message)
+	}
+	return
+}
+
+// ConnectionToken issues a connection token of the user signed with the Centrifugo HMAC secret
+func ConnectionToken(secret, userID string, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"sub": userID,
+		"exp": time.Now().Add(ttl).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
+
+// SubscriptionToken issues a token allowing the user to subscribe to the channel
+func SubscriptionToken(secret, userID, channel string, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":     userID,
+		"channel": channel,
+		"exp":     time.Now().Add(ttl).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
@@ -0,0 +1,119 @@
+package centrifugo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type publishRequest struct {
+	Channel string         `json:"channel"`
+	Data    map[string]any `json:"data"`
+}
+
+// standIn starts a server that answers like the Centrifugo HTTP API and forwards received publications
+func standIn(t *testing.T, apiKey string, reply string) (*httptest.Server, chan publishRequest) {
+	received := make(chan publishRequest, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/publish" || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-API-Key") != apiKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req publishRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- req
+		w.Write([]byte(reply))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, received
+}
+
+func TestPublish(t *testing.T) {
+	srv, received := standIn(t, "key", `{"result":{}}`)
+	c := New(srv.URL+"/", "key")
+
+	floorID := uuid.New()
+	if err := c.Publish(FloorChannel(floorID), map[string]any{"type": "wall"}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	req := <-received
+	if req.Channel != "floor:"+floorID.String() {
+		t.Errorf("expected channel floor:%v; got %v", floorID, req.Channel)
+	}
+	if req.Data["type"] != "wall" {
+		t.Errorf("expected data type wall; got %v", req.Data["type"])
+	}
+}
+
+func TestPublishErrors(t *testing.T) {
+	srv, _ := standIn(t, "key", `{"error":{"code":102,"message":"unknown channel"}}`)
+
+	if err := New(srv.URL, "wrong").Publish("floor:1", nil); err == nil {
+		t.Error("expected an error for a wrong api key")
+	}
+	if err := New(srv.URL, "key").Publish("floor:1", nil); err == nil {
+		t.Error("expected an error for an API error reply")
+	}
+	if err := New("", "key").Publish("floor:1", nil); err != nil {
+		t.Errorf("expected a disabled client to skip publishing; got %v", err)
+	}
+}
+
+func TestPublishAsyncKeepsOrder(t *testing.T) {
+	srv, received := standIn(t, "key", `{"result":{}}`)
+	c := New(srv.URL, "key")
+
+	for i := 0; i < 5; i++ {
+		c.PublishAsync("floor:1", map[string]any{"n": i})
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case req := <-received:
+			if n := req.Data["n"].(float64); int(n) != i {
+				t.Fatalf("expected message %d; got %v", i, n)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %d was not published", i)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	secret := "secret"
+	parse := func(s string) jwt.MapClaims {
+		token, err := jwt.Parse(s, func(*jwt.Token) (any, error) { return []byte(secret), nil },
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		if err != nil {
+			t.Fatalf("token is not valid: %v", err)
+		}
+		return token.Claims.(jwt.MapClaims)
+	}
+
+	conn, err := ConnectionToken(secret, "user", TokenTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims := parse(conn); claims["sub"] != "user" {
+		t.Errorf("expected sub user; got %v", claims["sub"])
+	}
+
+	sub, err := SubscriptionToken(secret, "user", "floor:1", TokenTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims := parse(sub); claims["channel"] != "floor:1" || claims["sub"] != "user" {
+		t.Errorf("unexpected subscription claims %v", claims)
+	}
+}
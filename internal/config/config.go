@@ -1,14 +1,17 @@
 package config
 
 import (
+	"time"
+
 	"github.com/caarlos0/env/v10"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog/log"
 )
 
 var (
-	Postgres PostgresConfig
-	App      AppConfig
+	Postgres   PostgresConfig
+	App        AppConfig
+	Centrifugo CentrifugoConfig
 )
 
 type PostgresConfig struct {
@@ -16,10 +19,21 @@ type PostgresConfig struct {
 }
 
 type AppConfig struct {
-	Port         string `env:"PORT,required"`
-	JWTSecret    string `env:"JWT_SECRET,required"`
-	IsProduction bool   `env:"PRODUCTION,required"`
-	ClientURL    string `env:"CLIENT_URL,required"`
+	Port          string `env:"PORT,required"`
+	JWTSecret     string `env:"JWT_SECRET,required"`
+	IsProduction  bool   `env:"PRODUCTION,required"`
+	ClientURL     string `env:"CLIENT_URL,required"`
+	HeatmapRamp   string `env:"HEATMAP_RAMP"`               // dBm-to-color ramp of heatmap images, e.g. "-90:0000ff,-67:00ff00,-45:ff0000"
+	MatrixWorkers int    `env:"MATRIX_WORKERS"`             // Workers generating a matrix, all CPUs if zero
+	JobWorkers    int    `env:"JOB_WORKERS" envDefault:"1"` // Workers of asynchronous jobs, jobs are not run by this instance if zero
+	// Debounce of the recompute of matrices after floor geometry changes, e.g. "10s".
+	// Dirty matrices are served until recomputed, if zero the next request queues their recompute instead.
+	MatrixRecomputeDelay time.Duration `env:"MATRIX_RECOMPUTE_DELAY"`
+}
+
+type CentrifugoConfig struct {
+	URL    string `env:"CENTRIFUGO_URL"`     // e.g. http://localhost:8000, publishing is disabled if empty
+	APIKey string `env:"CENTRIFUGO_API_KEY"` // api_key in centrifugo_config.json
 }
 
 func Init() {
@@ -37,4 +51,9 @@ func Init() {
 	}
 	log.Debug().Msgf("%+v\n", Postgres)
 
+	if err := env.Parse(&Centrifugo); err != nil {
+		log.Error().Err(err)
+	}
+	log.Debug().Msgf("%+v\n", Centrifugo)
+
 }
@@ -11,25 +11,33 @@ import (
 	"strings"
 )
 
+const accessPointColumns = `id, name, x, y, z, hor_rotation_offset, vert_rotation_offset, created_at, updated_at, deleted_at, floor_id, access_point_type_id,
+	correction_factor24, correction_factor5, correction_factor6`
+
 // CreateAccessPoint creates an access point
 func (p *postgres) CreateAccessPoint(ap *AccessPoint) (id uuid.UUID, err error) {
-	query := `INSERT INTO access_points (name, x, y, z, floor_id, access_point_type_id)
-			VALUES ($1, $2, $3, $4, $5, $6)
+	query := `INSERT INTO access_points (name, x, y, z, hor_rotation_offset, vert_rotation_offset, floor_id, access_point_type_id,
+				correction_factor24, correction_factor5, correction_factor6)
+			VALUES ($1, $2, $3, $4, COALESCE($5, 0), COALESCE($6, 0), $7, $8, COALESCE($9, 0), COALESCE($10, 0), COALESCE($11, 0))
 			RETURNING id`
-	row := p.Pool.QueryRow(context.Background(), query, ap.Name, ap.X, ap.Y, ap.Z, ap.FloorID, ap.AccessPointTypeID)
+	row := p.Pool.QueryRow(context.Background(), query, ap.Name, ap.X, ap.Y, ap.Z, ap.HorRotationOffset, ap.VertRotationOffset, ap.FloorID, ap.AccessPointTypeID,
+		ap.CorrectionFactor24, ap.CorrectionFactor5, ap.CorrectionFactor6)
 	err = row.Scan(&id)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create access point")
+		return
 	}
+	p.invalidateAccessPointMatrices(`SELECT id FROM floors WHERE id = $1`, ap.FloorID)
 	return
 }
 
 // GetAccessPoint retrieves an access point
 func (p *postgres) GetAccessPoint(accessPointUUID uuid.UUID) (ap *AccessPoint, err error) {
-	query := `SELECT * FROM access_points WHERE id = $1 AND deleted_at IS NULL`
+	query := `SELECT ` + accessPointColumns + ` FROM access_points WHERE id = $1 AND deleted_at IS NULL`
 	row := p.Pool.QueryRow(context.Background(), query, accessPointUUID)
 	ap = &AccessPoint{}
-	err = row.Scan(&ap.ID, &ap.Name, &ap.X, &ap.Y, &ap.Z, &ap.CreatedAt, &ap.UpdatedAt, &ap.DeletedAt, &ap.FloorID, &ap.AccessPointTypeID)
+	err = row.Scan(&ap.ID, &ap.Name, &ap.X, &ap.Y, &ap.Z, &ap.HorRotationOffset, &ap.VertRotationOffset, &ap.CreatedAt, &ap.UpdatedAt, &ap.DeletedAt, &ap.FloorID, &ap.AccessPointTypeID,
+		&ap.CorrectionFactor24, &ap.CorrectionFactor5, &ap.CorrectionFactor6)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			log.Error().Err(err).Msgf("No access point found with uuid %v", accessPointUUID)
@@ -45,7 +53,7 @@ func (p *postgres) GetAccessPoint(accessPointUUID uuid.UUID) (ap *AccessPoint, e
 // GetAccessPointDetailed retrieves an access point detailed
 func (p *postgres) GetAccessPointDetailed(accessPointUUID uuid.UUID) (ap *AccessPointDetailed, err error) {
 	query := `
-	SELECT ap.id, ap.name, ap.x, ap.y, ap.z, ap.created_at, ap.updated_at, ap.deleted_at, ap.floor_id, ap.access_point_type_id, apt.id, apt.name, apt.color, apt.created_at, apt.updated_at, apt.deleted_at, apt.site_id, r.id, r.number, r.channel, r.wifi, r.power, r.bandwidth, r.guard_interval, r.is_active, r.created_at, r.updated_at, r.deleted_at, r.access_point_id
+	SELECT ap.id, ap.name, ap.x, ap.y, ap.z, ap.hor_rotation_offset, ap.vert_rotation_offset, ap.created_at, ap.updated_at, ap.deleted_at, ap.floor_id, ap.access_point_type_id, ap.correction_factor24, ap.correction_factor5, ap.correction_factor6, apt.id, apt.name, apt.color, apt.diagram, apt.created_at, apt.updated_at, apt.deleted_at, apt.site_id, r.id, r.number, r.channel, r.wifi, r.power, r.bandwidth, r.guard_interval, r.is_active, r.created_at, r.updated_at, r.deleted_at, r.access_point_id
 	FROM access_points ap
 	LEFT JOIN access_point_types apt ON ap.access_point_type_id = apt.id AND ap.deleted_at IS NULL
 	LEFT JOIN radios r ON ap.id = r.access_point_id AND r.deleted_at IS NULL
@@ -64,8 +72,9 @@ func (p *postgres) GetAccessPointDetailed(accessPointUUID uuid.UUID) (ap *Access
 		apt := new(AccessPointType)
 
 		err = rows.Scan(
-			&ap.ID, &ap.Name, &ap.X, &ap.Y, &ap.Z, &ap.CreatedAt, &ap.UpdatedAt, &ap.DeletedAt, &ap.FloorID, &ap.AccessPointTypeID,
-			&apt.ID, &apt.Name, &apt.Color, &apt.CreatedAt, &apt.UpdatedAt, &apt.DeletedAt, &apt.SiteID,
+			&ap.ID, &ap.Name, &ap.X, &ap.Y, &ap.Z, &ap.HorRotationOffset, &ap.VertRotationOffset, &ap.CreatedAt, &ap.UpdatedAt, &ap.DeletedAt, &ap.FloorID, &ap.AccessPointTypeID,
+			&ap.CorrectionFactor24, &ap.CorrectionFactor5, &ap.CorrectionFactor6,
+			&apt.ID, &apt.Name, &apt.Color, &apt.Diagram, &apt.CreatedAt, &apt.UpdatedAt, &apt.DeletedAt, &apt.SiteID,
 			&r.ID, &r.Number, &r.Channel, &r.WiFi, &r.Power, &r.Bandwidth, &r.GuardInterval, &r.IsActive, &r.CreatedAt, &r.UpdatedAt, &r.DeletedAt, &r.AccessPointID,
 		)
 		if err != nil {
@@ -106,7 +115,7 @@ func (p *postgres) IsAccessPointSoftDeleted(accessPointUUID uuid.UUID) (isDelete
 
 // GetAccessPoints retrieves access points
 func (p *postgres) GetAccessPoints(floorUUID uuid.UUID) (aps []*AccessPoint, err error) {
-	query := `SELECT * FROM access_points WHERE floor_id = $1 AND deleted_at IS NULL`
+	query := `SELECT ` + accessPointColumns + ` FROM access_points WHERE floor_id = $1 AND deleted_at IS NULL`
 	rows, err := p.Pool.Query(context.Background(), query, floorUUID)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to retrieve access points")
@@ -117,7 +126,8 @@ func (p *postgres) GetAccessPoints(floorUUID uuid.UUID) (aps []*AccessPoint, err
 	var ap *AccessPoint
 	for rows.Next() {
 		ap = new(AccessPoint)
-		err = rows.Scan(&ap.ID, &ap.Name, &ap.X, &ap.Y, &ap.Z, &ap.CreatedAt, &ap.UpdatedAt, &ap.DeletedAt, &ap.FloorID, &ap.AccessPointTypeID)
+		err = rows.Scan(&ap.ID, &ap.Name, &ap.X, &ap.Y, &ap.Z, &ap.HorRotationOffset, &ap.VertRotationOffset, &ap.CreatedAt, &ap.UpdatedAt, &ap.DeletedAt, &ap.FloorID, &ap.AccessPointTypeID,
+			&ap.CorrectionFactor24, &ap.CorrectionFactor5, &ap.CorrectionFactor6)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to scan access point")
 			return
@@ -136,7 +146,7 @@ func (p *postgres) GetAccessPoints(floorUUID uuid.UUID) (aps []*AccessPoint, err
 
 func (p *postgres) GetAccessPointsDetailed(floorUUID uuid.UUID) (aps []*AccessPointDetailed, err error) {
 	query := `
-SELECT ap.id, ap.name, ap.x, ap.y, ap.z, ap.created_at, ap.updated_at, ap.deleted_at, ap.floor_id, ap.access_point_type_id, apt.id, apt.name, apt.color, apt.created_at, apt.updated_at, apt.deleted_at, apt.site_id, r.id, r.number, r.channel, r.wifi, r.power, r.bandwidth, r.guard_interval, r.is_active, r.created_at, r.updated_at, r.deleted_at, r.access_point_id
+SELECT ap.id, ap.name, ap.x, ap.y, ap.z, ap.hor_rotation_offset, ap.vert_rotation_offset, ap.created_at, ap.updated_at, ap.deleted_at, ap.floor_id, ap.access_point_type_id, ap.correction_factor24, ap.correction_factor5, ap.correction_factor6, apt.id, apt.name, apt.color, apt.diagram, apt.created_at, apt.updated_at, apt.deleted_at, apt.site_id, r.id, r.number, r.channel, r.wifi, r.power, r.bandwidth, r.guard_interval, r.is_active, r.created_at, r.updated_at, r.deleted_at, r.access_point_id
 FROM access_points ap
 LEFT JOIN access_point_types apt ON ap.access_point_type_id = apt.id AND ap.deleted_at IS NULL
 LEFT JOIN radios r ON ap.id = r.access_point_id AND r.deleted_at IS NULL
@@ -157,8 +167,9 @@ WHERE ap.floor_id = $1 AND apt.deleted_at IS NULL
 		apt := new(AccessPointType)
 
 		err = rows.Scan(
-			&ap.ID, &ap.Name, &ap.X, &ap.Y, &ap.Z, &ap.CreatedAt, &ap.UpdatedAt, &ap.DeletedAt, &ap.FloorID, &ap.AccessPointTypeID,
-			&apt.ID, &apt.Name, &apt.Color, &apt.CreatedAt, &apt.UpdatedAt, &apt.DeletedAt, &apt.SiteID,
+			&ap.ID, &ap.Name, &ap.X, &ap.Y, &ap.Z, &ap.HorRotationOffset, &ap.VertRotationOffset, &ap.CreatedAt, &ap.UpdatedAt, &ap.DeletedAt, &ap.FloorID, &ap.AccessPointTypeID,
+			&ap.CorrectionFactor24, &ap.CorrectionFactor5, &ap.CorrectionFactor6,
+			&apt.ID, &apt.Name, &apt.Color, &apt.Diagram, &apt.CreatedAt, &apt.UpdatedAt, &apt.DeletedAt, &apt.SiteID,
 			&r.ID, &r.Number, &r.Channel, &r.WiFi, &r.Power, &r.Bandwidth, &r.GuardInterval, &r.IsActive, &r.CreatedAt, &r.UpdatedAt, &r.DeletedAt, &r.AccessPointID,
 		)
 		if err != nil {
@@ -204,6 +215,7 @@ func (p *postgres) SoftDeleteAccessPoint(accessPointUUID uuid.UUID) (err error)
 		return
 	}
 	log.Debug().Msg("Access point deleted_at timestamp updated successfully")
+	p.invalidateAccessPointMatrices(`SELECT floor_id FROM access_points WHERE id = $1`, accessPointUUID)
 	return
 }
 
@@ -220,6 +232,7 @@ func (p *postgres) RestoreAccessPoint(accessPointUUID uuid.UUID) (err error) {
 		return
 	}
 	log.Debug().Msg("Access point deleted_at timestamp set null successfully")
+	p.invalidateAccessPointMatrices(`SELECT floor_id FROM access_points WHERE id = $1`, accessPointUUID)
 	return
 }
 
@@ -250,6 +263,31 @@ func (p *postgres) PatchUpdateAccessPoint(ap *AccessPoint) (err error) {
 		params = append(params, ap.Z)
 		paramID++
 	}
+	if ap.HorRotationOffset != nil {
+		updates = append(updates, fmt.Sprintf("hor_rotation_offset = $%d", paramID))
+		params = append(params, ap.HorRotationOffset)
+		paramID++
+	}
+	if ap.VertRotationOffset != nil {
+		updates = append(updates, fmt.Sprintf("vert_rotation_offset = $%d", paramID))
+		params = append(params, ap.VertRotationOffset)
+		paramID++
+	}
+	if ap.CorrectionFactor24 != nil {
+		updates = append(updates, fmt.Sprintf("correction_factor24 = $%d", paramID))
+		params = append(params, ap.CorrectionFactor24)
+		paramID++
+	}
+	if ap.CorrectionFactor5 != nil {
+		updates = append(updates, fmt.Sprintf("correction_factor5 = $%d", paramID))
+		params = append(params, ap.CorrectionFactor5)
+		paramID++
+	}
+	if ap.CorrectionFactor6 != nil {
+		updates = append(updates, fmt.Sprintf("correction_factor6 = $%d", paramID))
+		params = append(params, ap.CorrectionFactor6)
+		paramID++
+	}
 
 	if len(updates) == 0 {
 		log.Error().Msg("No fields provided for update")
@@ -265,6 +303,7 @@ func (p *postgres) PatchUpdateAccessPoint(ap *AccessPoint) (err error) {
 		return
 	}
 
+	p.invalidateAccessPointMatrices(`SELECT floor_id FROM access_points WHERE id = $1`, ap.ID)
 	return
 }
 
@@ -4,17 +4,25 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
+	"strings"
 )
 
+const accessPointTypeColumns = `id, name, color, diagram, created_at, updated_at, deleted_at, site_id`
+
 // CreateAccessPointType creates an access point type
 func (p *postgres) CreateAccessPointType(apt *AccessPointType) (id uuid.UUID, err error) {
-	query := `INSERT INTO access_point_types (name, color, site_id)
-			VALUES ($1, $2, $3)
+	query := `INSERT INTO access_point_types (name, color, diagram, site_id)
+			VALUES ($1, $2, $3, $4)
 			RETURNING id`
-	row := p.Pool.QueryRow(context.Background(), query, apt.Name, apt.Color, apt.SiteID)
+	var diagram any
+	if len(apt.Diagram) > 0 {
+		diagram = apt.Diagram
+	}
+	row := p.Pool.QueryRow(context.Background(), query, apt.Name, apt.Color, diagram, apt.SiteID)
 	err = row.Scan(&id)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create access point type")
@@ -24,10 +32,10 @@ func (p *postgres) CreateAccessPointType(apt *AccessPointType) (id uuid.UUID, er
 
 // GetAccessPointType retrieves an access point type
 func (p *postgres) GetAccessPointType(accessPointTypeUUID uuid.UUID) (apt *AccessPointType, err error) {
-	query := `SELECT * FROM access_point_types WHERE id = $1 AND deleted_at IS NULL`
+	query := `SELECT ` + accessPointTypeColumns + ` FROM access_point_types WHERE id = $1 AND deleted_at IS NULL`
 	row := p.Pool.QueryRow(context.Background(), query, accessPointTypeUUID)
 	apt = &AccessPointType{}
-	err = row.Scan(&apt.ID, &apt.Name, &apt.Color, &apt.CreatedAt, &apt.UpdatedAt, &apt.DeletedAt, &apt.SiteID)
+	err = row.Scan(&apt.ID, &apt.Name, &apt.Color, &apt.Diagram, &apt.CreatedAt, &apt.UpdatedAt, &apt.DeletedAt, &apt.SiteID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			log.Error().Err(err).Msgf("No access point type found with uuid %v", accessPointTypeUUID)
@@ -43,7 +51,7 @@ func (p *postgres) GetAccessPointType(accessPointTypeUUID uuid.UUID) (apt *Acces
 // GetAccessPointTypeDetailed retrieves an access point type
 func (p *postgres) GetAccessPointTypeDetailed(accessPointTypeUUID uuid.UUID) (apt *AccessPointTypeDetailed, err error) {
 	query := `
-	SELECT apt.id, apt.name, apt.color, apt.created_at, apt.updated_at, apt.deleted_at, apt.site_id,
+	SELECT apt.id, apt.name, apt.color, apt.diagram, apt.created_at, apt.updated_at, apt.deleted_at, apt.site_id,
 	       rt.id, rt.number, rt.channel, rt.wifi, rt.power, rt.bandwidth, rt.guard_interval, rt.created_at, rt.updated_at, rt.deleted_at, rt.access_point_type_id
 	FROM access_point_types apt
 	LEFT JOIN radio_templates rt ON rt.access_point_type_id = apt.id AND rt.deleted_at IS NULL
@@ -61,7 +69,7 @@ func (p *postgres) GetAccessPointTypeDetailed(accessPointTypeUUID uuid.UUID) (ap
 		rt := new(RadioTemplate)
 
 		err = rows.Scan(
-			&apt.ID, &apt.Name, &apt.Color, &apt.CreatedAt, &apt.UpdatedAt, &apt.DeletedAt, &apt.SiteID,
+			&apt.ID, &apt.Name, &apt.Color, &apt.Diagram, &apt.CreatedAt, &apt.UpdatedAt, &apt.DeletedAt, &apt.SiteID,
 			&rt.ID, &rt.Number, &rt.Channel, &rt.WiFi, &rt.Power, &rt.Bandwidth, &rt.GuardInterval, &rt.CreatedAt, &rt.UpdatedAt, &rt.DeletedAt, &rt.AccessPointTypeID,
 		)
 		if err != nil {
@@ -102,7 +110,7 @@ func (p *postgres) IsAccessPointTypeSoftDeleted(accessPointTypeUUID uuid.UUID) (
 
 // GetAccessPointTypes retrieves access point types
 func (p *postgres) GetAccessPointTypes(siteUUID uuid.UUID) (apts []*AccessPointType, err error) {
-	query := `SELECT * FROM access_point_types WHERE site_id = $1 AND deleted_at IS NULL`
+	query := `SELECT ` + accessPointTypeColumns + ` FROM access_point_types WHERE site_id = $1 AND deleted_at IS NULL`
 	rows, err := p.Pool.Query(context.Background(), query, siteUUID)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to retrieve access point types")
@@ -113,7 +121,7 @@ func (p *postgres) GetAccessPointTypes(siteUUID uuid.UUID) (apts []*AccessPointT
 	var apt *AccessPointType
 	for rows.Next() {
 		apt = new(AccessPointType)
-		err = rows.Scan(&apt.ID, &apt.Name, &apt.Color, &apt.CreatedAt, &apt.UpdatedAt, &apt.DeletedAt, &apt.SiteID)
+		err = rows.Scan(&apt.ID, &apt.Name, &apt.Color, &apt.Diagram, &apt.CreatedAt, &apt.UpdatedAt, &apt.DeletedAt, &apt.SiteID)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to scan access point types")
 			return
@@ -132,7 +140,7 @@ func (p *postgres) GetAccessPointTypes(siteUUID uuid.UUID) (apts []*AccessPointT
 
 func (p *postgres) GetAccessPointTypesDetailed(siteUUID uuid.UUID) (aps []*AccessPointTypeDetailed, err error) {
 	query := `
-SELECT apt.id, apt.name, apt.color, apt.created_at, apt.updated_at, apt.deleted_at, apt.site_id, r.id, r.number, r.channel, r.wifi, r.power, r.bandwidth, r.guard_interval, r.created_at, r.updated_at, r.deleted_at, r.access_point_type_id
+SELECT apt.id, apt.name, apt.color, apt.diagram, apt.created_at, apt.updated_at, apt.deleted_at, apt.site_id, r.id, r.number, r.channel, r.wifi, r.power, r.bandwidth, r.guard_interval, r.created_at, r.updated_at, r.deleted_at, r.access_point_type_id
 FROM access_point_types apt
 LEFT JOIN radio_templates r ON apt.id = r.access_point_type_id AND r.deleted_at IS NULL
 WHERE apt.site_id = $1 AND apt.deleted_at IS NULL
@@ -151,7 +159,7 @@ WHERE apt.site_id = $1 AND apt.deleted_at IS NULL
 		r := new(RadioTemplate)
 
 		err = rows.Scan(
-			&apt.ID, &apt.Name, &apt.Color, &apt.CreatedAt, &apt.UpdatedAt, &apt.DeletedAt, &apt.SiteID,
+			&apt.ID, &apt.Name, &apt.Color, &apt.Diagram, &apt.CreatedAt, &apt.UpdatedAt, &apt.DeletedAt, &apt.SiteID,
 			&r.ID, &r.Number, &r.Channel, &r.WiFi, &r.Power, &r.Bandwidth, &r.GuardInterval, &r.CreatedAt, &r.UpdatedAt, &r.DeletedAt, &r.AccessPointTypeID,
 		)
 		if err != nil {
@@ -196,6 +204,7 @@ func (p *postgres) SoftDeleteAccessPointType(accessPointTypeUUID uuid.UUID) (err
 		return
 	}
 	log.Debug().Msg("Access point type deleted_at timestamp updated successfully")
+	p.invalidateAccessPointMatrices(`SELECT floor_id FROM access_points WHERE access_point_type_id = $1`, accessPointTypeUUID)
 	return
 }
 
@@ -212,5 +221,50 @@ func (p *postgres) RestoreAccessPointType(accessPointTypeUUID uuid.UUID) (err er
 		return
 	}
 	log.Debug().Msg("Access point type deleted_at timestamp set null successfully")
+	p.invalidateAccessPointMatrices(`SELECT floor_id FROM access_points WHERE access_point_type_id = $1`, accessPointTypeUUID)
+	return
+}
+
+// PatchUpdateAccessPointType updates only the specified fields of an access point type
+func (p *postgres) PatchUpdateAccessPointType(apt *AccessPointType) (err error) {
+	query := "UPDATE access_point_types SET updated_at = NOW(), "
+	updates := []string{}
+	params := []interface{}{}
+	paramID := 1
+
+	if apt.Name != "" {
+		updates = append(updates, fmt.Sprintf("name = $%d", paramID))
+		params = append(params, apt.Name)
+		paramID++
+	}
+	if apt.Color != "" {
+		updates = append(updates, fmt.Sprintf("color = $%d", paramID))
+		params = append(params, apt.Color)
+		paramID++
+	}
+	if len(apt.Diagram) > 0 {
+		updates = append(updates, fmt.Sprintf("diagram = $%d", paramID))
+		params = append(params, apt.Diagram)
+		paramID++
+	}
+
+	if len(updates) == 0 {
+		log.Error().Msg("No fields provided for update")
+		return fmt.Errorf("no fields provided for update")
+	}
+
+	query += strings.Join(updates, ", ") + fmt.Sprintf(" WHERE id = $%d AND deleted_at IS NULL", paramID)
+	params = append(params, apt.ID)
+
+	_, err = p.Pool.Exec(context.Background(), query, params...)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to execute update")
+		return
+	}
+
+	// The diagram changes the coverage of every access point of the type
+	if len(apt.Diagram) > 0 {
+		p.invalidateAccessPointMatrices(`SELECT floor_id FROM access_points WHERE access_point_type_id = $1`, apt.ID)
+	}
 	return
 }
@@ -0,0 +1,233 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+const calibrationProfileColumns = `id, name, version, rssi_cutoff, rssi_invisible, correction_coefficient24, correction_coefficient5, correction_coefficient6, calculate_walls, min_accuracy, max_accuracy, result_length, one_sensor_result_length, info_aging_time, created_at, updated_at, deleted_at, site_id`
+
+// scanCalibrationProfile scans a row selected with calibrationProfileColumns
+func scanCalibrationProfile(row pgx.Row, cp *CalibrationProfile) error {
+	return row.Scan(&cp.ID, &cp.Name, &cp.Version, &cp.RSSICutoff, &cp.RSSIInvisible,
+		&cp.CorrectionCoefficient24, &cp.CorrectionCoefficient5, &cp.CorrectionCoefficient6, &cp.CalculateWalls,
+		&cp.MinAccuracy, &cp.MaxAccuracy, &cp.ResultLength, &cp.OneSensorResultLength, &cp.InfoAgingTime,
+		&cp.CreatedAt, &cp.UpdatedAt, &cp.DeletedAt, &cp.SiteID)
+}
+
+// CreateCalibrationProfile creates the calibration profile of a site, missing values are left out of the insert
+// so the column defaults of the table apply
+func (p *postgres) CreateCalibrationProfile(cp *CalibrationProfile) (id uuid.UUID, err error) {
+	columns := []string{"name", "site_id"}
+	placeholders := []string{"$1", "$2"}
+	params := []interface{}{cp.Name, cp.SiteID}
+
+	fields := []struct {
+		column string
+		value  any
+		isSet  bool
+	}{
+		{"rssi_cutoff", cp.RSSICutoff, cp.RSSICutoff != nil},
+		{"rssi_invisible", cp.RSSIInvisible, cp.RSSIInvisible != nil},
+		{"correction_coefficient24", cp.CorrectionCoefficient24, cp.CorrectionCoefficient24 != nil},
+		{"correction_coefficient5", cp.CorrectionCoefficient5, cp.CorrectionCoefficient5 != nil},
+		{"correction_coefficient6", cp.CorrectionCoefficient6, cp.CorrectionCoefficient6 != nil},
+		{"calculate_walls", cp.CalculateWalls, cp.CalculateWalls != nil},
+		{"min_accuracy", cp.MinAccuracy, cp.MinAccuracy != nil},
+		{"max_accuracy", cp.MaxAccuracy, cp.MaxAccuracy != nil},
+		{"result_length", cp.ResultLength, cp.ResultLength != nil},
+		{"one_sensor_result_length", cp.OneSensorResultLength, cp.OneSensorResultLength != nil},
+		{"info_aging_time", cp.InfoAgingTime, cp.InfoAgingTime != nil},
+	}
+	for _, field := range fields {
+		if field.isSet {
+			params = append(params, field.value)
+			columns = append(columns, field.column)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(params)))
+		}
+	}
+
+	query := fmt.Sprintf(`INSERT INTO calibration_profiles (%s) VALUES (%s) RETURNING id`,
+		strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	row := p.Pool.QueryRow(context.Background(), query, params...)
+	err = row.Scan(&id)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create calibration profile")
+	}
+	return
+}
+
+// GetCalibrationProfile retrieves a calibration profile
+func (p *postgres) GetCalibrationProfile(profileUUID uuid.UUID) (cp *CalibrationProfile, err error) {
+	query := `SELECT ` + calibrationProfileColumns + ` FROM calibration_profiles WHERE id = $1 AND deleted_at IS NULL`
+	row := p.Pool.QueryRow(context.Background(), query, profileUUID)
+	cp = &CalibrationProfile{}
+	err = scanCalibrationProfile(row, cp)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msgf("No calibration profile found with uuid %v", profileUUID)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve calibration profile")
+		return
+	}
+	log.Debug().Msgf("Retrieved calibration profile: %v", cp)
+	return
+}
+
+// IsCalibrationProfileSoftDeleted checks if the calibration profile has been soft deleted
+func (p *postgres) IsCalibrationProfileSoftDeleted(profileUUID uuid.UUID) (isDeleted bool, err error) {
+	var deletedAt sql.NullTime // Use sql.NullTime to properly handle NULL values
+	query := `SELECT deleted_at FROM calibration_profiles WHERE id = $1`
+	row := p.Pool.QueryRow(context.Background(), query, profileUUID)
+	err = row.Scan(&deletedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msgf("No calibration profile found with uuid %v", profileUUID)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve calibration profile")
+		return
+	}
+	isDeleted = deletedAt.Valid
+	log.Debug().Msgf("Is calibration profile deleted: %v", isDeleted)
+	return
+}
+
+// GetCalibrationProfiles retrieves the calibration profiles of a site, soft deleted ones included
+func (p *postgres) GetCalibrationProfiles(siteUUID uuid.UUID) (cps []*CalibrationProfile, err error) {
+	query := `SELECT ` + calibrationProfileColumns + ` FROM calibration_profiles WHERE site_id = $1 ORDER BY deleted_at DESC NULLS FIRST, created_at`
+	rows, err := p.Pool.Query(context.Background(), query, siteUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve calibration profiles")
+		return
+	}
+	defer rows.Close()
+
+	var cp *CalibrationProfile
+	for rows.Next() {
+		cp = new(CalibrationProfile)
+		err = scanCalibrationProfile(rows, cp)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan calibration profiles")
+			return
+		}
+		cps = append(cps, cp)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error().Err(err).Msg("Rows iteration error")
+		return
+	}
+
+	log.Debug().Msgf("Retrieved %d calibration profiles", len(cps))
+	return
+}
+
+// GetFloorCalibrationProfile retrieves the live calibration profile of the site of the floor,
+// pgx.ErrNoRows is returned when the site uses the engine defaults
+func (p *postgres) GetFloorCalibrationProfile(floorUUID uuid.UUID) (cp *CalibrationProfile, err error) {
+	query := `SELECT ` + calibrationProfileColumns + ` FROM calibration_profiles
+		WHERE deleted_at IS NULL AND site_id = (SELECT b.site_id FROM floors f JOIN buildings b ON b.id = f.building_id WHERE f.id = $1)`
+	row := p.Pool.QueryRow(context.Background(), query, floorUUID)
+	cp = &CalibrationProfile{}
+	err = scanCalibrationProfile(row, cp)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		log.Error().Err(err).Msg("Failed to retrieve calibration profile of floor")
+	}
+	return
+}
+
+// SoftDeleteCalibrationProfile soft delete a calibration profile, its site returns to the engine defaults
+func (p *postgres) SoftDeleteCalibrationProfile(profileUUID uuid.UUID) (err error) {
+	query := `UPDATE calibration_profiles SET deleted_at = NOW() WHERE id = $1`
+	commandTag, err := p.Pool.Exec(context.Background(), query, profileUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to soft delete calibration profile")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("No calibration profile found with the uuid: %v", profileUUID)
+		return
+	}
+	log.Debug().Msg("Calibration profile deleted_at timestamp updated successfully")
+	return
+}
+
+// RestoreCalibrationProfile restore a calibration profile, it fails if the site has another live profile
+func (p *postgres) RestoreCalibrationProfile(profileUUID uuid.UUID) (err error) {
+	query := `UPDATE calibration_profiles SET deleted_at = NULL WHERE id = $1`
+	commandTag, err := p.Pool.Exec(context.Background(), query, profileUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to restore calibration profile")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("No calibration profile found with the uuid: %v", profileUUID)
+		return
+	}
+	log.Debug().Msg("Calibration profile deleted_at timestamp set null successfully")
+	return
+}
+
+// PatchUpdateCalibrationProfile updates only the specified fields of a calibration profile and increments its version,
+// so matrices built with the previous version are queued again by the next request.
+// pgx.ErrNoRows is returned when there is no live profile with the id
+func (p *postgres) PatchUpdateCalibrationProfile(cp *CalibrationProfile) (err error) {
+	query := "UPDATE calibration_profiles SET updated_at = NOW(), version = version + 1, "
+	updates := []string{}
+	params := []interface{}{}
+	paramID := 1
+
+	fields := []struct {
+		column string
+		value  any
+		isSet  bool
+	}{
+		{"name", cp.Name, cp.Name != ""},
+		{"rssi_cutoff", cp.RSSICutoff, cp.RSSICutoff != nil},
+		{"rssi_invisible", cp.RSSIInvisible, cp.RSSIInvisible != nil},
+		{"correction_coefficient24", cp.CorrectionCoefficient24, cp.CorrectionCoefficient24 != nil},
+		{"correction_coefficient5", cp.CorrectionCoefficient5, cp.CorrectionCoefficient5 != nil},
+		{"correction_coefficient6", cp.CorrectionCoefficient6, cp.CorrectionCoefficient6 != nil},
+		{"calculate_walls", cp.CalculateWalls, cp.CalculateWalls != nil},
+		{"min_accuracy", cp.MinAccuracy, cp.MinAccuracy != nil},
+		{"max_accuracy", cp.MaxAccuracy, cp.MaxAccuracy != nil},
+		{"result_length", cp.ResultLength, cp.ResultLength != nil},
+		{"one_sensor_result_length", cp.OneSensorResultLength, cp.OneSensorResultLength != nil},
+		{"info_aging_time", cp.InfoAgingTime, cp.InfoAgingTime != nil},
+	}
+	for _, field := range fields {
+		if field.isSet {
+			updates = append(updates, fmt.Sprintf("%s = $%d", field.column, paramID))
+			params = append(params, field.value)
+			paramID++
+		}
+	}
+
+	if len(updates) == 0 {
+		log.Error().Msg("No fields provided for update")
+		return fmt.Errorf("no fields provided for update")
+	}
+
+	query += strings.Join(updates, ", ") + fmt.Sprintf(" WHERE id = $%d AND deleted_at IS NULL", paramID)
+	params = append(params, cp.ID)
+
+	commandTag, err := p.Pool.Exec(context.Background(), query, params...)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to execute update")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("No calibration profile found with the uuid: %v", cp.ID)
+		return pgx.ErrNoRows
+	}
+
+	return
+}
@@ -9,8 +9,22 @@ import (
 
 type Service interface {
 	GetUserByUsername(username string) (u User, err error)
+	GetUserByID(userUUID uuid.UUID) (u User, err error)
+
+	CreateRefreshToken(rt *RefreshToken) (id uuid.UUID, err error)
+	GetRefreshToken(tokenHash string) (rt *RefreshToken, err error)
+	RotateRefreshToken(oldUUID uuid.UUID, next *RefreshToken) (id uuid.UUID, err error)
+	RevokeRefreshTokenFamily(familyUUID uuid.UUID) (err error)
+	GetSessions(userUUID uuid.UUID) (sessions []*Session, err error)
+	RevokeSession(userUUID, familyUUID uuid.UUID) (err error)
 	CreateUser(username, password string) (id uuid.UUID, err error)
 
+	GetSiteIDOf(resource string, id uuid.UUID) (siteID uuid.UUID, err error)
+	GetUserSiteRole(userUUID, siteUUID uuid.UUID) (role string, err error)
+	GetSiteMembers(siteUUID uuid.UUID) (members []*SiteMember, err error)
+	GrantSiteRole(userUUID, siteUUID uuid.UUID, role string) (err error)
+	RevokeSiteRole(userUUID, siteUUID uuid.UUID) (err error)
+
 	CreateSite(userUUID uuid.UUID, s *Site) (id uuid.UUID, err error)
 	GetSite(siteUUID uuid.UUID) (s *Site, err error)
 	GetSites(userUUID uuid.UUID) (s []*Site, err error)
@@ -34,6 +48,7 @@ type Service interface {
 	SoftDeleteFloor(floorUUID uuid.UUID) (err error)
 	RestoreFloor(floorUUID uuid.UUID) (err error)
 	PatchUpdateFloor(f *Floor) (err error)
+	GetFloorPropagationModel(floorUUID uuid.UUID) (name string, err error)
 
 	CreateWallType(wt *WallType) (id uuid.UUID, err error)
 	GetWallType(wallTypeUUID uuid.UUID) (wt *WallType, err error)
@@ -52,6 +67,14 @@ type Service interface {
 	RestoreWall(wallUUID uuid.UUID) (err error)
 	PatchUpdateWall(w *Wall) (err error)
 
+	CreateObstacle(o *Obstacle) (id uuid.UUID, err error)
+	GetObstacle(obstacleUUID uuid.UUID) (o *Obstacle, err error)
+	IsObstacleSoftDeleted(obstacleUUID uuid.UUID) (isDeleted bool, err error)
+	GetObstacles(floorUUID uuid.UUID) (obs []*Obstacle, err error)
+	SoftDeleteObstacle(obstacleUUID uuid.UUID) (err error)
+	RestoreObstacle(obstacleUUID uuid.UUID) (err error)
+	PatchUpdateObstacle(o *Obstacle) (err error)
+
 	CreateAccessPointType(apt *AccessPointType) (id uuid.UUID, err error) // TODO: add color for apt
 	GetAccessPointType(accessPointTypeUUID uuid.UUID) (apt *AccessPointType, err error)
 	GetAccessPointTypeDetailed(accessPointTypeUUID uuid.UUID) (apt *AccessPointTypeDetailed, err error)
@@ -60,6 +83,7 @@ type Service interface {
 	GetAccessPointTypesDetailed(siteUUID uuid.UUID) (aps []*AccessPointTypeDetailed, err error)
 	SoftDeleteAccessPointType(accessPointTypeUUID uuid.UUID) (err error)
 	RestoreAccessPointType(accessPointTypeUUID uuid.UUID) (err error)
+	PatchUpdateAccessPointType(apt *AccessPointType) (err error)
 
 	CreateRadioTemplate(r *RadioTemplate) (id uuid.UUID, err error)
 	GetRadioTemplate(radioUUID uuid.UUID) (r RadioTemplate, err error)
@@ -76,6 +100,7 @@ type Service interface {
 	SoftDeleteRadio(radioUUID uuid.UUID) (err error)
 	RestoreRadio(radioUUID uuid.UUID) (err error)
 	PatchUpdateRadio(r *Radio) (err error)
+	ApplyRadioSettings(radios []*Radio, floorIDs []uuid.UUID, check func(current, updated *Radio) error) (accessPointIDs []uuid.UUID, err error)
 
 	CreateAccessPoint(ap *AccessPoint) (id uuid.UUID, err error)
 	GetAccessPoint(accessPointUUID uuid.UUID) (ap *AccessPoint, err error)
@@ -87,6 +112,54 @@ type Service interface {
 	RestoreAccessPoint(accessPointUUID uuid.UUID) (err error)
 	PatchUpdateAccessPoint(ap *AccessPoint) (err error)
 
+	SaveMatrix(m *Matrix, batches <-chan MatrixBatch) (id uuid.UUID, err error)
+	GetMatrix(floorUUID uuid.UUID, kind string, cellSize float64) (m *Matrix, err error)
+	GetMatrixRows(matrixUUID uuid.UUID) (rows []*MatrixRow, err error)
+	DeleteMatrices(floorUUID uuid.UUID) (err error)
+
+	CreateSensorType(st *SensorType) (id uuid.UUID, err error)
+	GetSensorType(sensorTypeUUID uuid.UUID) (st *SensorType, err error)
+	IsSensorTypeSoftDeleted(sensorTypeUUID uuid.UUID) (isDeleted bool, err error)
+	GetSensorTypes(siteUUID uuid.UUID) (sts []*SensorType, err error)
+	SoftDeleteSensorType(sensorTypeUUID uuid.UUID) (err error)
+	RestoreSensorType(sensorTypeUUID uuid.UUID) (err error)
+	PatchUpdateSensorType(st *SensorType) (err error)
+
+	CreateCalibrationProfile(cp *CalibrationProfile) (id uuid.UUID, err error)
+	GetCalibrationProfile(profileUUID uuid.UUID) (cp *CalibrationProfile, err error)
+	IsCalibrationProfileSoftDeleted(profileUUID uuid.UUID) (isDeleted bool, err error)
+	GetCalibrationProfiles(siteUUID uuid.UUID) (cps []*CalibrationProfile, err error)
+	GetFloorCalibrationProfile(floorUUID uuid.UUID) (cp *CalibrationProfile, err error)
+	SoftDeleteCalibrationProfile(profileUUID uuid.UUID) (err error)
+	RestoreCalibrationProfile(profileUUID uuid.UUID) (err error)
+	PatchUpdateCalibrationProfile(cp *CalibrationProfile) (err error)
+
+	CreateSurveyMeasurements(ms []*SurveyMeasurement) (count int64, err error)
+	IsSurveyMeasurementSoftDeleted(measurementUUID uuid.UUID) (isDeleted bool, err error)
+	GetSurveyMeasurements(floorUUID uuid.UUID) (ms []*SurveyMeasurement, err error)
+	SoftDeleteSurveyMeasurement(measurementUUID uuid.UUID) (err error)
+	RestoreSurveyMeasurement(measurementUUID uuid.UUID) (err error)
+	ApplySurveyCalibration(f *Floor, sensors []*SensorPatch, accessPoints []*AccessPoint) (err error)
+
+	CreateJob(j *Job) (id uuid.UUID, err error)
+	GetJob(jobUUID uuid.UUID) (j *Job, err error)
+	GetJobs(floorUUID uuid.UUID) (js []*Job, err error)
+	ClaimJob(worker string) (j *Job, err error)
+	RequeueStaleJobs(timeout time.Duration) (count int64, err error)
+	UpdateJobProgress(jobUUID uuid.UUID, progress int) (cancelRequested bool, err error)
+	FinishJob(jobUUID uuid.UUID, status string, result []byte, errMsg *string) (err error)
+	CancelJob(jobUUID uuid.UUID) (status string, err error)
+	EnqueueDebouncedJob(j *Job, delay time.Duration) (id uuid.UUID, err error)
+
+	CreateSensor(s *Sensor) (id uuid.UUID, err error)
+	GetSensor(sensorUUID uuid.UUID) (s *Sensor, err error)
+	IsSensorSoftDeleted(sensorUUID uuid.UUID) (isDeleted bool, err error)
+	GetSensorByMac(mac string) (s *Sensor, err error)
+	GetSensors(floorUUID uuid.UUID) (ss []*Sensor, err error)
+	SoftDeleteSensor(sensorUUID uuid.UUID) (err error)
+	RestoreSensor(sensorUUID uuid.UUID) (err error)
+	PatchUpdateSensor(s *SensorPatch) (err error)
+
 	//SetRadioState(rs *RadioState) (id uuid.UUID, err error)
 	//GetRadioStates(accessPointID uuid.UUID) (radioStates []RadioState, err error)
 
@@ -117,13 +190,79 @@ type UserRole struct {
 	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
 	UpdatedAt time.Time  `json:"updatedAt" db:"updated_at"`
 	DeletedAt *time.Time `json:"deletedAt" db:"deleted_at"`
+	SiteID    *uuid.UUID `json:"siteId" db:"site_id"`
+}
+
+// Site roles, each role includes the permissions of the roles below it
+const (
+	RoleOwner  = "owner"  // manages the site and its roles
+	RoleEditor = "editor" // edits the site content
+	RoleViewer = "viewer" // reads the site content
+)
+
+// RoleLevel returns the rank of the site role, 0 for unknown roles
+func RoleLevel(role string) int {
+	switch role {
+	case RoleOwner:
+		return 3
+	case RoleEditor:
+		return 2
+	case RoleViewer:
+		return 1
+	}
+	return 0
 }
 
+// SiteMember is a user with a role in a site
+type SiteMember struct {
+	UserID    uuid.UUID `json:"userId" db:"user_id"`
+	Username  string    `json:"username" db:"username"`
+	Role      string    `json:"role" db:"role"`
+	SiteID    uuid.UUID `json:"siteId" db:"site_id"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// Kinds of entities whose site can be resolved by GetSiteIDOf
+const (
+	ResourceSite               = "site"
+	ResourceBuilding           = "building"
+	ResourceFloor              = "floor"
+	ResourceWall               = "wall"
+	ResourceWallType           = "wallType"
+	ResourceObstacle           = "obstacle"
+	ResourceAccessPoint        = "accessPoint"
+	ResourceAccessPointType    = "accessPointType"
+	ResourceRadio              = "radio"
+	ResourceRadioTemplate      = "radioTemplate"
+	ResourceSensor             = "sensor"
+	ResourceSensorType         = "sensorType"
+	ResourceCalibrationProfile = "calibrationProfile"
+	ResourceSurveyMeasurement  = "surveyMeasurement"
+	ResourceJob                = "job"
+)
+
 type RefreshToken struct {
-	ID     uuid.UUID  `json:"id" db:"id"`
-	Token  string     `json:"token" db:"token"`
-	Expiry *time.Time `json:"expiry" db:"expiry"`
-	UserID uuid.UUID  `json:"userId" db:"user_id"`
+	ID         uuid.UUID  `json:"id" db:"id"`
+	Token      string     `json:"token" db:"token"` // SHA-256 hash of the token
+	Expiry     *time.Time `json:"expiry" db:"expiry"`
+	UserID     uuid.UUID  `json:"userId" db:"user_id"`
+	FamilyID   uuid.UUID  `json:"familyId" db:"family_id"` // Tokens rotated from the same login
+	Device     string     `json:"device" db:"device"`
+	IP         string     `json:"ip" db:"ip"`
+	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
+	RevokedAt  *time.Time `json:"revokedAt" db:"revoked_at"`
+	ReplacedBy *uuid.UUID `json:"replacedBy" db:"replaced_by"`
+}
+
+// Session is a login of a user on a device, it lasts while its refresh token family is active
+type Session struct {
+	ID         uuid.UUID `json:"id" db:"family_id"`
+	Device     string    `json:"device" db:"device"`
+	IP         string    `json:"ip" db:"ip"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+	LastUsedAt time.Time `json:"lastUsedAt" db:"last_used_at"`
+	Expiry     time.Time `json:"expiry" db:"expiry"`
+	Current    bool      `json:"current"`
 }
 
 type Site struct {
@@ -134,9 +273,11 @@ type Site struct {
 	UpdatedAt        time.Time          `json:"updatedAt" db:"updated_at"`
 	DeletedAt        *time.Time         `json:"deletedAt" db:"deleted_at"`
 	UserID           uuid.UUID          `json:"userId" db:"user_id"`
+	PropagationModel *string            `json:"propagationModel" db:"propagation_model"`
 	Buildings        []*Building        `json:"buildings"`
 	AccessPointTypes []*AccessPointType `json:"accessPointTypes"`
 	WallTypes        []*WallType        `json:"wallTypes"`
+	SensorTypes      []*SensorType      `json:"sensorTypes"`
 }
 
 type Building struct {
@@ -154,41 +295,60 @@ type Building struct {
 }
 
 type Floor struct {
-	ID           uuid.UUID              `json:"id" db:"id"`
-	Name         *string                `json:"name" db:"name"`
-	Number       *int                   `json:"number" db:"number"`
-	Image        *string                `json:"image" db:"image"`
-	Scale        *float64               `json:"scale" db:"scale"`
-	CreatedAt    time.Time              `json:"createdAt" db:"created_at"`
-	UpdatedAt    time.Time              `json:"updatedAt" db:"updated_at"`
-	DeletedAt    *time.Time             `json:"deletedAt" db:"deleted_at"`
-	BuildingID   uuid.UUID              `json:"buildingId" db:"building_id"`
-	AccessPoints []*AccessPointDetailed `json:"accessPoints"`
-	Walls        []*WallDetailed        `json:"walls"`
-	//Sensors      []*Sensor      `json:"sensors"`
-}
-
-type AccessPoint struct {
 	ID                uuid.UUID  `json:"id" db:"id"`
-	Name              string     `json:"name" db:"name"`
-	X                 *int       `json:"x" db:"x"`
-	Y                 *int       `json:"y" db:"y"`
-	Z                 *float64   `json:"z" db:"z"`
+	Name              *string    `json:"name" db:"name"`
+	Number            *int       `json:"number" db:"number"`
+	Image             *string    `json:"image" db:"image"`
+	Scale             *float64   `json:"scale" db:"scale"`
 	CreatedAt         time.Time  `json:"createdAt" db:"created_at"`
 	UpdatedAt         time.Time  `json:"updatedAt" db:"updated_at"`
 	DeletedAt         *time.Time `json:"deletedAt" db:"deleted_at"`
-	FloorID           uuid.UUID  `json:"floorId" db:"floor_id"`
-	AccessPointTypeID uuid.UUID  `json:"accessPointTypeId" db:"access_point_type_id"`
+	BuildingID        uuid.UUID  `json:"buildingId" db:"building_id"`
+	PropagationModel  *string    `json:"propagationModel" db:"propagation_model"`
+	GeometryRevision  int64      `json:"geometryRevision" db:"geometry_revision"`   // Incremented on every change of the floor geometry
+	Elevation         *float64   `json:"elevation" db:"elevation"`                  // Meters above the ground, number times height when nil
+	Height            *float64   `json:"height" db:"height"`                        // Meters from the floor to the floor above
+	SlabAttenuation24 *float64   `json:"slabAttenuation24" db:"slab_attenuation24"` // dB of the slab under the floor
+	SlabAttenuation5  *float64   `json:"slabAttenuation5" db:"slab_attenuation5"`
+	SlabAttenuation6  *float64   `json:"slabAttenuation6" db:"slab_attenuation6"`
+	// Path-loss exponents fitted to the survey of the floor, they override the exponents of the propagation model
+	PathLossExponent24 *float64               `json:"pathLossExponent24" db:"path_loss_exponent24"`
+	PathLossExponent5  *float64               `json:"pathLossExponent5" db:"path_loss_exponent5"`
+	PathLossExponent6  *float64               `json:"pathLossExponent6" db:"path_loss_exponent6"`
+	AccessPoints       []*AccessPointDetailed `json:"accessPoints"`
+	Walls              []*WallDetailed        `json:"walls"`
+	Obstacles          []*Obstacle            `json:"obstacles"`
+	Sensors            []*Sensor              `json:"sensors"`
+	AdjacentFloors     []*Floor               `json:"-"` // Floors above and below with their access points, leaking into the floor coverage
+}
+
+type AccessPoint struct {
+	ID                 uuid.UUID  `json:"id" db:"id"`
+	Name               string     `json:"name" db:"name"`
+	X                  *int       `json:"x" db:"x"`
+	Y                  *int       `json:"y" db:"y"`
+	Z                  *float64   `json:"z" db:"z"`
+	HorRotationOffset  *int       `json:"horRotationOffset" db:"hor_rotation_offset"`   // Azimuth of the antenna boresight in degrees clockwise from the top of the plan
+	VertRotationOffset *int       `json:"vertRotationOffset" db:"vert_rotation_offset"` // Downtilt of the antenna in degrees
+	CreatedAt          time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt          time.Time  `json:"updatedAt" db:"updated_at"`
+	DeletedAt          *time.Time `json:"deletedAt" db:"deleted_at"`
+	FloorID            uuid.UUID  `json:"floorId" db:"floor_id"`
+	AccessPointTypeID  uuid.UUID  `json:"accessPointTypeId" db:"access_point_type_id"`
+	CorrectionFactor24 *float64   `json:"correctionFactor24" db:"correction_factor24"` // dB added to the predicted signal, fitted to the survey
+	CorrectionFactor5  *float64   `json:"correctionFactor5" db:"correction_factor5"`
+	CorrectionFactor6  *float64   `json:"correctionFactor6" db:"correction_factor6"`
 }
 
 type AccessPointType struct {
-	ID        uuid.UUID  `json:"id" db:"id"`
-	Name      string     `json:"name" db:"name"`
-	Color     string     `json:"color" db:"color"`
-	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
-	UpdatedAt time.Time  `json:"updatedAt" db:"updated_at"`
-	DeletedAt *time.Time `json:"deletedAt" db:"deleted_at"`
-	SiteID    uuid.UUID  `json:"siteId" db:"site_id"`
+	ID        uuid.UUID       `json:"id" db:"id"`
+	Name      string          `json:"name" db:"name"`
+	Color     string          `json:"color" db:"color"`
+	Diagram   json.RawMessage `json:"diagram" db:"diagram"` // Radiation diagram of the antennas, see Diagram
+	CreatedAt time.Time       `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time       `json:"updatedAt" db:"updated_at"`
+	DeletedAt *time.Time      `json:"deletedAt" db:"deleted_at"`
+	SiteID    uuid.UUID       `json:"siteId" db:"site_id"`
 }
 
 type RadioTemplate struct {
@@ -243,6 +403,9 @@ type Wall struct {
 	Y1         *int       `json:"y1" db:"y1"`
 	X2         *int       `json:"x2" db:"x2"`
 	Y2         *int       `json:"y2" db:"y2"`
+	ZBottom    *float64   `json:"zBottom" db:"z_bottom"` // Meters above the floor
+	ZTop       *float64   `json:"zTop" db:"z_top"`       // Meters above the floor, the ceiling when nil
+	ResetZTop  bool       `json:"-" db:"-"`              // Patch only: the wall reaches the ceiling again
 	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
 	UpdatedAt  time.Time  `json:"updatedAt" db:"updated_at"`
 	DeletedAt  *time.Time `json:"deletedAt" db:"deleted_at"`
@@ -269,6 +432,84 @@ type WallDetailed struct {
 	WallType *WallType `json:"wallType"`
 }
 
+// ObstaclePoint is a vertex of an obstacle polygon in image pixels
+type ObstaclePoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Obstacle is a polygon of a floor attenuating the signal per meter of the ray inside it
+type Obstacle struct {
+	ID            uuid.UUID       `json:"id" db:"id"`
+	Name          *string         `json:"name" db:"name"`
+	Color         *string         `json:"color" db:"color"`
+	Points        []ObstaclePoint `json:"points" db:"points"`
+	Attenuation24 *float64        `json:"attenuation24" db:"attenuation24"` // dB per meter
+	Attenuation5  *float64        `json:"attenuation5" db:"attenuation5"`
+	Attenuation6  *float64        `json:"attenuation6" db:"attenuation6"`
+	ZBottom       *float64        `json:"zBottom" db:"z_bottom"` // Meters above the floor
+	ZTop          *float64        `json:"zTop" db:"z_top"`       // Meters above the floor, the ceiling when nil
+	ResetZTop     bool            `json:"-" db:"-"`              // Patch only: the obstacle reaches the ceiling again
+	CreatedAt     time.Time       `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time       `json:"updatedAt" db:"updated_at"`
+	DeletedAt     *time.Time      `json:"deletedAt" db:"deleted_at"`
+	FloorID       uuid.UUID       `json:"floorId" db:"floor_id"`
+}
+
+// Kinds of floor matrices
+const (
+	MatrixKindCoverage = "coverage" // access points as emitters, used by heatmaps
+	MatrixKindSensors  = "sensors"  // sensors as receivers, used by positioning
+)
+
+// Matrix is a header of a generated floor matrix
+type Matrix struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Kind      string    `json:"kind" db:"kind"`
+	CellSize  float64   `json:"cellSize" db:"cell_size"`
+	MinX      int       `json:"minX" db:"min_x"`
+	MinY      int       `json:"minY" db:"min_y"`
+	MaxX      int       `json:"maxX" db:"max_x"`
+	MaxY      int       `json:"maxY" db:"max_y"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	FloorID   uuid.UUID `json:"floorId" db:"floor_id"`
+	// Calibration profile the matrix was built with, nil for the default calibration
+	CalibrationProfileID *uuid.UUID `json:"calibrationProfileId" db:"calibration_profile_id"`
+	CalibrationVersion   int        `json:"calibrationVersion" db:"calibration_version"`
+	// Geometry revision of the floor the matrix was generated from, a dirty matrix is older than the floor geometry
+	GeometryRevision int64 `json:"geometryRevision" db:"geometry_revision"`
+	Dirty            bool  `json:"dirty" db:"dirty"`
+}
+
+// Point is a cell of a floor matrix, coordinates are in meters
+type Point struct {
+	ID       int       `json:"id" db:"id"`
+	X        float64   `json:"x" db:"x"`
+	Y        float64   `json:"y" db:"y"`
+	MatrixID uuid.UUID `json:"matrixId" db:"matrix_id"`
+}
+
+// MatrixRow is the signal of one emitter in a point of a floor matrix
+type MatrixRow struct {
+	PointID  int       `json:"pointId" db:"point_id"`
+	SensorID uuid.UUID `json:"sensorId" db:"sensor_id"`
+	RSSI24   float64   `json:"rssi24" db:"rssi24"`
+	RSSI5    float64   `json:"rssi5" db:"rssi5"`
+	RSSI6    float64   `json:"rssi6" db:"rssi6"`
+	Distance float64   `json:"distance" db:"distance"`
+	MatrixID uuid.UUID `json:"matrixId" db:"matrix_id"`
+	X        float64   `json:"x"` // Point coordinates, filled on read
+	Y        float64   `json:"y"`
+}
+
+// MatrixBatch is a chunk of matrix points and rows written at once.
+// A batch with Err aborts the matrix, nothing is saved.
+type MatrixBatch struct {
+	Points []*Point
+	Rows   []*MatrixRow
+	Err    error
+}
+
 // ? TODO Возможно стоит из названий убрать приписку sensor_
 type Sensor struct {
 	ID         uuid.UUID `json:"id" db:"id"`                  // "id" INTEGER [pk, increment]
@@ -291,10 +532,9 @@ type Sensor struct {
 	// TODO  "primary_channel_width" VARCHAR(45)
 	// TODO  "primary_interval" FLOAT
 	// TODO  "secondary_interval" FLOAT
-	MapId              uuid.UUID       `json:"mapId" db:"map_id"`                            //  "map_id" INTEGER
-	X                  float64         `json:"x" db:"x"`                                     //   "x" FLOAT
-	Y                  float64         `json:"y" db:"y"`                                     //   "y" FLOAT
-	Z                  float64         `json:"z" db:"z"`                                     //   "z" FLOAT
+	X                  float64         `json:"x" db:"x"`                                     //   "x" FLOAT, image pixels
+	Y                  float64         `json:"y" db:"y"`                                     //   "y" FLOAT, image pixels
+	Z                  float64         `json:"z" db:"z"`                                     //   "z" FLOAT, meters
 	RxAntGain          float64         `json:"rxAntGain" db:"rx_ant_gain"`                   //   "rx_ant_gain" FLOAT [not null, default: 0]
 	HorRotationOffset  int             `json:"horRotationOffset" db:"hor_rotation_offset"`   //   "hor_rotation_offset" INTEGER [not null, default: 0]
 	VertRotationOffset int             `json:"vertRotationOffset" db:"vert_rotation_offset"` //   "vert_rotation_offset" INTEGER [not null, default: 0]
@@ -302,8 +542,56 @@ type Sensor struct {
 	CorrectionFactor5  float64         `json:"correctionFactor5" db:"correction_factor5"`    //   "correction_factor5" INTEGER [not null, default: 0] -> FLOAT
 	CorrectionFactor6  float64         `json:"correctionFactor6" db:"correction_factor6"`    //   "correction_factor6" INTEGER [not null, default: 0float64 -> FLOAT
 	Diagram            json.RawMessage `json:"diagram" db:"diagram"`                         // Тип JSON
+	CreatedAt          time.Time       `json:"createdAt" db:"created_at"`
+	UpdatedAt          time.Time       `json:"updatedAt" db:"updated_at"`
+	DeletedAt          *time.Time      `json:"deletedAt" db:"deleted_at"`
+	FloorID            uuid.UUID       `json:"floorId" db:"floor_id"`
+	SensorTypeID       *uuid.UUID      `json:"sensorTypeId" db:"sensor_type_id"`
 }
 
+// SensorPatch holds the fields of a sensor to update, nil fields are left unchanged
+type SensorPatch struct {
+	ID                 uuid.UUID       `json:"id"`
+	Mac                *string         `json:"mac"`
+	Ip                 *string         `json:"ip"`
+	Name               *string         `json:"name"`
+	Allias             *string         `json:"allias"`
+	Interface0         *string         `json:"interface0"`
+	Interface1         *string         `json:"interface1"`
+	Interface2         *string         `json:"interface2"`
+	X                  *float64        `json:"x"`
+	Y                  *float64        `json:"y"`
+	Z                  *float64        `json:"z"`
+	RxAntGain          *float64        `json:"rxAntGain"`
+	HorRotationOffset  *int            `json:"horRotationOffset"`
+	VertRotationOffset *int            `json:"vertRotationOffset"`
+	CorrectionFactor24 *float64        `json:"correctionFactor24"`
+	CorrectionFactor5  *float64        `json:"correctionFactor5"`
+	CorrectionFactor6  *float64        `json:"correctionFactor6"`
+	Diagram            json.RawMessage `json:"diagram"`
+	SensorTypeID       *uuid.UUID      `json:"sensorTypeId"`
+}
+
+// SensorType holds the antenna defaults inherited by new sensors of the type
+type SensorType struct {
+	ID                 uuid.UUID       `json:"id" db:"id"`
+	Name               string          `json:"name" db:"name"`
+	Color              string          `json:"color" db:"color"`
+	RxAntGain          *float64        `json:"rxAntGain" db:"rx_ant_gain"`
+	CorrectionFactor24 *float64        `json:"correctionFactor24" db:"correction_factor24"`
+	CorrectionFactor5  *float64        `json:"correctionFactor5" db:"correction_factor5"`
+	CorrectionFactor6  *float64        `json:"correctionFactor6" db:"correction_factor6"`
+	Diagram            json.RawMessage `json:"diagram" db:"diagram"` // Diagram
+	CreatedAt          time.Time       `json:"createdAt" db:"created_at"`
+	UpdatedAt          time.Time       `json:"updatedAt" db:"updated_at"`
+	DeletedAt          *time.Time      `json:"deletedAt" db:"deleted_at"`
+	SiteID             uuid.UUID       `json:"siteId" db:"site_id"`
+}
+
+// Diagram is a radiation diagram of an antenna. Degree maps angles sampled with a constant step from "0"
+// to the gains in dBi of the horizontal and vertical cuts at the angle. Horizontal angles go clockwise
+// from the boresight seen from above, vertical angles go down from the boresight: 90 is straight down,
+// 180 is behind and 270 straight up.
 type Diagram struct {
 	Degree map[string]Degree `json:"degree"`
 }
@@ -317,3 +605,79 @@ type Degree struct {
 // 	SensorID uuid.UUID       `json:"sensorId" db:"sensor_id"`
 
 // }
+
+// CalibrationProfile holds the tuning of the location engine for a site, nil fields take the defaults
+// of the engine. Version grows on every update.
+type CalibrationProfile struct {
+	ID                      uuid.UUID  `json:"id" db:"id"`
+	Name                    string     `json:"name" db:"name"`
+	Version                 int        `json:"version" db:"version"`
+	RSSICutoff              *float64   `json:"rssiCutoff" db:"rssi_cutoff"`
+	RSSIInvisible           *float64   `json:"rssiInvisible" db:"rssi_invisible"`
+	CorrectionCoefficient24 *float64   `json:"correctionCoefficient24" db:"correction_coefficient24"`
+	CorrectionCoefficient5  *float64   `json:"correctionCoefficient5" db:"correction_coefficient5"`
+	CorrectionCoefficient6  *float64   `json:"correctionCoefficient6" db:"correction_coefficient6"`
+	CalculateWalls          *bool      `json:"calculateWalls" db:"calculate_walls"`
+	MinAccuracy             *float64   `json:"minAccuracy" db:"min_accuracy"`
+	MaxAccuracy             *float64   `json:"maxAccuracy" db:"max_accuracy"`
+	ResultLength            *int       `json:"resultLength" db:"result_length"`
+	OneSensorResultLength   *float64   `json:"oneSensorResultLength" db:"one_sensor_result_length"`
+	InfoAgingTime           *int       `json:"infoAgingTime" db:"info_aging_time"` // Seconds
+	CreatedAt               time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt               time.Time  `json:"updatedAt" db:"updated_at"`
+	DeletedAt               *time.Time `json:"deletedAt" db:"deleted_at"`
+	SiteID                  uuid.UUID  `json:"siteId" db:"site_id"`
+}
+
+// SurveyMeasurement is the RSSI of a sensor or an access point measured at a point of the floor plan.
+// Coordinates are image pixels, Band is 24, 5 or 6.
+type SurveyMeasurement struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	X             float64    `json:"x" db:"x"`
+	Y             float64    `json:"y" db:"y"`
+	Band          int        `json:"band" db:"band"`
+	RSSI          float64    `json:"rssi" db:"rssi"`
+	MeasuredAt    time.Time  `json:"measuredAt" db:"measured_at"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+	DeletedAt     *time.Time `json:"deletedAt" db:"deleted_at"`
+	SensorID      *uuid.UUID `json:"sensorId" db:"sensor_id"`
+	AccessPointID *uuid.UUID `json:"accessPointId" db:"access_point_id"`
+	FloorID       uuid.UUID  `json:"floorId" db:"floor_id"`
+}
+
+// Kinds of jobs
+const (
+	JobKindMatrix      = "matrix"      // generates a floor matrix
+	JobKindHeatmap     = "heatmap"     // computes the heatmap of a floor
+	JobKindCalibration = "calibration" // fits the floor predictions to its survey
+)
+
+// States of jobs, done, failed and cancelled are final
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job is an asynchronous computation of a floor. Params and Result are JSON documents of the job kind.
+type Job struct {
+	ID              uuid.UUID       `json:"id" db:"id"`
+	Kind            string          `json:"kind" db:"kind"`
+	Status          string          `json:"status" db:"status"`
+	Params          json.RawMessage `json:"params" db:"params"`
+	Progress        int             `json:"progress" db:"progress"` // Percents
+	Result          json.RawMessage `json:"result" db:"result"`
+	Error           *string         `json:"error" db:"error"`
+	CancelRequested bool            `json:"cancelRequested" db:"cancel_requested"`
+	Worker          *string         `json:"worker" db:"worker"`
+	CreatedAt       time.Time       `json:"createdAt" db:"created_at"`
+	UpdatedAt       time.Time       `json:"updatedAt" db:"updated_at"`
+	StartedAt       *time.Time      `json:"startedAt" db:"started_at"`
+	FinishedAt      *time.Time      `json:"finishedAt" db:"finished_at"`
+	FloorID         uuid.UUID       `json:"floorId" db:"floor_id"`
+	UserID          *uuid.UUID      `json:"userId" db:"user_id"`
+	RunAfter        time.Time       `json:"runAfter" db:"run_after"`
+	DedupKey        *string         `json:"dedupKey" db:"dedup_key"` // A queued job with a key is unique, see EnqueueDebouncedJob
+}
@@ -11,25 +11,34 @@ import (
 	"strings"
 )
 
+const floorColumns = `id, name, number, image, scale, created_at, updated_at, deleted_at, building_id, propagation_model, geometry_revision,
+	elevation, height, slab_attenuation24, slab_attenuation5, slab_attenuation6,
+	path_loss_exponent24, path_loss_exponent5, path_loss_exponent6`
+
 // CreateFloor creates a floor
 func (p *postgres) CreateFloor(f *Floor) (id uuid.UUID, err error) {
-	query := `INSERT INTO floors (name, number, scale, building_id)
-			VALUES ($1, $2, $3, $4)
+	query := `INSERT INTO floors (name, number, scale, building_id, propagation_model, elevation, height, slab_attenuation24, slab_attenuation5, slab_attenuation6)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10)
 			RETURNING id`
-	row := p.Pool.QueryRow(context.Background(), query, f.Name, f.Number, f.Scale, f.BuildingID)
+	row := p.Pool.QueryRow(context.Background(), query, f.Name, f.Number, f.Scale, f.BuildingID, f.PropagationModel,
+		f.Elevation, f.Height, f.SlabAttenuation24, f.SlabAttenuation5, f.SlabAttenuation6)
 	err = row.Scan(&id)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create floor")
+		return
 	}
+	p.invalidateBuildingCoverage(`SELECT $1::uuid`, id)
 	return
 }
 
 // GetFloor retrieves a floor
 func (p *postgres) GetFloor(floorUUID uuid.UUID) (f *Floor, err error) {
-	query := `SELECT * FROM floors WHERE id = $1 AND deleted_at IS NULL`
+	query := `SELECT ` + floorColumns + ` FROM floors WHERE id = $1 AND deleted_at IS NULL`
 	row := p.Pool.QueryRow(context.Background(), query, floorUUID)
 	f = &Floor{}
-	err = row.Scan(&f.ID, &f.Name, &f.Number, &f.Image, &f.Scale, &f.CreatedAt, &f.UpdatedAt, &f.DeletedAt, &f.BuildingID)
+	err = row.Scan(&f.ID, &f.Name, &f.Number, &f.Image, &f.Scale, &f.CreatedAt, &f.UpdatedAt, &f.DeletedAt, &f.BuildingID, &f.PropagationModel, &f.GeometryRevision,
+		&f.Elevation, &f.Height, &f.SlabAttenuation24, &f.SlabAttenuation5, &f.SlabAttenuation6,
+		&f.PathLossExponent24, &f.PathLossExponent5, &f.PathLossExponent6)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			log.Error().Err(err).Msgf("No floor found with uuid %v", floorUUID)
@@ -63,7 +72,7 @@ func (p *postgres) IsFloorSoftDeleted(floorUUID uuid.UUID) (isDeleted bool, err
 
 // GetFloors retrieves floors
 func (p *postgres) GetFloors(buildingUUID uuid.UUID) (fs []*Floor, err error) {
-	query := `SELECT * FROM floors WHERE building_id = $1 AND deleted_at IS NULL`
+	query := `SELECT ` + floorColumns + ` FROM floors WHERE building_id = $1 AND deleted_at IS NULL`
 	rows, err := p.Pool.Query(context.Background(), query, buildingUUID)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to retrieve floors")
@@ -74,7 +83,9 @@ func (p *postgres) GetFloors(buildingUUID uuid.UUID) (fs []*Floor, err error) {
 	var f *Floor
 	for rows.Next() {
 		f = new(Floor)
-		err = rows.Scan(&f.ID, &f.Name, &f.Number, &f.Image, &f.Scale, &f.CreatedAt, &f.UpdatedAt, &f.DeletedAt, &f.BuildingID)
+		err = rows.Scan(&f.ID, &f.Name, &f.Number, &f.Image, &f.Scale, &f.CreatedAt, &f.UpdatedAt, &f.DeletedAt, &f.BuildingID, &f.PropagationModel, &f.GeometryRevision,
+			&f.Elevation, &f.Height, &f.SlabAttenuation24, &f.SlabAttenuation5, &f.SlabAttenuation6,
+			&f.PathLossExponent24, &f.PathLossExponent5, &f.PathLossExponent6)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to scan floor")
 			return
@@ -104,6 +115,7 @@ func (p *postgres) SoftDeleteFloor(floorUUID uuid.UUID) (err error) {
 		return
 	}
 	log.Debug().Msg("Floor deleted_at timestamp updated successfully")
+	p.invalidateBuildingCoverage(`SELECT $1::uuid`, floorUUID)
 	return
 }
 
@@ -120,6 +132,7 @@ func (p *postgres) RestoreFloor(floorUUID uuid.UUID) (err error) {
 		return
 	}
 	log.Debug().Msg("Floor deleted_at timestamp set null successfully")
+	p.invalidateBuildingCoverage(`SELECT $1::uuid`, floorUUID)
 	return
 }
 
@@ -150,6 +163,37 @@ func (p *postgres) PatchUpdateFloor(f *Floor) (err error) {
 		params = append(params, f.Scale)
 		paramID++
 	}
+	// An empty model makes the floor use the model of its site
+	if f.PropagationModel != nil {
+		updates = append(updates, fmt.Sprintf("propagation_model = NULLIF($%d, '')", paramID))
+		params = append(params, f.PropagationModel)
+		paramID++
+	}
+	if f.Elevation != nil {
+		updates = append(updates, fmt.Sprintf("elevation = $%d", paramID))
+		params = append(params, f.Elevation)
+		paramID++
+	}
+	if f.Height != nil {
+		updates = append(updates, fmt.Sprintf("height = $%d", paramID))
+		params = append(params, f.Height)
+		paramID++
+	}
+	if f.SlabAttenuation24 != nil {
+		updates = append(updates, fmt.Sprintf("slab_attenuation24 = $%d", paramID))
+		params = append(params, f.SlabAttenuation24)
+		paramID++
+	}
+	if f.SlabAttenuation5 != nil {
+		updates = append(updates, fmt.Sprintf("slab_attenuation5 = $%d", paramID))
+		params = append(params, f.SlabAttenuation5)
+		paramID++
+	}
+	if f.SlabAttenuation6 != nil {
+		updates = append(updates, fmt.Sprintf("slab_attenuation6 = $%d", paramID))
+		params = append(params, f.SlabAttenuation6)
+		paramID++
+	}
 
 	if len(updates) == 0 {
 		log.Error().Msg("No fields provided for update")
@@ -165,5 +209,26 @@ func (p *postgres) PatchUpdateFloor(f *Floor) (err error) {
 		return
 	}
 
+	if f.Scale != nil || f.Image != nil || f.PropagationModel != nil || f.Height != nil {
+		p.invalidateMatrices(`SELECT id FROM floors WHERE id = $1`, f.ID)
+	}
+	// The levels and slabs of the building decide how much the floors leak into each other
+	if f.Scale != nil || f.Number != nil || f.Elevation != nil || f.Height != nil ||
+		f.SlabAttenuation24 != nil || f.SlabAttenuation5 != nil || f.SlabAttenuation6 != nil {
+		p.invalidateBuildingCoverage(`SELECT $1::uuid`, f.ID)
+	}
+	return
+}
+
+// GetFloorPropagationModel returns the name of the propagation model of the floor or of its site,
+// an empty name means the default model
+func (p *postgres) GetFloorPropagationModel(floorUUID uuid.UUID) (name string, err error) {
+	query := `SELECT COALESCE(f.propagation_model, s.propagation_model, '')
+		FROM floors f JOIN buildings b ON b.id = f.building_id JOIN sites s ON s.id = b.site_id
+		WHERE f.id = $1`
+	err = p.Pool.QueryRow(context.Background(), query, floorUUID).Scan(&name)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve floor propagation model")
+	}
 	return
 }
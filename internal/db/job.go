@@ -0,0 +1,207 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const jobColumns = `id, kind, status, params, progress, result, error, cancel_requested, worker,
+	created_at, updated_at, started_at, finished_at, floor_id, user_id, run_after, dedup_key`
+
+// scanJob scans a row of jobColumns
+func scanJob(row pgx.Row) (j *Job, err error) {
+	j = new(Job)
+	err = row.Scan(&j.ID, &j.Kind, &j.Status, &j.Params, &j.Progress, &j.Result, &j.Error, &j.CancelRequested, &j.Worker,
+		&j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.FinishedAt, &j.FloorID, &j.UserID, &j.RunAfter, &j.DedupKey)
+	return
+}
+
+// CreateJob queues a job
+func (p *postgres) CreateJob(j *Job) (id uuid.UUID, err error) {
+	params := j.Params
+	if params == nil {
+		params = []byte("{}")
+	}
+	query := `INSERT INTO jobs (kind, params, floor_id, user_id) VALUES ($1, $2, $3, $4) RETURNING id`
+	err = p.Pool.QueryRow(context.Background(), query, j.Kind, params, j.FloorID, j.UserID).Scan(&id)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create job")
+		return
+	}
+	log.Debug().Msgf("Queued %s job %v", j.Kind, id)
+	return
+}
+
+// EnqueueDebouncedJob queues the job to run after the delay. If a queued job with the same dedup key exists,
+// it is postponed to the end of the delay and gets the params of the job instead.
+func (p *postgres) EnqueueDebouncedJob(j *Job, delay time.Duration) (id uuid.UUID, err error) {
+	params := j.Params
+	if params == nil {
+		params = []byte("{}")
+	}
+	query := `
+INSERT INTO jobs (kind, params, floor_id, user_id, dedup_key, run_after)
+VALUES ($1, $2, $3, $4, $5, NOW() + $6::interval)
+ON CONFLICT (dedup_key) WHERE status = 'queued'
+DO UPDATE SET params = EXCLUDED.params, run_after = EXCLUDED.run_after, updated_at = NOW()
+RETURNING id`
+	err = p.Pool.QueryRow(context.Background(), query, j.Kind, params, j.FloorID, j.UserID, j.DedupKey,
+		fmt.Sprintf("%d milliseconds", delay.Milliseconds())).Scan(&id)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to enqueue debounced job")
+		return
+	}
+	log.Debug().Msgf("Debounced %s job %v runs in %v", j.Kind, id, delay)
+	return
+}
+
+// GetJob retrieves a job
+func (p *postgres) GetJob(jobUUID uuid.UUID) (j *Job, err error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE id = $1`
+	j, err = scanJob(p.Pool.QueryRow(context.Background(), query, jobUUID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msgf("No job found with uuid %v", jobUUID)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve job")
+		return
+	}
+	log.Debug().Msgf("Retrieved job: %v", j.ID)
+	return
+}
+
+// GetJobs retrieves the jobs of a floor, the newest first
+func (p *postgres) GetJobs(floorUUID uuid.UUID) (js []*Job, err error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE floor_id = $1 ORDER BY created_at DESC`
+	rows, err := p.Pool.Query(context.Background(), query, floorUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve jobs")
+		return
+	}
+	defer rows.Close()
+
+	var j *Job
+	for rows.Next() {
+		j, err = scanJob(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan jobs")
+			return
+		}
+		js = append(js, j)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error().Err(err).Msg("Rows iteration error")
+		return
+	}
+
+	log.Debug().Msgf("Retrieved %d jobs", len(js))
+	return
+}
+
+// ClaimJob marks the oldest due queued job as running by the worker and returns it.
+// Jobs locked by other instances are skipped, pgx.ErrNoRows is returned when no job is due.
+func (p *postgres) ClaimJob(worker string) (j *Job, err error) {
+	query := `
+UPDATE jobs SET status = 'running', worker = $1, progress = 0, started_at = NOW(), updated_at = NOW()
+WHERE id = (
+	SELECT id FROM jobs WHERE status = 'queued' AND run_after <= NOW()
+	ORDER BY run_after, created_at
+	FOR UPDATE SKIP LOCKED
+	LIMIT 1
+)
+RETURNING ` + jobColumns
+	j, err = scanJob(p.Pool.QueryRow(context.Background(), query, worker))
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msg("Failed to claim job")
+		}
+		return
+	}
+	log.Debug().Msgf("Worker %s claimed %s job %v", worker, j.Kind, j.ID)
+	return
+}
+
+// RequeueStaleJobs returns to the queue the running jobs without a heartbeat for the timeout,
+// their worker is considered dead. Jobs with a requested cancellation are cancelled instead.
+// Requeued jobs lose their dedup key as a newer job with the same key may be queued.
+func (p *postgres) RequeueStaleJobs(timeout time.Duration) (count int64, err error) {
+	query := `
+UPDATE jobs SET
+	status = CASE WHEN cancel_requested THEN 'cancelled' ELSE 'queued' END,
+	finished_at = CASE WHEN cancel_requested THEN NOW() END,
+	worker = NULL, dedup_key = NULL, updated_at = NOW()
+WHERE status = 'running' AND updated_at < NOW() - $1::interval`
+	commandTag, err := p.Pool.Exec(context.Background(), query, fmt.Sprintf("%d milliseconds", timeout.Milliseconds()))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to requeue stale jobs")
+		return
+	}
+	count = commandTag.RowsAffected()
+	if count > 0 {
+		log.Warn().Msgf("Requeued %d stale jobs", count)
+	}
+	return
+}
+
+// UpdateJobProgress sets the progress of a running job and renews its heartbeat.
+// It reports whether the cancellation of the job was requested.
+func (p *postgres) UpdateJobProgress(jobUUID uuid.UUID, progress int) (cancelRequested bool, err error) {
+	query := `UPDATE jobs SET progress = $2, updated_at = NOW() WHERE id = $1 AND status = 'running' RETURNING cancel_requested`
+	err = p.Pool.QueryRow(context.Background(), query, jobUUID, progress).Scan(&cancelRequested)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// The job is no longer running, e.g. it was requeued as stale
+			return true, nil
+		}
+		log.Error().Err(err).Msg("Failed to update job progress")
+	}
+	return
+}
+
+// FinishJob sets the final status of a running job with its result or error
+func (p *postgres) FinishJob(jobUUID uuid.UUID, status string, result []byte, errMsg *string) (err error) {
+	query := `
+UPDATE jobs SET status = $2, result = $3, error = $4,
+	progress = CASE WHEN $2 = 'done' THEN 100 ELSE progress END,
+	finished_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status = 'running'`
+	commandTag, err := p.Pool.Exec(context.Background(), query, jobUUID, status, result, errMsg)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to finish job")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("No running job found with the uuid: %v", jobUUID)
+		return
+	}
+	log.Debug().Msgf("Job %v is %s", jobUUID, status)
+	return
+}
+
+// CancelJob cancels a queued job at once, a running job is cancelled by its worker on the next heartbeat.
+// The status after the request is returned, pgx.ErrNoRows if the job has already finished.
+func (p *postgres) CancelJob(jobUUID uuid.UUID) (status string, err error) {
+	query := `
+UPDATE jobs SET
+	status = CASE WHEN status = 'queued' THEN 'cancelled' ELSE status END,
+	finished_at = CASE WHEN status = 'queued' THEN NOW() END,
+	cancel_requested = TRUE
+WHERE id = $1 AND status IN ('queued', 'running')
+RETURNING status`
+	err = p.Pool.QueryRow(context.Background(), query, jobUUID).Scan(&status)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msg("Failed to cancel job")
+		}
+		return
+	}
+	log.Debug().Msgf("Cancellation of job %v requested, status %s", jobUUID, status)
+	return
+}
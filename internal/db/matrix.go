@@ -0,0 +1,232 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/config"
+)
+
+// MaxMatrixCellSizes is the number of cell sizes a floor keeps a matrix of every kind for
+const MaxMatrixCellSizes = 3
+
+// MatrixRecomputeKey is the dedup key of the job generating the matrix of the kind and cell size of the floor
+func MatrixRecomputeKey(floorID uuid.UUID, kind string, cellSize float64) string {
+	return fmt.Sprintf("recompute:%v:%s:%v", floorID, kind, cellSize)
+}
+
+// SaveMatrix replaces the floor matrix of the same kind with a new one.
+// Points and rows are copied batch by batch in a single transaction, the batches channel is always drained.
+func (p *postgres) SaveMatrix(m *Matrix, batches <-chan MatrixBatch) (id uuid.UUID, err error) {
+	defer func() {
+		for range batches {
+		}
+	}()
+
+	ctx := context.Background()
+	tx, err := p.Pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to begin transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM floor_matrices WHERE floor_id = $1 AND kind = $2 AND cell_size = $3`, m.FloorID, m.Kind, m.CellSize)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete previous matrix")
+		return
+	}
+	// Matrices of the least recently generated cell sizes are dropped
+	_, err = tx.Exec(ctx, `DELETE FROM floor_matrices WHERE floor_id = $1 AND kind = $2 AND id NOT IN (
+		SELECT id FROM floor_matrices WHERE floor_id = $1 AND kind = $2 ORDER BY created_at DESC LIMIT $3)`, m.FloorID, m.Kind, MaxMatrixCellSizes-1)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete matrices of other cell sizes")
+		return
+	}
+
+	// The matrix is dirty from the start if the geometry changed during its generation
+	query := `INSERT INTO floor_matrices (kind, cell_size, min_x, min_y, max_x, max_y, floor_id, calibration_profile_id, calibration_version, geometry_revision, dirty)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10 < (SELECT geometry_revision FROM floors WHERE id = $7))
+			RETURNING id`
+	err = tx.QueryRow(ctx, query, m.Kind, m.CellSize, m.MinX, m.MinY, m.MaxX, m.MaxY, m.FloorID, m.CalibrationProfileID, m.CalibrationVersion, m.GeometryRevision).Scan(&id)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create matrix")
+		return
+	}
+
+	var pointsCount, rowsCount int64
+	for batch := range batches {
+		if batch.Err != nil {
+			log.Error().Err(batch.Err).Msg("Matrix generation failed")
+			return id, batch.Err
+		}
+		n, err := tx.CopyFrom(ctx, pgx.Identifier{"points"}, []string{"id", "x", "y", "matrix_id"},
+			pgx.CopyFromSlice(len(batch.Points), func(i int) ([]any, error) {
+				pt := batch.Points[i]
+				return []any{pt.ID, pt.X, pt.Y, id}, nil
+			}))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to copy matrix points")
+			return id, err
+		}
+		pointsCount += n
+
+		n, err = tx.CopyFrom(ctx, pgx.Identifier{"matrix"}, []string{"point_id", "sensor_id", "rssi24", "rssi5", "rssi6", "distance", "matrix_id"},
+			pgx.CopyFromSlice(len(batch.Rows), func(i int) ([]any, error) {
+				r := batch.Rows[i]
+				return []any{r.PointID, r.SensorID, r.RSSI24, r.RSSI5, r.RSSI6, r.Distance, id}, nil
+			}))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to copy matrix rows")
+			return id, err
+		}
+		rowsCount += n
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to commit matrix")
+		return
+	}
+	log.Debug().Msgf("Saved matrix %v with %d points and %d rows", id, pointsCount, rowsCount)
+	return
+}
+
+// GetMatrix retrieves the floor matrix header of the kind
+func (p *postgres) GetMatrix(floorUUID uuid.UUID, kind string, cellSize float64) (m *Matrix, err error) {
+	query := `SELECT id, kind, cell_size, min_x, min_y, max_x, max_y, created_at, floor_id, calibration_profile_id, calibration_version, geometry_revision, dirty FROM floor_matrices WHERE floor_id = $1 AND kind = $2 AND cell_size = $3`
+	row := p.Pool.QueryRow(context.Background(), query, floorUUID, kind, cellSize)
+	m = &Matrix{}
+	err = row.Scan(&m.ID, &m.Kind, &m.CellSize, &m.MinX, &m.MinY, &m.MaxX, &m.MaxY, &m.CreatedAt, &m.FloorID, &m.CalibrationProfileID, &m.CalibrationVersion, &m.GeometryRevision, &m.Dirty)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Debug().Msgf("No %s matrix of %v m cells found for floor %v", kind, cellSize, floorUUID)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve matrix")
+		return
+	}
+	log.Debug().Msgf("Retrieved matrix: %v", m)
+	return
+}
+
+// GetMatrixRows retrieves matrix rows together with their point coordinates
+func (p *postgres) GetMatrixRows(matrixUUID uuid.UUID) (rows []*MatrixRow, err error) {
+	query := `
+SELECT m.point_id, m.sensor_id, m.rssi24, m.rssi5, m.rssi6, m.distance, m.matrix_id, p.x, p.y
+FROM matrix m
+JOIN points p ON p.matrix_id = m.matrix_id AND p.id = m.point_id
+WHERE m.matrix_id = $1
+ORDER BY m.point_id
+`
+	res, err := p.Pool.Query(context.Background(), query, matrixUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve matrix rows")
+		return
+	}
+	defer res.Close()
+
+	for res.Next() {
+		r := new(MatrixRow)
+		err = res.Scan(&r.PointID, &r.SensorID, &r.RSSI24, &r.RSSI5, &r.RSSI6, &r.Distance, &r.MatrixID, &r.X, &r.Y)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan matrix rows")
+			return
+		}
+		rows = append(rows, r)
+	}
+
+	if err = res.Err(); err != nil {
+		log.Error().Err(err).Msg("Rows iteration error")
+		return
+	}
+
+	log.Debug().Msgf("Retrieved %d matrix rows", len(rows))
+	return
+}
+
+// DeleteMatrices deletes all matrices of a floor
+func (p *postgres) DeleteMatrices(floorUUID uuid.UUID) (err error) {
+	_, err = p.Pool.Exec(context.Background(), `DELETE FROM floor_matrices WHERE floor_id = $1`, floorUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete matrices")
+	}
+	return
+}
+
+// invalidateMatrices increments the geometry revision of the floors selected by floorsQuery and marks their matrices dirty,
+// see markMatricesDirty
+func (p *postgres) invalidateMatrices(floorsQuery string, args ...any) {
+	p.markMatricesDirty("", floorsQuery, args...)
+}
+
+// invalidateMatricesOfKind is invalidateMatrices for the matrices of the kind only
+func (p *postgres) invalidateMatricesOfKind(kind string, floorsQuery string, args ...any) {
+	p.markMatricesDirty(kind, floorsQuery, args...)
+}
+
+// invalidateBuildingCoverage invalidates the coverage matrices of the other floors of the buildings
+// of the floors selected by floorsQuery, access points leak into the floors above and below
+func (p *postgres) invalidateBuildingCoverage(floorsQuery string, args ...any) {
+	p.invalidateMatricesOfKind(MatrixKindCoverage, `
+SELECT o.id FROM floors o JOIN floors f ON f.building_id = o.building_id
+WHERE o.id <> f.id AND o.deleted_at IS NULL AND f.id IN (`+floorsQuery+`)`, args...)
+}
+
+// invalidateAccessPointMatrices invalidates the matrices of the floors selected by floorsQuery
+// and the coverage matrices of the other floors of their buildings
+func (p *postgres) invalidateAccessPointMatrices(floorsQuery string, args ...any) {
+	p.invalidateMatrices(floorsQuery, args...)
+	p.invalidateBuildingCoverage(floorsQuery, args...)
+}
+
+// markMatricesDirty increments the geometry revision of the floors selected by floorsQuery and marks
+// their matrices of the kind, of every kind if empty, dirty. With MATRIX_RECOMPUTE_DELAY a debounced job
+// regenerates every dirty matrix, otherwise the next request queues their recompute.
+func (p *postgres) markMatricesDirty(kind string, floorsQuery string, args ...any) {
+	kindFilter := ""
+	if kind != "" {
+		args = append(args, kind)
+		kindFilter = fmt.Sprintf(" AND m.kind = $%d", len(args))
+	}
+	query := `
+WITH changed AS (
+	UPDATE floors SET geometry_revision = geometry_revision + 1 WHERE id IN (` + floorsQuery + `) RETURNING id
+)
+UPDATE floor_matrices m SET dirty = TRUE FROM changed WHERE m.floor_id = changed.id` + kindFilter + `
+RETURNING m.floor_id, m.kind, m.cell_size`
+	rows, err := p.Pool.Query(context.Background(), query, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to invalidate matrices")
+		return
+	}
+	var dirty []*Matrix
+	for rows.Next() {
+		m := new(Matrix)
+		if err = rows.Scan(&m.FloorID, &m.Kind, &m.CellSize); err != nil {
+			log.Error().Err(err).Msg("Failed to scan dirty matrices")
+			rows.Close()
+			return
+		}
+		dirty = append(dirty, m)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		log.Error().Err(err).Msg("Rows iteration error")
+		return
+	}
+	log.Debug().Msgf("Invalidated %d matrices", len(dirty))
+
+	delay := config.App.MatrixRecomputeDelay
+	if delay <= 0 {
+		return
+	}
+	for _, m := range dirty {
+		params, _ := json.Marshal(map[string]any{"kind": m.Kind, "cellSize": m.CellSize, "onlyDirty": true})
+		key := MatrixRecomputeKey(m.FloorID, m.Kind, m.CellSize)
+		_, _ = p.EnqueueDebouncedJob(&Job{Kind: JobKindMatrix, Params: params, FloorID: m.FloorID, DedupKey: &key}, delay)
+	}
+}
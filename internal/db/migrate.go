@@ -0,0 +1,227 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Migration files are named <version>_<name>.up.sql and <version>_<name>.down.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Key of the advisory lock taken while migrations run, so concurrent instances apply them once
+const migrationLockKey int64 = 7_340_018
+
+var ErrSchemaAhead = errors.New("database schema is newer than the application")
+
+// Migration is a versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrator applies embedded migrations and records them in the schema_migrations table
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+// NewMigrator loads the embedded migrations
+func NewMigrator(pool *pgxpool.Pool) (m *Migrator, err error) {
+	m = &Migrator{pool: pool}
+	m.migrations, err = loadMigrations(migrationFiles)
+	return
+}
+
+// loadMigrations reads migrations from the migrations directory of fsys ordered by version
+func loadMigrations(fsys fs.FS) (migrations []Migration, err error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		versionStr, title, found := strings.Cut(strings.TrimSuffix(name, "."+direction+".sql"), "_")
+		if !found {
+			return nil, fmt.Errorf("migration %s: name must be <version>_<name>.%s.sql", name, direction)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", name, versionStr)
+		}
+		body, err := fs.ReadFile(fsys, path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: title}
+			byVersion[version] = mg
+		} else if mg.Name != title {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mg.Name, title)
+		}
+		if direction == "up" {
+			mg.Up = string(body)
+		} else {
+			mg.Down = string(body)
+		}
+	}
+
+	for _, mg := range byVersion {
+		if mg.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", mg.Version, mg.Name)
+		}
+		migrations = append(migrations, *mg)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return
+}
+
+// Latest returns the version of the newest embedded migration
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock
+func (m *Migrator) withLock(fn func(ctx context.Context, conn *pgxpool.Conn) error) (err error) {
+	ctx := context.Background()
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return
+	}
+	defer conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	_, err = conn.Exec(ctx, `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp
+)`)
+	if err != nil {
+		return
+	}
+	return fn(ctx, conn)
+}
+
+// version returns the newest applied migration version or 0 if none were applied
+func version(ctx context.Context, conn *pgxpool.Conn) (v int, err error) {
+	err = conn.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v)
+	return
+}
+
+// Version returns the schema version of the database
+func (m *Migrator) Version() (v int, err error) {
+	err = m.withLock(func(ctx context.Context, conn *pgxpool.Conn) (err error) {
+		v, err = version(ctx, conn)
+		return
+	})
+	return
+}
+
+// Up applies at most n pending migrations, all of them if n <= 0.
+// It fails with ErrSchemaAhead if the database has migrations unknown to the application.
+func (m *Migrator) Up(n int) (applied int, err error) {
+	err = m.withLock(func(ctx context.Context, conn *pgxpool.Conn) (err error) {
+		current, err := version(ctx, conn)
+		if err != nil {
+			return
+		}
+		if current > m.Latest() {
+			return fmt.Errorf("%w: database is at version %d, application knows up to %d", ErrSchemaAhead, current, m.Latest())
+		}
+		for _, mg := range m.migrations {
+			if mg.Version <= current {
+				continue
+			}
+			if n > 0 && applied >= n {
+				break
+			}
+			err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) (err error) {
+				if _, err = tx.Exec(ctx, mg.Up); err != nil {
+					return
+				}
+				_, err = tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mg.Version, mg.Name)
+				return
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mg.Version, mg.Name, err)
+			}
+			log.Info().Msgf("Applied migration %d_%s", mg.Version, mg.Name)
+			applied++
+		}
+		return
+	})
+	return
+}
+
+// Down reverts at most n applied migrations starting from the newest one, all of them if n <= 0
+func (m *Migrator) Down(n int) (reverted int, err error) {
+	err = m.withLock(func(ctx context.Context, conn *pgxpool.Conn) (err error) {
+		current, err := version(ctx, conn)
+		if err != nil {
+			return
+		}
+		if current > m.Latest() {
+			return fmt.Errorf("%w: database is at version %d, application knows up to %d", ErrSchemaAhead, current, m.Latest())
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mg := m.migrations[i]
+			if mg.Version > current {
+				continue
+			}
+			if n > 0 && reverted >= n {
+				break
+			}
+			if mg.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down script", mg.Version, mg.Name)
+			}
+			err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) (err error) {
+				if _, err = tx.Exec(ctx, mg.Down); err != nil {
+					return
+				}
+				_, err = tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mg.Version)
+				return
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mg.Version, mg.Name, err)
+			}
+			log.Info().Msgf("Reverted migration %d_%s", mg.Version, mg.Name)
+			reverted++
+		}
+		return
+	})
+	return
+}
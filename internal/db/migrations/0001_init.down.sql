@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS walls;
+DROP TABLE IF EXISTS wall_types;
+DROP TABLE IF EXISTS radios;
+DROP TABLE IF EXISTS access_points;
+DROP TABLE IF EXISTS radio_templates;
+DROP TABLE IF EXISTS access_point_types;
+DROP TABLE IF EXISTS floors;
+DROP TABLE IF EXISTS buildings;
+DROP TABLE IF EXISTS sites;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,155 @@
+-- Активация расширения для генерации UUID
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+CREATE TABLE IF NOT EXISTS users (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    username VARCHAR UNIQUE NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS roles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE SET NULL,
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    UNIQUE (user_id, role_id)
+);
+
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    token VARCHAR(1500) NOT NULL,
+    expiry TIMESTAMPTZ NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE SET NULL
+);
+
+
+CREATE TABLE IF NOT EXISTS sites (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR NOT NULL,
+    description VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS buildings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR NOT NULL,
+    description VARCHAR NOT NULL,
+    country VARCHAR NOT NULL,
+    city VARCHAR NOT NULL,
+    address VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    site_id UUID NOT NULL REFERENCES sites(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS floors (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR NOT NULL,
+    number INTEGER NOT NULL,
+    image VARCHAR,
+    scale FLOAT NOT NULL CHECK (scale > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    building_id UUID NOT NULL REFERENCES buildings(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS access_point_types (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR NOT NULL,
+    color VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    site_id UUID NOT NULL REFERENCES sites(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS radio_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    number INTEGER NOT NULL CHECK (number > 0),
+    channel INTEGER NOT NULL CHECK (channel > 0),
+    wifi VARCHAR NOT NULL,
+    power INTEGER NOT NULL,
+    bandwidth VARCHAR NOT NULL,
+    guard_interval INTEGER NOT NULL CHECK (guard_interval > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    access_point_type_id UUID NOT NULL REFERENCES access_point_types(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS access_points (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR NOT NULL,
+    x INTEGER NOT NULL CHECK (x > 0),
+    y INTEGER NOT NULL CHECK (y > 0),
+    z FLOAT NOT NULL CHECK (z > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    floor_id UUID NOT NULL REFERENCES floors(id) ON DELETE SET NULL,
+    access_point_type_id UUID NOT NULL REFERENCES access_point_types(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS radios (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    number INTEGER NOT NULL CHECK (number > 0),
+    channel INTEGER NOT NULL CHECK (channel > 0),
+    wifi VARCHAR NOT NULL,
+    power INTEGER NOT NULL,
+    bandwidth VARCHAR NOT NULL,
+    guard_interval INTEGER NOT NULL CHECK (guard_interval > 0),
+    is_active BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    access_point_id UUID NOT NULL REFERENCES access_points(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS wall_types (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR NOT NULL,
+    color VARCHAR NOT NULL,
+    attenuation24 FLOAT NOT NULL CHECK (attenuation24 > 0),
+    attenuation5 FLOAT NOT NULL CHECK (attenuation5 > 0),
+    attenuation6 FLOAT NOT NULL CHECK (attenuation6 > 0),
+    thickness FLOAT NOT NULL CHECK (thickness > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    site_id UUID NOT NULL REFERENCES sites(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS walls (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    x1 INTEGER NOT NULL CHECK (x1 > 0),
+    y1 INTEGER NOT NULL CHECK (y1 > 0),
+    x2 INTEGER NOT NULL CHECK (x2 > 0),
+    y2 INTEGER NOT NULL CHECK (y2 > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    floor_id UUID NOT NULL REFERENCES floors(id) ON DELETE SET NULL,
+    wall_type_id UUID NOT NULL REFERENCES wall_types(id) ON DELETE SET NULL
+);
+
+-- Relation between walls and wall types
+--ALTER TABLE walls ADD COLUMN wall_type_id UUID REFERENCES wall_types(id) ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS matrix;
+DROP TABLE IF EXISTS points;
+DROP TABLE IF EXISTS floor_matrices;
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS floor_matrices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind VARCHAR NOT NULL,
+    cell_size FLOAT NOT NULL CHECK (cell_size > 0),
+    min_x INTEGER NOT NULL,
+    min_y INTEGER NOT NULL,
+    max_x INTEGER NOT NULL,
+    max_y INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    floor_id UUID NOT NULL REFERENCES floors(id) ON DELETE CASCADE,
+    UNIQUE (floor_id, kind)
+);
+
+CREATE TABLE IF NOT EXISTS points (
+    id INTEGER NOT NULL,
+    x FLOAT NOT NULL,
+    y FLOAT NOT NULL,
+    matrix_id UUID NOT NULL REFERENCES floor_matrices(id) ON DELETE CASCADE,
+    PRIMARY KEY (matrix_id, id)
+);
+
+CREATE TABLE IF NOT EXISTS matrix (
+    point_id INTEGER NOT NULL,
+    sensor_id UUID NOT NULL,
+    rssi24 FLOAT NOT NULL,
+    rssi5 FLOAT NOT NULL,
+    rssi6 FLOAT NOT NULL,
+    distance FLOAT NOT NULL,
+    matrix_id UUID NOT NULL,
+    FOREIGN KEY (matrix_id, point_id) REFERENCES points(matrix_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS matrix_matrix_id_point_id_idx ON matrix (matrix_id, point_id);
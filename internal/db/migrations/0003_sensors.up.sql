@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS sensors (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    sensor_mac VARCHAR(17) UNIQUE NOT NULL,
+    sensor_ip VARCHAR(64) NOT NULL,
+    sensor_name VARCHAR(45) NOT NULL DEFAULT '',
+    allias VARCHAR(45) NOT NULL DEFAULT '',
+    interface_0 VARCHAR(45) NOT NULL,
+    interface_1 VARCHAR(45) NOT NULL DEFAULT '',
+    interface_2 VARCHAR(45) NOT NULL DEFAULT '',
+    x FLOAT NOT NULL CHECK (x >= 0),
+    y FLOAT NOT NULL CHECK (y >= 0),
+    z FLOAT NOT NULL CHECK (z >= 0),
+    rx_ant_gain FLOAT NOT NULL DEFAULT 0,
+    hor_rotation_offset INTEGER NOT NULL DEFAULT 0,
+    vert_rotation_offset INTEGER NOT NULL DEFAULT 0,
+    correction_factor24 FLOAT NOT NULL DEFAULT 0,
+    correction_factor5 FLOAT NOT NULL DEFAULT 0,
+    correction_factor6 FLOAT NOT NULL DEFAULT 0,
+    diagram JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    floor_id UUID NOT NULL REFERENCES floors(id) ON DELETE SET NULL
+);
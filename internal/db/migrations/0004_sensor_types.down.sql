@@ -0,0 +1,2 @@
+ALTER TABLE sensors DROP COLUMN IF EXISTS sensor_type_id;
+DROP TABLE IF EXISTS sensor_types;
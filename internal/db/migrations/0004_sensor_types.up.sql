@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS sensor_types (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR NOT NULL,
+    color VARCHAR NOT NULL,
+    rx_ant_gain FLOAT NOT NULL DEFAULT 0,
+    correction_factor24 FLOAT NOT NULL DEFAULT 0,
+    correction_factor5 FLOAT NOT NULL DEFAULT 0,
+    correction_factor6 FLOAT NOT NULL DEFAULT 0,
+    diagram JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    site_id UUID NOT NULL REFERENCES sites(id) ON DELETE SET NULL
+);
+
+-- Relation between sensors and sensor types
+ALTER TABLE sensors ADD COLUMN IF NOT EXISTS sensor_type_id UUID REFERENCES sensor_types(id) ON DELETE SET NULL;
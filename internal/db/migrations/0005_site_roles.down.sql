@@ -0,0 +1,5 @@
+DELETE FROM user_roles WHERE site_id IS NOT NULL;
+DROP INDEX IF EXISTS user_roles_user_id_site_id_idx;
+ALTER TABLE user_roles DROP COLUMN IF EXISTS site_id;
+ALTER TABLE user_roles ADD CONSTRAINT user_roles_user_id_role_id_key UNIQUE (user_id, role_id);
+DELETE FROM roles WHERE name IN ('owner', 'editor', 'viewer') AND NOT EXISTS (SELECT 1 FROM user_roles WHERE user_roles.role_id = roles.id);
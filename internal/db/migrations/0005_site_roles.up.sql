@@ -0,0 +1,8 @@
+-- Roles are granted per site, the site creator (sites.user_id) is its implicit owner
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS site_id UUID REFERENCES sites(id) ON DELETE CASCADE;
+ALTER TABLE user_roles DROP CONSTRAINT IF EXISTS user_roles_user_id_role_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS user_roles_user_id_site_id_idx ON user_roles (user_id, site_id);
+
+INSERT INTO roles (name)
+SELECT name FROM (VALUES ('owner'), ('editor'), ('viewer')) AS r(name)
+WHERE NOT EXISTS (SELECT 1 FROM roles WHERE roles.name = r.name);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS refresh_tokens_user_id_idx;
+DROP INDEX IF EXISTS refresh_tokens_family_id_idx;
+DROP INDEX IF EXISTS refresh_tokens_token_idx;
+
+ALTER TABLE refresh_tokens
+    DROP COLUMN replaced_by,
+    DROP COLUMN revoked_at,
+    DROP COLUMN created_at,
+    DROP COLUMN ip,
+    DROP COLUMN device,
+    DROP COLUMN family_id;
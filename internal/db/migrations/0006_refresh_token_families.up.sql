@@ -0,0 +1,14 @@
+-- Refresh tokens are stored as SHA-256 hashes, tokens issued before cannot be verified
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+    ADD COLUMN family_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN device VARCHAR NOT NULL DEFAULT '',
+    ADD COLUMN ip VARCHAR NOT NULL DEFAULT '',
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    ADD COLUMN revoked_at TIMESTAMPTZ,
+    ADD COLUMN replaced_by UUID REFERENCES refresh_tokens(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX refresh_tokens_token_idx ON refresh_tokens (token);
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE floors DROP COLUMN IF EXISTS propagation_model;
+ALTER TABLE sites DROP COLUMN IF EXISTS propagation_model;
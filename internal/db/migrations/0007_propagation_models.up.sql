@@ -0,0 +1,3 @@
+-- Path-loss model of the location engine, a floor without a model uses the model of its site
+ALTER TABLE sites ADD COLUMN IF NOT EXISTS propagation_model VARCHAR;
+ALTER TABLE floors ADD COLUMN IF NOT EXISTS propagation_model VARCHAR;
@@ -0,0 +1,3 @@
+ALTER TABLE floor_matrices DROP COLUMN IF EXISTS calibration_version;
+ALTER TABLE floor_matrices DROP COLUMN IF EXISTS calibration_profile_id;
+DROP TABLE IF EXISTS calibration_profiles;
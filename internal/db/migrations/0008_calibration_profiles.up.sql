@@ -0,0 +1,30 @@
+-- Tuning of the location engine per site, defaults are the constants of the engine.
+-- The version grows on every update and is recorded by the matrices built with the profile.
+CREATE TABLE IF NOT EXISTS calibration_profiles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    rssi_cutoff FLOAT NOT NULL DEFAULT -85,
+    rssi_invisible FLOAT NOT NULL DEFAULT -100,
+    correction_coefficient24 FLOAT NOT NULL DEFAULT 0,
+    correction_coefficient5 FLOAT NOT NULL DEFAULT 0,
+    correction_coefficient6 FLOAT NOT NULL DEFAULT 0,
+    calculate_walls BOOLEAN NOT NULL DEFAULT TRUE,
+    min_accuracy FLOAT NOT NULL DEFAULT 1,
+    max_accuracy FLOAT NOT NULL DEFAULT 5,
+    result_length INTEGER NOT NULL DEFAULT 15,
+    one_sensor_result_length FLOAT NOT NULL DEFAULT 1.5,
+    info_aging_time INTEGER NOT NULL DEFAULT 30,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    site_id UUID NOT NULL REFERENCES sites(id) ON DELETE CASCADE,
+    CHECK (min_accuracy > 0 AND max_accuracy >= min_accuracy),
+    CHECK (result_length > 0 AND one_sensor_result_length > 0 AND info_aging_time > 0)
+);
+
+-- A site has at most one live profile
+CREATE UNIQUE INDEX IF NOT EXISTS calibration_profiles_site_id_idx ON calibration_profiles (site_id) WHERE deleted_at IS NULL;
+
+ALTER TABLE floor_matrices ADD COLUMN IF NOT EXISTS calibration_profile_id UUID;
+ALTER TABLE floor_matrices ADD COLUMN IF NOT EXISTS calibration_version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,17 @@
+-- Walk-test measurements: the RSSI of a sensor or an access point measured at a known point of a floor plan
+CREATE TABLE IF NOT EXISTS survey_measurements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    x FLOAT NOT NULL,
+    y FLOAT NOT NULL,
+    band INTEGER NOT NULL CHECK (band IN (24, 5, 6)),
+    rssi FLOAT NOT NULL,
+    measured_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    sensor_id UUID REFERENCES sensors(id) ON DELETE CASCADE,
+    access_point_id UUID REFERENCES access_points(id) ON DELETE CASCADE,
+    floor_id UUID NOT NULL REFERENCES floors(id) ON DELETE CASCADE,
+    CHECK ((sensor_id IS NULL) <> (access_point_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS survey_measurements_floor_id_idx ON survey_measurements (floor_id);
@@ -0,0 +1,21 @@
+-- Asynchronous floor computations, claimed by the workers of any instance with FOR UPDATE SKIP LOCKED
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind VARCHAR(32) NOT NULL CHECK (kind IN ('matrix', 'heatmap', 'calibration')),
+    status VARCHAR(16) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'done', 'failed', 'cancelled')),
+    params JSONB NOT NULL DEFAULT '{}',
+    progress INTEGER NOT NULL DEFAULT 0 CHECK (progress BETWEEN 0 AND 100),
+    result JSONB,
+    error TEXT,
+    cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+    worker VARCHAR(128),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp, -- Heartbeat of the running job
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    floor_id UUID NOT NULL REFERENCES floors(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS jobs_queued_idx ON jobs (created_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS jobs_floor_id_idx ON jobs (floor_id, created_at);
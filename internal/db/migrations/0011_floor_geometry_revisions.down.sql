@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS jobs_queued_dedup_key_idx;
+DROP INDEX IF EXISTS jobs_queued_idx;
+CREATE INDEX IF NOT EXISTS jobs_queued_idx ON jobs (created_at) WHERE status = 'queued';
+
+ALTER TABLE jobs
+    DROP COLUMN IF EXISTS dedup_key,
+    DROP COLUMN IF EXISTS run_after;
+
+ALTER TABLE floor_matrices
+    DROP COLUMN IF EXISTS dirty,
+    DROP COLUMN IF EXISTS geometry_revision;
+
+ALTER TABLE floors DROP COLUMN IF EXISTS geometry_revision;
@@ -0,0 +1,16 @@
+-- Every change of the geometry of a floor increments its revision and marks its matrices dirty.
+-- A matrix records the revision it was generated from, dirty matrices may be served until they are recomputed.
+ALTER TABLE floors ADD COLUMN IF NOT EXISTS geometry_revision BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE floor_matrices
+    ADD COLUMN IF NOT EXISTS geometry_revision BIGINT NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS dirty BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Debounced jobs wait until run_after, a queued job with a dedup key is unique and postponed instead of repeated
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS run_after TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    ADD COLUMN IF NOT EXISTS dedup_key VARCHAR(128);
+
+DROP INDEX IF EXISTS jobs_queued_idx;
+CREATE INDEX IF NOT EXISTS jobs_queued_idx ON jobs (run_after, created_at) WHERE status = 'queued';
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_queued_dedup_key_idx ON jobs (dedup_key) WHERE status = 'queued';
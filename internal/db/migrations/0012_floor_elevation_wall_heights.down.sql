@@ -0,0 +1,10 @@
+ALTER TABLE floors
+    DROP COLUMN IF EXISTS slab_attenuation6,
+    DROP COLUMN IF EXISTS slab_attenuation5,
+    DROP COLUMN IF EXISTS slab_attenuation24,
+    DROP COLUMN IF EXISTS height,
+    DROP COLUMN IF EXISTS elevation;
+
+ALTER TABLE walls
+    DROP COLUMN IF EXISTS z_top,
+    DROP COLUMN IF EXISTS z_bottom;
@@ -0,0 +1,14 @@
+-- Heights in meters above the floor a wall spans, a NULL top reaches the ceiling
+ALTER TABLE walls
+    ADD COLUMN IF NOT EXISTS z_bottom FLOAT NOT NULL DEFAULT 0 CHECK (z_bottom >= 0),
+    ADD COLUMN IF NOT EXISTS z_top FLOAT CHECK (z_top > z_bottom);
+
+-- Elevation of the floor level above the ground and height of the storey in meters,
+-- attenuation in dB of the slab under the floor for signals between storeys.
+-- NULLs take the defaults of the location engine, a NULL elevation is the floor number times the height.
+ALTER TABLE floors
+    ADD COLUMN IF NOT EXISTS elevation FLOAT,
+    ADD COLUMN IF NOT EXISTS height FLOAT CHECK (height > 0),
+    ADD COLUMN IF NOT EXISTS slab_attenuation24 FLOAT CHECK (slab_attenuation24 >= 0),
+    ADD COLUMN IF NOT EXISTS slab_attenuation5 FLOAT CHECK (slab_attenuation5 >= 0),
+    ADD COLUMN IF NOT EXISTS slab_attenuation6 FLOAT CHECK (slab_attenuation6 >= 0);
@@ -0,0 +1,19 @@
+-- Obstacles are polygons of a floor plan (columns, shafts, racks, crowd zones) attenuating the signal
+-- per meter of the ray inside them. Points are a JSON array of {"x", "y"} in image pixels.
+CREATE TABLE IF NOT EXISTS obstacles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR NOT NULL,
+    color VARCHAR,
+    points JSONB NOT NULL CHECK (jsonb_typeof(points) = 'array' AND jsonb_array_length(points) >= 3),
+    attenuation24 FLOAT NOT NULL CHECK (attenuation24 >= 0),
+    attenuation5 FLOAT NOT NULL CHECK (attenuation5 >= 0),
+    attenuation6 FLOAT NOT NULL CHECK (attenuation6 >= 0),
+    z_bottom FLOAT NOT NULL DEFAULT 0 CHECK (z_bottom >= 0),
+    z_top FLOAT CHECK (z_top > z_bottom),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    deleted_at TIMESTAMPTZ,
+    floor_id UUID NOT NULL REFERENCES floors(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS obstacles_floor_id_idx ON obstacles (floor_id) WHERE deleted_at IS NULL;
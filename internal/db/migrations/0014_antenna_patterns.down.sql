@@ -0,0 +1,5 @@
+ALTER TABLE access_points
+    DROP COLUMN IF EXISTS vert_rotation_offset,
+    DROP COLUMN IF EXISTS hor_rotation_offset;
+
+ALTER TABLE access_point_types DROP COLUMN IF EXISTS diagram;
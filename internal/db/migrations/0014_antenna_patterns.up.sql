@@ -0,0 +1,7 @@
+-- Radiation diagram of the antennas of an access point model, see db.Diagram
+ALTER TABLE access_point_types ADD COLUMN IF NOT EXISTS diagram JSONB;
+
+-- Mounting of an access point: azimuth of the boresight clockwise from the top of the floor plan and downtilt in degrees
+ALTER TABLE access_points
+    ADD COLUMN IF NOT EXISTS hor_rotation_offset INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS vert_rotation_offset INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+-- Only the latest matrix of every kind of a floor is kept
+DELETE FROM floor_matrices m
+WHERE EXISTS (SELECT 1 FROM floor_matrices o WHERE o.floor_id = m.floor_id AND o.kind = m.kind AND (o.created_at, o.id) > (m.created_at, m.id));
+
+ALTER TABLE floor_matrices DROP CONSTRAINT IF EXISTS floor_matrices_floor_id_kind_cell_size_key;
+ALTER TABLE floor_matrices ADD CONSTRAINT floor_matrices_floor_id_kind_key UNIQUE (floor_id, kind);
@@ -0,0 +1,3 @@
+-- A floor keeps a matrix of every kind per cell size, so clients asking for different grids don't replace each other's matrix
+ALTER TABLE floor_matrices DROP CONSTRAINT IF EXISTS floor_matrices_floor_id_kind_key;
+ALTER TABLE floor_matrices ADD CONSTRAINT floor_matrices_floor_id_kind_cell_size_key UNIQUE (floor_id, kind, cell_size);
@@ -0,0 +1,9 @@
+ALTER TABLE floors
+    DROP COLUMN IF EXISTS path_loss_exponent6,
+    DROP COLUMN IF EXISTS path_loss_exponent5,
+    DROP COLUMN IF EXISTS path_loss_exponent24;
+
+ALTER TABLE access_points
+    DROP COLUMN IF EXISTS correction_factor6,
+    DROP COLUMN IF EXISTS correction_factor5,
+    DROP COLUMN IF EXISTS correction_factor24;
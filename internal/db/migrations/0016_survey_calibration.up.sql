@@ -0,0 +1,11 @@
+-- Calibration fitted to the survey of a floor: correction factors in dB added to the signal of the access points
+-- and path-loss exponents of the floor overriding the exponents of its propagation model
+ALTER TABLE access_points
+    ADD COLUMN IF NOT EXISTS correction_factor24 FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS correction_factor5 FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS correction_factor6 FLOAT NOT NULL DEFAULT 0;
+
+ALTER TABLE floors
+    ADD COLUMN IF NOT EXISTS path_loss_exponent24 FLOAT,
+    ADD COLUMN IF NOT EXISTS path_loss_exponent5 FLOAT,
+    ADD COLUMN IF NOT EXISTS path_loss_exponent6 FLOAT;
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+const obstacleColumns = `id, name, color, points, attenuation24, attenuation5, attenuation6, z_bottom, z_top,
+	created_at, updated_at, deleted_at, floor_id`
+
+// scanObstacle scans a row of obstacleColumns
+func scanObstacle(row pgx.Row) (o *Obstacle, err error) {
+	o = new(Obstacle)
+	err = row.Scan(&o.ID, &o.Name, &o.Color, &o.Points, &o.Attenuation24, &o.Attenuation5, &o.Attenuation6, &o.ZBottom, &o.ZTop,
+		&o.CreatedAt, &o.UpdatedAt, &o.DeletedAt, &o.FloorID)
+	return
+}
+
+// CreateObstacle creates an obstacle
+func (p *postgres) CreateObstacle(o *Obstacle) (id uuid.UUID, err error) {
+	query := `INSERT INTO obstacles (name, color, points, attenuation24, attenuation5, attenuation6, z_bottom, z_top, floor_id)
+			VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, 0), $8, $9)
+			RETURNING id`
+	row := p.Pool.QueryRow(context.Background(), query, o.Name, o.Color, o.Points, o.Attenuation24, o.Attenuation5, o.Attenuation6,
+		o.ZBottom, o.ZTop, o.FloorID)
+	err = row.Scan(&id)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create obstacle")
+		return
+	}
+	p.invalidateMatrices(`SELECT $1::uuid`, o.FloorID)
+	return
+}
+
+// GetObstacle retrieves an obstacle
+func (p *postgres) GetObstacle(obstacleUUID uuid.UUID) (o *Obstacle, err error) {
+	query := `SELECT ` + obstacleColumns + ` FROM obstacles WHERE id = $1 AND deleted_at IS NULL`
+	o, err = scanObstacle(p.Pool.QueryRow(context.Background(), query, obstacleUUID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msgf("No obstacle found with uuid %v", obstacleUUID)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve obstacle")
+		return
+	}
+	log.Debug().Msgf("Retrieved obstacle: %v", o.ID)
+	return
+}
+
+// IsObstacleSoftDeleted checks if the obstacle has been soft deleted
+func (p *postgres) IsObstacleSoftDeleted(obstacleUUID uuid.UUID) (isDeleted bool, err error) {
+	var deletedAt sql.NullTime
+	query := `SELECT deleted_at FROM obstacles WHERE id = $1`
+	err = p.Pool.QueryRow(context.Background(), query, obstacleUUID).Scan(&deletedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msgf("No obstacle found with uuid %v", obstacleUUID)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve obstacle")
+		return
+	}
+	isDeleted = deletedAt.Valid
+	log.Debug().Msgf("Is obstacle deleted: %v", isDeleted)
+	return
+}
+
+// GetObstacles retrieves the obstacles of a floor
+func (p *postgres) GetObstacles(floorUUID uuid.UUID) (obs []*Obstacle, err error) {
+	query := `SELECT ` + obstacleColumns + ` FROM obstacles WHERE floor_id = $1 AND deleted_at IS NULL ORDER BY created_at`
+	rows, err := p.Pool.Query(context.Background(), query, floorUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve obstacles")
+		return
+	}
+	defer rows.Close()
+
+	var o *Obstacle
+	for rows.Next() {
+		o, err = scanObstacle(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan obstacles")
+			return
+		}
+		obs = append(obs, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error().Err(err).Msg("Rows iteration error")
+		return
+	}
+
+	log.Debug().Msgf("Retrieved %d obstacles", len(obs))
+	return
+}
+
+// SoftDeleteObstacle soft delete an obstacle
+func (p *postgres) SoftDeleteObstacle(obstacleUUID uuid.UUID) (err error) {
+	query := `UPDATE obstacles SET deleted_at = NOW() WHERE id = $1`
+	commandTag, err := p.Pool.Exec(context.Background(), query, obstacleUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to soft delete obstacle")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("No obstacle found with the uuid: %v", obstacleUUID)
+		return
+	}
+	log.Debug().Msg("Obstacle deleted_at timestamp updated successfully")
+	p.invalidateMatrices(`SELECT floor_id FROM obstacles WHERE id = $1`, obstacleUUID)
+	return
+}
+
+// RestoreObstacle restore an obstacle
+func (p *postgres) RestoreObstacle(obstacleUUID uuid.UUID) (err error) {
+	query := `UPDATE obstacles SET deleted_at = NULL WHERE id = $1`
+	commandTag, err := p.Pool.Exec(context.Background(), query, obstacleUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to restore obstacle")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("No obstacle found with the uuid: %v", obstacleUUID)
+		return
+	}
+	log.Debug().Msg("Obstacle deleted_at timestamp set null successfully")
+	p.invalidateMatrices(`SELECT floor_id FROM obstacles WHERE id = $1`, obstacleUUID)
+	return
+}
+
+// PatchUpdateObstacle updates only the specified fields of an obstacle
+func (p *postgres) PatchUpdateObstacle(o *Obstacle) (err error) {
+	query := "UPDATE obstacles SET updated_at = NOW(), "
+	updates := []string{}
+	params := []interface{}{}
+	paramID := 1
+
+	if o.Name != nil {
+		updates = append(updates, fmt.Sprintf("name = $%d", paramID))
+		params = append(params, o.Name)
+		paramID++
+	}
+	if o.Color != nil {
+		updates = append(updates, fmt.Sprintf("color = $%d", paramID))
+		params = append(params, o.Color)
+		paramID++
+	}
+	if o.Points != nil {
+		updates = append(updates, fmt.Sprintf("points = $%d", paramID))
+		params = append(params, o.Points)
+		paramID++
+	}
+	if o.Attenuation24 != nil {
+		updates = append(updates, fmt.Sprintf("attenuation24 = $%d", paramID))
+		params = append(params, o.Attenuation24)
+		paramID++
+	}
+	if o.Attenuation5 != nil {
+		updates = append(updates, fmt.Sprintf("attenuation5 = $%d", paramID))
+		params = append(params, o.Attenuation5)
+		paramID++
+	}
+	if o.Attenuation6 != nil {
+		updates = append(updates, fmt.Sprintf("attenuation6 = $%d", paramID))
+		params = append(params, o.Attenuation6)
+		paramID++
+	}
+	if o.ZBottom != nil {
+		updates = append(updates, fmt.Sprintf("z_bottom = $%d", paramID))
+		params = append(params, o.ZBottom)
+		paramID++
+	}
+	if o.ResetZTop {
+		updates = append(updates, "z_top = NULL")
+	} else if o.ZTop != nil {
+		updates = append(updates, fmt.Sprintf("z_top = $%d", paramID))
+		params = append(params, o.ZTop)
+		paramID++
+	}
+
+	if len(updates) == 0 {
+		log.Error().Msg("No fields provided for update")
+		return fmt.Errorf("no fields provided for update")
+	}
+
+	query += strings.Join(updates, ", ") + fmt.Sprintf(" WHERE id = $%d AND deleted_at IS NULL", paramID)
+	params = append(params, o.ID)
+
+	_, err = p.Pool.Exec(context.Background(), query, params...)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to execute update")
+		return
+	}
+
+	// Only the name and the color do not change the coverage
+	if o.Points != nil || o.Attenuation24 != nil || o.Attenuation5 != nil || o.Attenuation6 != nil || o.ZBottom != nil || o.ZTop != nil || o.ResetZTop {
+		p.invalidateMatrices(`SELECT floor_id FROM obstacles WHERE id = $1`, o.ID)
+	}
+	return
+}
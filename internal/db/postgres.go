@@ -12,184 +12,32 @@ type postgres struct {
 	*pgxpool.Pool
 }
 
-// New initializes a new postgres connection.
-func New() Service {
-
+// Connect opens a postgres connection pool.
+func Connect() *pgxpool.Pool {
 	pool, err := pgxpool.New(context.Background(), config.Postgres.URL)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Unable to connect to postgres")
 	}
-	conn, err := pool.Acquire(context.Background())
-	if err != nil {
-		log.Error().Err(err).Msg("Unable to take conn from pool")
-	}
-	defer conn.Release()
-
-	// It's not recommended to defer conn.Close() here because this will close the connection immediately after New() finishes
-	// Instead, ensure that the connection is closed outside of this function when it's no longer needed
-
-	query := `
-CREATE TABLE IF NOT EXISTS users (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    username VARCHAR UNIQUE NOT NULL,
-    password VARCHAR(255) NOT NULL,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-    deleted_at TIMESTAMPTZ
-);
-
-CREATE TABLE IF NOT EXISTS roles (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    name VARCHAR NOT NULL,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-    deleted_at TIMESTAMPTZ
-);
-
-CREATE TABLE IF NOT EXISTS user_roles (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    user_id UUID NOT NULL REFERENCES users(id) ON DELETE SET NULL,
-    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE SET NULL,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-    deleted_at TIMESTAMPTZ,
-    UNIQUE (user_id, role_id)
-);
-
-
-CREATE TABLE IF NOT EXISTS refresh_tokens (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    token VARCHAR(1500) NOT NULL,
-    expiry TIMESTAMPTZ NOT NULL,
-    user_id UUID NOT NULL REFERENCES users(id) ON DELETE SET NULL
-);
-
-
-CREATE TABLE IF NOT EXISTS sites (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    name VARCHAR NOT NULL,
-    description VARCHAR NOT NULL,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-    deleted_at TIMESTAMPTZ,
-    user_id UUID NOT NULL REFERENCES users(id) ON DELETE SET NULL
-);
-
-CREATE TABLE IF NOT EXISTS buildings (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    name VARCHAR NOT NULL,
-    description VARCHAR NOT NULL,
-    country VARCHAR NOT NULL,
-    city VARCHAR NOT NULL,
-    address VARCHAR NOT NULL,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-    deleted_at TIMESTAMPTZ,
-    site_id UUID NOT NULL REFERENCES sites(id) ON DELETE SET NULL
-);
-
-CREATE TABLE IF NOT EXISTS floors (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    name VARCHAR NOT NULL,
-    number INTEGER NOT NULL,
-    image VARCHAR,
-    scale FLOAT NOT NULL CHECK (scale > 0),
-    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-    deleted_at TIMESTAMPTZ,
-    building_id UUID NOT NULL REFERENCES buildings(id) ON DELETE SET NULL
-);
-
-CREATE TABLE IF NOT EXISTS access_point_types (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    name VARCHAR NOT NULL,
-    color VARCHAR NOT NULL,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-    deleted_at TIMESTAMPTZ,
-    site_id UUID NOT NULL REFERENCES sites(id) ON DELETE SET NULL
-);
-
-CREATE TABLE IF NOT EXISTS radio_templates (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    number INTEGER NOT NULL CHECK (number > 0),
-    channel INTEGER NOT NULL CHECK (channel > 0),
-    wifi VARCHAR NOT NULL,
-    power INTEGER NOT NULL,
-    bandwidth VARCHAR NOT NULL,
-    guard_interval INTEGER NOT NULL CHECK (guard_interval > 0),
-    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-    deleted_at TIMESTAMPTZ,
-    access_point_type_id UUID NOT NULL REFERENCES access_point_types(id) ON DELETE SET NULL
-);
-
-CREATE TABLE IF NOT EXISTS access_points (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    name VARCHAR NOT NULL,
-    x INTEGER NOT NULL CHECK (x > 0),
-    y INTEGER NOT NULL CHECK (y > 0),
-    z FLOAT NOT NULL CHECK (z > 0),
-    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-    deleted_at TIMESTAMPTZ,
-    floor_id UUID NOT NULL REFERENCES floors(id) ON DELETE SET NULL,
-    access_point_type_id UUID NOT NULL REFERENCES access_point_types(id) ON DELETE SET NULL
-);
-
-CREATE TABLE IF NOT EXISTS radios (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    number INTEGER NOT NULL CHECK (number > 0),
-    channel INTEGER NOT NULL CHECK (channel > 0),
-    wifi VARCHAR NOT NULL,
-    power INTEGER NOT NULL,
-    bandwidth VARCHAR NOT NULL,
-    guard_interval INTEGER NOT NULL CHECK (guard_interval > 0),
-    is_active BOOLEAN NOT NULL DEFAULT FALSE,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-    deleted_at TIMESTAMPTZ,
-    access_point_id UUID NOT NULL REFERENCES access_points(id) ON DELETE SET NULL
-);
-
-CREATE TABLE IF NOT EXISTS wall_types (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    name VARCHAR NOT NULL,
-    color VARCHAR NOT NULL,
-    attenuation24 FLOAT NOT NULL CHECK (attenuation24 > 0),
-    attenuation5 FLOAT NOT NULL CHECK (attenuation5 > 0),
-    attenuation6 FLOAT NOT NULL CHECK (attenuation6 > 0),
-    thickness FLOAT NOT NULL CHECK (thickness > 0),
-    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-    deleted_at TIMESTAMPTZ,
-    site_id UUID NOT NULL REFERENCES sites(id) ON DELETE SET NULL
-);
-
-CREATE TABLE IF NOT EXISTS walls (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    x1 INTEGER NOT NULL CHECK (x1 > 0),
-    y1 INTEGER NOT NULL CHECK (y1 > 0),
-    x2 INTEGER NOT NULL CHECK (x2 > 0),
-    y2 INTEGER NOT NULL CHECK (y2 > 0),
-    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
-    deleted_at TIMESTAMPTZ,
-    floor_id UUID NOT NULL REFERENCES floors(id) ON DELETE SET NULL,
-    wall_type_id UUID NOT NULL REFERENCES wall_types(id) ON DELETE SET NULL
-);
-
--- Relation between walls and wall types
---ALTER TABLE walls ADD COLUMN wall_type_id UUID REFERENCES wall_types(id) ON DELETE SET NULL;
+	return pool
+}
 
--- Активация расширения для генерации UUID
-CREATE EXTENSION IF NOT EXISTS pgcrypto;`
+// New initializes a new postgres connection and applies pending migrations.
+// It refuses to start if the database schema is newer than the application.
+func New() Service {
+	pool := Connect()
 
-	_, err = conn.Exec(context.Background(), query)
+	migrator, err := NewMigrator(pool)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load migrations")
+		return nil
+	}
+	applied, err := migrator.Up(0)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to create tables")
+		log.Fatal().Err(err).Msg("Failed to migrate database")
 		return nil
 	}
+	log.Info().Msgf("Database schema is at version %d (%d migrations applied)", migrator.Latest(), applied)
+
 	db := &postgres{pool}
 	return db
 }
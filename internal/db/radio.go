@@ -8,6 +8,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
+	"slices"
 	"strings"
 )
 
@@ -20,7 +21,9 @@ func (p *postgres) CreateRadio(r *Radio) (id uuid.UUID, err error) {
 	err = row.Scan(&id)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create radio")
+		return
 	}
+	p.invalidateAccessPointMatrices(`SELECT floor_id FROM access_points WHERE id = $1`, r.AccessPointID)
 	return
 }
 
@@ -144,6 +147,7 @@ func (p *postgres) SoftDeleteRadio(radioUUID uuid.UUID) (err error) {
 		return
 	}
 	log.Debug().Msg("Access point deleted_at timestamp updated successfully")
+	p.invalidateAccessPointMatrices(`SELECT ap.floor_id FROM radios r JOIN access_points ap ON ap.id = r.access_point_id WHERE r.id = $1`, radioUUID)
 	return
 }
 
@@ -160,6 +164,7 @@ func (p *postgres) RestoreRadio(radioUUID uuid.UUID) (err error) {
 		return
 	}
 	log.Debug().Msg("Radio deleted_at timestamp set null successfully")
+	p.invalidateAccessPointMatrices(`SELECT ap.floor_id FROM radios r JOIN access_points ap ON ap.id = r.access_point_id WHERE r.id = $1`, radioUUID)
 	return
 }
 
@@ -220,5 +225,67 @@ func (p *postgres) PatchUpdateRadio(r *Radio) (err error) {
 		return
 	}
 
+	p.invalidateAccessPointMatrices(`SELECT ap.floor_id FROM radios r JOIN access_points ap ON ap.id = r.access_point_id WHERE r.id = $1`, r.ID)
+	return
+}
+
+var ErrRadioOutOfScope = errors.New("radio is not on the floors of the plan")
+var ErrRadioSettingsInvalid = errors.New("radio settings are not valid")
+
+// ApplyRadioSettings sets the channel and power of the radios in one transaction, every radio must belong
+// to an access point on one of the floors, otherwise nothing is changed and ErrRadioOutOfScope is returned.
+// The WiFi field of a radio is written only when it has none. Each radio is locked and passed to check with
+// its settings after the update, an error of check is returned wrapped in ErrRadioSettingsInvalid and nothing is changed.
+// The access points of the radios are returned.
+func (p *postgres) ApplyRadioSettings(radios []*Radio, floorIDs []uuid.UUID, check func(current, updated *Radio) error) (accessPointIDs []uuid.UUID, err error) {
+	ctx := context.Background()
+	tx, err := p.Pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to begin transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	selectQuery := `SELECT r.id, r.number, r.channel, r.wifi, r.power, r.bandwidth, r.guard_interval, r.is_active, r.created_at, r.updated_at, r.deleted_at, r.access_point_id
+			FROM radios r JOIN access_points ap ON ap.id = r.access_point_id
+			WHERE r.id = $1 AND r.deleted_at IS NULL AND ap.deleted_at IS NULL AND ap.floor_id = ANY($2)
+			FOR UPDATE OF r`
+	updateQuery := `UPDATE radios SET channel = $1, power = $2, wifi = $3, updated_at = NOW() WHERE id = $4`
+	radioIDs := make([]uuid.UUID, 0, len(radios))
+	for _, r := range radios {
+		var current Radio
+		err = tx.QueryRow(ctx, selectQuery, r.ID, floorIDs).Scan(&current.ID, &current.Number, &current.Channel, &current.WiFi, &current.Power,
+			&current.Bandwidth, &current.GuardInterval, &current.IsActive, &current.CreatedAt, &current.UpdatedAt, &current.DeletedAt, &current.AccessPointID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %v", ErrRadioOutOfScope, r.ID)
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to retrieve radio")
+			return nil, err
+		}
+		updated := current
+		updated.Channel, updated.Power = r.Channel, r.Power
+		if updated.WiFi == nil {
+			updated.WiFi = r.WiFi
+		}
+		if err = check(&current, &updated); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRadioSettingsInvalid, err)
+		}
+		if _, err = tx.Exec(ctx, updateQuery, updated.Channel, updated.Power, updated.WiFi, r.ID); err != nil {
+			log.Error().Err(err).Msg("Failed to update radio settings")
+			return nil, err
+		}
+		radioIDs = append(radioIDs, r.ID)
+		if !slices.Contains(accessPointIDs, current.AccessPointID) {
+			accessPointIDs = append(accessPointIDs, current.AccessPointID)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to commit radio settings")
+		return nil, err
+	}
+	log.Debug().Msgf("Applied settings of %d radios", len(radios))
+	p.invalidateAccessPointMatrices(`SELECT ap.floor_id FROM radios r JOIN access_points ap ON ap.id = r.access_point_id WHERE r.id = ANY($1)`, radioIDs)
 	return
 }
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// siteOfQueries select the site of an entity by its id, soft deleted entities are included so they can be restored
+var siteOfQueries = map[string]string{
+	ResourceSite:               `SELECT id FROM sites WHERE id = $1`,
+	ResourceBuilding:           `SELECT site_id FROM buildings WHERE id = $1`,
+	ResourceFloor:              `SELECT b.site_id FROM floors f JOIN buildings b ON b.id = f.building_id WHERE f.id = $1`,
+	ResourceWall:               `SELECT b.site_id FROM walls w JOIN floors f ON f.id = w.floor_id JOIN buildings b ON b.id = f.building_id WHERE w.id = $1`,
+	ResourceWallType:           `SELECT site_id FROM wall_types WHERE id = $1`,
+	ResourceObstacle:           `SELECT b.site_id FROM obstacles o JOIN floors f ON f.id = o.floor_id JOIN buildings b ON b.id = f.building_id WHERE o.id = $1`,
+	ResourceAccessPoint:        `SELECT b.site_id FROM access_points ap JOIN floors f ON f.id = ap.floor_id JOIN buildings b ON b.id = f.building_id WHERE ap.id = $1`,
+	ResourceAccessPointType:    `SELECT site_id FROM access_point_types WHERE id = $1`,
+	ResourceRadio:              `SELECT b.site_id FROM radios r JOIN access_points ap ON ap.id = r.access_point_id JOIN floors f ON f.id = ap.floor_id JOIN buildings b ON b.id = f.building_id WHERE r.id = $1`,
+	ResourceRadioTemplate:      `SELECT apt.site_id FROM radio_templates rt JOIN access_point_types apt ON apt.id = rt.access_point_type_id WHERE rt.id = $1`,
+	ResourceSensor:             `SELECT b.site_id FROM sensors s JOIN floors f ON f.id = s.floor_id JOIN buildings b ON b.id = f.building_id WHERE s.id = $1`,
+	ResourceSensorType:         `SELECT site_id FROM sensor_types WHERE id = $1`,
+	ResourceCalibrationProfile: `SELECT site_id FROM calibration_profiles WHERE id = $1`,
+	ResourceSurveyMeasurement:  `SELECT b.site_id FROM survey_measurements sm JOIN floors f ON f.id = sm.floor_id JOIN buildings b ON b.id = f.building_id WHERE sm.id = $1`,
+	ResourceJob:                `SELECT b.site_id FROM jobs j JOIN floors f ON f.id = j.floor_id JOIN buildings b ON b.id = f.building_id WHERE j.id = $1`,
+}
+
+// GetSiteIDOf retrieves the site the entity of the resource kind belongs to
+func (p *postgres) GetSiteIDOf(resource string, id uuid.UUID) (siteID uuid.UUID, err error) {
+	query, ok := siteOfQueries[resource]
+	if !ok {
+		return siteID, fmt.Errorf("unknown resource %q", resource)
+	}
+	err = p.Pool.QueryRow(context.Background(), query, id).Scan(&siteID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Debug().Msgf("No %s found with uuid %v", resource, id)
+			return
+		}
+		log.Error().Err(err).Msgf("Failed to retrieve site of %s", resource)
+	}
+	return
+}
+
+// GetUserSiteRole retrieves the role of the user in the site, the site creator is its owner.
+// An empty role is returned if the user has no access to the site.
+func (p *postgres) GetUserSiteRole(userUUID, siteUUID uuid.UUID) (role string, err error) {
+	query := `
+SELECT CASE WHEN s.user_id = $1 THEN 'owner' ELSE COALESCE(r.name, '') END
+FROM sites s
+LEFT JOIN user_roles ur ON ur.site_id = s.id AND ur.user_id = $1 AND ur.deleted_at IS NULL
+LEFT JOIN roles r ON r.id = ur.role_id AND r.deleted_at IS NULL
+WHERE s.id = $2`
+	err = p.Pool.QueryRow(context.Background(), query, userUUID, siteUUID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		log.Error().Err(err).Msg("Failed to retrieve user site role")
+	}
+	return
+}
+
+// GetSiteMembers retrieves the users having a role in the site, the site creator included
+func (p *postgres) GetSiteMembers(siteUUID uuid.UUID) (members []*SiteMember, err error) {
+	query := `
+SELECT u.id, u.username, 'owner', s.id, s.created_at
+FROM sites s
+JOIN users u ON u.id = s.user_id
+WHERE s.id = $1
+UNION ALL
+SELECT u.id, u.username, r.name, ur.site_id, ur.updated_at
+FROM user_roles ur
+JOIN users u ON u.id = ur.user_id
+JOIN roles r ON r.id = ur.role_id
+JOIN sites s ON s.id = ur.site_id
+WHERE ur.site_id = $1 AND ur.deleted_at IS NULL AND ur.user_id <> s.user_id`
+	rows, err := p.Pool.Query(context.Background(), query, siteUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve site members")
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m := new(SiteMember)
+		err = rows.Scan(&m.UserID, &m.Username, &m.Role, &m.SiteID, &m.UpdatedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan site member")
+			return
+		}
+		members = append(members, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error().Err(err).Msg("Rows iteration error")
+		return
+	}
+	return
+}
+
+// GrantSiteRole sets the role of the user in the site, replacing the previous one
+func (p *postgres) GrantSiteRole(userUUID, siteUUID uuid.UUID, role string) (err error) {
+	query := `
+INSERT INTO user_roles (user_id, role_id, site_id)
+SELECT $1, id, $2 FROM roles WHERE name = $3 AND deleted_at IS NULL
+ORDER BY created_at LIMIT 1
+ON CONFLICT (user_id, site_id) DO UPDATE SET role_id = EXCLUDED.role_id, updated_at = NOW(), deleted_at = NULL`
+	commandTag, err := p.Pool.Exec(context.Background(), query, userUUID, siteUUID, role)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to grant site role")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	return
+}
+
+// RevokeSiteRole soft deletes the role of the user in the site
+func (p *postgres) RevokeSiteRole(userUUID, siteUUID uuid.UUID) (err error) {
+	query := `UPDATE user_roles SET deleted_at = NOW() WHERE user_id = $1 AND site_id = $2 AND deleted_at IS NULL`
+	commandTag, err := p.Pool.Exec(context.Background(), query, userUUID, siteUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to revoke site role")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("User %v has no role in site %v", userUUID, siteUUID)
+	}
+	return
+}
@@ -1 +1,231 @@
 package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+const sensorColumns = `id, sensor_mac, sensor_ip, sensor_name, allias, interface_0, interface_1, interface_2, x, y, z, rx_ant_gain, hor_rotation_offset, vert_rotation_offset, correction_factor24, correction_factor5, correction_factor6, diagram, created_at, updated_at, deleted_at, floor_id, sensor_type_id`
+
+// scanSensor scans a row selected with sensorColumns
+func scanSensor(row pgx.Row, s *Sensor) error {
+	return row.Scan(&s.ID, &s.Mac, &s.Ip, &s.Name, &s.Allias, &s.Interface0, &s.Interface1, &s.Interface2,
+		&s.X, &s.Y, &s.Z, &s.RxAntGain, &s.HorRotationOffset, &s.VertRotationOffset,
+		&s.CorrectionFactor24, &s.CorrectionFactor5, &s.CorrectionFactor6, &s.Diagram,
+		&s.CreatedAt, &s.UpdatedAt, &s.DeletedAt, &s.FloorID, &s.SensorTypeID)
+}
+
+// CreateSensor creates a sensor
+func (p *postgres) CreateSensor(s *Sensor) (id uuid.UUID, err error) {
+	query := `INSERT INTO sensors (sensor_mac, sensor_ip, sensor_name, allias, interface_0, interface_1, interface_2, x, y, z, rx_ant_gain, hor_rotation_offset, vert_rotation_offset, correction_factor24, correction_factor5, correction_factor6, diagram, floor_id, sensor_type_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+			RETURNING id`
+	var diagram any
+	if len(s.Diagram) > 0 {
+		diagram = s.Diagram
+	}
+	row := p.Pool.QueryRow(context.Background(), query, s.Mac, s.Ip, s.Name, s.Allias, s.Interface0, s.Interface1, s.Interface2,
+		s.X, s.Y, s.Z, s.RxAntGain, s.HorRotationOffset, s.VertRotationOffset,
+		s.CorrectionFactor24, s.CorrectionFactor5, s.CorrectionFactor6, diagram, s.FloorID, s.SensorTypeID)
+	err = row.Scan(&id)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create sensor")
+		return
+	}
+	p.invalidateMatricesOfKind(MatrixKindSensors, `SELECT $1::uuid`, s.FloorID)
+	return
+}
+
+// GetSensor retrieves a sensor
+func (p *postgres) GetSensor(sensorUUID uuid.UUID) (s *Sensor, err error) {
+	query := `SELECT ` + sensorColumns + ` FROM sensors WHERE id = $1 AND deleted_at IS NULL`
+	row := p.Pool.QueryRow(context.Background(), query, sensorUUID)
+	s = &Sensor{}
+	err = scanSensor(row, s)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msgf("No sensor found with uuid %v", sensorUUID)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve sensor")
+		return
+	}
+	log.Debug().Msgf("Retrieved sensor: %v", s)
+	return
+}
+
+// GetSensorByMac retrieves a sensor by its MAC address
+func (p *postgres) GetSensorByMac(mac string) (s *Sensor, err error) {
+	query := `SELECT ` + sensorColumns + ` FROM sensors WHERE lower(sensor_mac) = lower($1) AND deleted_at IS NULL`
+	row := p.Pool.QueryRow(context.Background(), query, mac)
+	s = &Sensor{}
+	err = scanSensor(row, s)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msgf("No sensor found with mac %v", mac)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve sensor")
+		return
+	}
+	return
+}
+
+// IsSensorSoftDeleted checks if the sensor has been soft deleted
+func (p *postgres) IsSensorSoftDeleted(sensorUUID uuid.UUID) (isDeleted bool, err error) {
+	var deletedAt sql.NullTime // Use sql.NullTime to properly handle NULL values
+	query := `SELECT deleted_at FROM sensors WHERE id = $1`
+	row := p.Pool.QueryRow(context.Background(), query, sensorUUID)
+	err = row.Scan(&deletedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msgf("No sensor found with uuid %v", sensorUUID)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve sensor")
+		return
+	}
+	isDeleted = deletedAt.Valid
+	log.Debug().Msgf("Is sensor deleted: %v", isDeleted)
+	return
+}
+
+// GetSensors retrieves sensors
+func (p *postgres) GetSensors(floorUUID uuid.UUID) (ss []*Sensor, err error) {
+	query := `SELECT ` + sensorColumns + ` FROM sensors WHERE floor_id = $1 AND deleted_at IS NULL`
+	rows, err := p.Pool.Query(context.Background(), query, floorUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve sensors")
+		return
+	}
+	defer rows.Close()
+
+	var s *Sensor
+	for rows.Next() {
+		s = new(Sensor)
+		err = scanSensor(rows, s)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan sensor")
+			return
+		}
+		ss = append(ss, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error().Err(err).Msg("Rows iteration error")
+		return
+	}
+
+	log.Debug().Msgf("Retrieved %d sensors", len(ss))
+	return
+}
+
+// SoftDeleteSensor soft delete a sensor
+func (p *postgres) SoftDeleteSensor(sensorUUID uuid.UUID) (err error) {
+	query := `UPDATE sensors SET deleted_at = NOW() WHERE id = $1`
+	commandTag, err := p.Pool.Exec(context.Background(), query, sensorUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to soft delete sensor")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("No sensor found with the uuid: %v", sensorUUID)
+		return
+	}
+	p.invalidateMatricesOfKind(MatrixKindSensors, `SELECT floor_id FROM sensors WHERE id = $1`, sensorUUID)
+	log.Debug().Msg("Sensor deleted_at timestamp updated successfully")
+	return
+}
+
+// RestoreSensor restore a sensor
+func (p *postgres) RestoreSensor(sensorUUID uuid.UUID) (err error) {
+	query := `UPDATE sensors SET deleted_at = NULL WHERE id = $1`
+	commandTag, err := p.Pool.Exec(context.Background(), query, sensorUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to restore sensor")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("No sensor found with the uuid: %v", sensorUUID)
+		return
+	}
+	p.invalidateMatricesOfKind(MatrixKindSensors, `SELECT floor_id FROM sensors WHERE id = $1`, sensorUUID)
+	log.Debug().Msg("Sensor deleted_at timestamp set null successfully")
+	return
+}
+
+// PatchUpdateSensor updates only the specified fields of a sensor, pgx.ErrNoRows is returned when there is no live sensor with the id
+func (p *postgres) PatchUpdateSensor(s *SensorPatch) (err error) {
+	query, params, err := sensorPatchQuery(s)
+	if err != nil {
+		return
+	}
+	commandTag, err := p.Pool.Exec(context.Background(), query, params...)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to execute update")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("No sensor found with the uuid: %v", s.ID)
+		return pgx.ErrNoRows
+	}
+	p.invalidateMatricesOfKind(MatrixKindSensors, `SELECT floor_id FROM sensors WHERE id = $1`, s.ID)
+
+	return
+}
+
+// sensorPatchQuery builds the update of the specified fields of a sensor
+func sensorPatchQuery(s *SensorPatch) (query string, params []interface{}, err error) {
+	query = "UPDATE sensors SET updated_at = NOW(), "
+	updates := []string{}
+	params = []interface{}{}
+	paramID := 1
+
+	fields := []struct {
+		column string
+		value  any
+		isSet  bool
+	}{
+		{"sensor_mac", s.Mac, s.Mac != nil},
+		{"sensor_ip", s.Ip, s.Ip != nil},
+		{"sensor_name", s.Name, s.Name != nil},
+		{"allias", s.Allias, s.Allias != nil},
+		{"interface_0", s.Interface0, s.Interface0 != nil},
+		{"interface_1", s.Interface1, s.Interface1 != nil},
+		{"interface_2", s.Interface2, s.Interface2 != nil},
+		{"x", s.X, s.X != nil},
+		{"y", s.Y, s.Y != nil},
+		{"z", s.Z, s.Z != nil},
+		{"rx_ant_gain", s.RxAntGain, s.RxAntGain != nil},
+		{"hor_rotation_offset", s.HorRotationOffset, s.HorRotationOffset != nil},
+		{"vert_rotation_offset", s.VertRotationOffset, s.VertRotationOffset != nil},
+		{"correction_factor24", s.CorrectionFactor24, s.CorrectionFactor24 != nil},
+		{"correction_factor5", s.CorrectionFactor5, s.CorrectionFactor5 != nil},
+		{"correction_factor6", s.CorrectionFactor6, s.CorrectionFactor6 != nil},
+		{"diagram", s.Diagram, len(s.Diagram) > 0},
+		{"sensor_type_id", s.SensorTypeID, s.SensorTypeID != nil},
+	}
+	for _, field := range fields {
+		if field.isSet {
+			updates = append(updates, fmt.Sprintf("%s = $%d", field.column, paramID))
+			params = append(params, field.value)
+			paramID++
+		}
+	}
+
+	if len(updates) == 0 {
+		log.Error().Msg("No fields provided for update")
+		return "", nil, fmt.Errorf("no fields provided for update")
+	}
+
+	query += strings.Join(updates, ", ") + fmt.Sprintf(" WHERE id = $%d AND deleted_at IS NULL", paramID)
+	params = append(params, s.ID)
+
+	return
+}
@@ -1 +1,189 @@
 package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+// CreateSensorType creates a sensor type
+func (p *postgres) CreateSensorType(st *SensorType) (id uuid.UUID, err error) {
+	query := `INSERT INTO sensor_types (name, color, rx_ant_gain, correction_factor24, correction_factor5, correction_factor6, diagram, site_id)
+			VALUES ($1, $2, COALESCE($3, 0), COALESCE($4, 0), COALESCE($5, 0), COALESCE($6, 0), $7, $8)
+			RETURNING id`
+	var diagram any
+	if len(st.Diagram) > 0 {
+		diagram = st.Diagram
+	}
+	row := p.Pool.QueryRow(context.Background(), query, st.Name, st.Color, st.RxAntGain, st.CorrectionFactor24, st.CorrectionFactor5, st.CorrectionFactor6, diagram, st.SiteID)
+	err = row.Scan(&id)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create sensor type")
+	}
+	return
+}
+
+// GetSensorType retrieves a sensor type
+func (p *postgres) GetSensorType(sensorTypeUUID uuid.UUID) (st *SensorType, err error) {
+	query := `SELECT * FROM sensor_types WHERE id = $1 AND deleted_at IS NULL`
+	row := p.Pool.QueryRow(context.Background(), query, sensorTypeUUID)
+	st = &SensorType{}
+	err = row.Scan(&st.ID, &st.Name, &st.Color, &st.RxAntGain, &st.CorrectionFactor24, &st.CorrectionFactor5, &st.CorrectionFactor6, &st.Diagram, &st.CreatedAt, &st.UpdatedAt, &st.DeletedAt, &st.SiteID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msgf("No sensor type found with uuid %v", sensorTypeUUID)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve sensor type")
+		return
+	}
+	log.Debug().Msgf("Retrieved sensor type: %v", st)
+	return
+}
+
+// IsSensorTypeSoftDeleted checks if the sensor type has been soft deleted
+func (p *postgres) IsSensorTypeSoftDeleted(sensorTypeUUID uuid.UUID) (isDeleted bool, err error) {
+	var deletedAt sql.NullTime // Use sql.NullTime to properly handle NULL values
+	query := `SELECT deleted_at FROM sensor_types WHERE id = $1`
+	row := p.Pool.QueryRow(context.Background(), query, sensorTypeUUID)
+	err = row.Scan(&deletedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msgf("No sensor type found with uuid %v", sensorTypeUUID)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve sensor type")
+		return
+	}
+	isDeleted = deletedAt.Valid
+	log.Debug().Msgf("Is sensor type deleted: %v", isDeleted)
+	return
+}
+
+// GetSensorTypes retrieves sensor types
+func (p *postgres) GetSensorTypes(siteUUID uuid.UUID) (sts []*SensorType, err error) {
+	query := `SELECT * FROM sensor_types WHERE site_id = $1 AND deleted_at IS NULL`
+	rows, err := p.Pool.Query(context.Background(), query, siteUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve sensor types")
+		return
+	}
+	defer rows.Close()
+
+	var st *SensorType
+	for rows.Next() {
+		st = new(SensorType)
+		err = rows.Scan(&st.ID, &st.Name, &st.Color, &st.RxAntGain, &st.CorrectionFactor24, &st.CorrectionFactor5, &st.CorrectionFactor6, &st.Diagram, &st.CreatedAt, &st.UpdatedAt, &st.DeletedAt, &st.SiteID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan sensor types")
+			return
+		}
+		sts = append(sts, st)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error().Err(err).Msg("Rows iteration error")
+		return
+	}
+
+	log.Debug().Msgf("Retrieved %d sensor types", len(sts))
+	return
+}
+
+// SoftDeleteSensorType soft delete a sensor type
+func (p *postgres) SoftDeleteSensorType(sensorTypeUUID uuid.UUID) (err error) {
+	query := `UPDATE sensor_types SET deleted_at = NOW() WHERE id = $1`
+	commandTag, err := p.Pool.Exec(context.Background(), query, sensorTypeUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to soft delete sensor type")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("No sensor type found with the uuid: %v", sensorTypeUUID)
+		return
+	}
+	log.Debug().Msg("Sensor type deleted_at timestamp updated successfully")
+	return
+}
+
+// RestoreSensorType restore a sensor type
+func (p *postgres) RestoreSensorType(sensorTypeUUID uuid.UUID) (err error) {
+	query := `UPDATE sensor_types SET deleted_at = NULL WHERE id = $1`
+	commandTag, err := p.Pool.Exec(context.Background(), query, sensorTypeUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to restore sensor type")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("No sensor type found with the uuid: %v", sensorTypeUUID)
+		return
+	}
+	log.Debug().Msg("Sensor type deleted_at timestamp set null successfully")
+	return
+}
+
+// PatchUpdateSensorType updates only the specified fields of a sensor type.
+// Sensors already created from the type keep their values.
+func (p *postgres) PatchUpdateSensorType(st *SensorType) (err error) {
+	query := "UPDATE sensor_types SET updated_at = NOW(), "
+	updates := []string{}
+	params := []interface{}{}
+	paramID := 1
+
+	if st.Name != "" {
+		updates = append(updates, fmt.Sprintf("name = $%d", paramID))
+		params = append(params, st.Name)
+		paramID++
+	}
+	if st.Color != "" {
+		updates = append(updates, fmt.Sprintf("color = $%d", paramID))
+		params = append(params, st.Color)
+		paramID++
+	}
+	if st.RxAntGain != nil {
+		updates = append(updates, fmt.Sprintf("rx_ant_gain = $%d", paramID))
+		params = append(params, st.RxAntGain)
+		paramID++
+	}
+	if st.CorrectionFactor24 != nil {
+		updates = append(updates, fmt.Sprintf("correction_factor24 = $%d", paramID))
+		params = append(params, st.CorrectionFactor24)
+		paramID++
+	}
+	if st.CorrectionFactor5 != nil {
+		updates = append(updates, fmt.Sprintf("correction_factor5 = $%d", paramID))
+		params = append(params, st.CorrectionFactor5)
+		paramID++
+	}
+	if st.CorrectionFactor6 != nil {
+		updates = append(updates, fmt.Sprintf("correction_factor6 = $%d", paramID))
+		params = append(params, st.CorrectionFactor6)
+		paramID++
+	}
+	if len(st.Diagram) > 0 {
+		updates = append(updates, fmt.Sprintf("diagram = $%d", paramID))
+		params = append(params, st.Diagram)
+		paramID++
+	}
+
+	if len(updates) == 0 {
+		log.Error().Msg("No fields provided for update")
+		return fmt.Errorf("no fields provided for update")
+	}
+
+	query += strings.Join(updates, ", ") + fmt.Sprintf(" WHERE id = $%d AND deleted_at IS NULL", paramID)
+	params = append(params, st.ID)
+
+	_, err = p.Pool.Exec(context.Background(), query, params...)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to execute update")
+		return
+	}
+
+	return
+}
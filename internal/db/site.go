@@ -13,10 +13,10 @@ import (
 
 // CreateSite creates a site
 func (p *postgres) CreateSite(userUUID uuid.UUID, s *Site) (id uuid.UUID, err error) {
-	query := `INSERT INTO sites (name, description, user_id)
-			VALUES ($1, $2, $3)
+	query := `INSERT INTO sites (name, description, user_id, propagation_model)
+			VALUES ($1, $2, $3, NULLIF($4, ''))
 			RETURNING id`
-	row := p.Pool.QueryRow(context.Background(), query, s.Name, s.Description, userUUID)
+	row := p.Pool.QueryRow(context.Background(), query, s.Name, s.Description, userUUID, s.PropagationModel)
 	err = row.Scan(&id)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create site")
@@ -26,10 +26,10 @@ func (p *postgres) CreateSite(userUUID uuid.UUID, s *Site) (id uuid.UUID, err er
 
 // GetSite retrieves a site
 func (p *postgres) GetSite(siteUUID uuid.UUID) (s *Site, err error) {
-	query := `SELECT * FROM sites WHERE id = $1 AND deleted_at IS NULL`
+	query := `SELECT id, name, description, created_at, updated_at, deleted_at, user_id, propagation_model FROM sites WHERE id = $1 AND deleted_at IS NULL`
 	row := p.Pool.QueryRow(context.Background(), query, siteUUID)
 	s = &Site{}
-	err = row.Scan(&s.ID, &s.Name, &s.Description, &s.CreatedAt, &s.UpdatedAt, &s.DeletedAt, &s.UserID)
+	err = row.Scan(&s.ID, &s.Name, &s.Description, &s.CreatedAt, &s.UpdatedAt, &s.DeletedAt, &s.UserID, &s.PropagationModel)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			log.Error().Err(err).Msgf("No site found with uuid %v", siteUUID)
@@ -63,7 +63,8 @@ func (p *postgres) IsSiteSoftDeleted(siteUUID uuid.UUID) (isDeleted bool, err er
 
 // GetSites retrieves sites
 func (p *postgres) GetSites(userUUID uuid.UUID) (sites []*Site, err error) {
-	query := `SELECT * FROM sites WHERE user_id = $1 AND deleted_at IS NULL`
+	query := `SELECT id, name, description, created_at, updated_at, deleted_at, user_id, propagation_model FROM sites WHERE deleted_at IS NULL AND (user_id = $1
+		OR id IN (SELECT site_id FROM user_roles WHERE user_id = $1 AND deleted_at IS NULL))`
 	rows, err := p.Pool.Query(context.Background(), query, userUUID)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to retrieve sites")
@@ -74,7 +75,7 @@ func (p *postgres) GetSites(userUUID uuid.UUID) (sites []*Site, err error) {
 	var s *Site
 	for rows.Next() {
 		s = new(Site)
-		err = rows.Scan(&s.ID, &s.Name, &s.Description, &s.CreatedAt, &s.UpdatedAt, &s.DeletedAt, &s.UserID)
+		err = rows.Scan(&s.ID, &s.Name, &s.Description, &s.CreatedAt, &s.UpdatedAt, &s.DeletedAt, &s.UserID, &s.PropagationModel)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to scan site")
 			return
@@ -140,6 +141,12 @@ func (p *postgres) PatchUpdateSite(site *Site) (err error) {
 		params = append(params, site.Description)
 		paramID++
 	}
+	// An empty model resets the site to the default model
+	if site.PropagationModel != nil {
+		updates = append(updates, fmt.Sprintf("propagation_model = NULLIF($%d, '')", paramID))
+		params = append(params, site.PropagationModel)
+		paramID++
+	}
 
 	if len(updates) == 0 {
 		log.Error().Msg("No fields provided for update")
@@ -155,5 +162,8 @@ func (p *postgres) PatchUpdateSite(site *Site) (err error) {
 		return
 	}
 
+	if site.PropagationModel != nil {
+		p.invalidateMatrices(`SELECT f.id FROM floors f JOIN buildings b ON b.id = f.building_id WHERE b.site_id = $1`, site.ID)
+	}
 	return
 }
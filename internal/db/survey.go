@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// CreateSurveyMeasurements stores the measurements of a walk test in one copy,
+// a zero MeasuredAt is stored as the current time
+func (p *postgres) CreateSurveyMeasurements(ms []*SurveyMeasurement) (count int64, err error) {
+	now := time.Now()
+	count, err = p.Pool.CopyFrom(context.Background(), pgx.Identifier{"survey_measurements"},
+		[]string{"x", "y", "band", "rssi", "measured_at", "sensor_id", "access_point_id", "floor_id"},
+		pgx.CopyFromSlice(len(ms), func(i int) ([]any, error) {
+			m := ms[i]
+			measuredAt := m.MeasuredAt
+			if measuredAt.IsZero() {
+				measuredAt = now
+			}
+			return []any{m.X, m.Y, m.Band, m.RSSI, measuredAt, m.SensorID, m.AccessPointID, m.FloorID}, nil
+		}))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create survey measurements")
+		return
+	}
+	log.Debug().Msgf("Created %d survey measurements", count)
+	return
+}
+
+// IsSurveyMeasurementSoftDeleted checks if the survey measurement has been soft deleted
+func (p *postgres) IsSurveyMeasurementSoftDeleted(measurementUUID uuid.UUID) (isDeleted bool, err error) {
+	var deletedAt sql.NullTime // Use sql.NullTime to properly handle NULL values
+	query := `SELECT deleted_at FROM survey_measurements WHERE id = $1`
+	row := p.Pool.QueryRow(context.Background(), query, measurementUUID)
+	err = row.Scan(&deletedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msgf("No survey measurement found with uuid %v", measurementUUID)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve survey measurement")
+		return
+	}
+	isDeleted = deletedAt.Valid
+	log.Debug().Msgf("Is survey measurement deleted: %v", isDeleted)
+	return
+}
+
+// GetSurveyMeasurements retrieves the survey measurements of a floor
+func (p *postgres) GetSurveyMeasurements(floorUUID uuid.UUID) (ms []*SurveyMeasurement, err error) {
+	query := `SELECT id, x, y, band, rssi, measured_at, created_at, deleted_at, sensor_id, access_point_id, floor_id
+		FROM survey_measurements WHERE floor_id = $1 AND deleted_at IS NULL ORDER BY measured_at`
+	rows, err := p.Pool.Query(context.Background(), query, floorUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve survey measurements")
+		return
+	}
+	defer rows.Close()
+
+	var m *SurveyMeasurement
+	for rows.Next() {
+		m = new(SurveyMeasurement)
+		err = rows.Scan(&m.ID, &m.X, &m.Y, &m.Band, &m.RSSI, &m.MeasuredAt, &m.CreatedAt, &m.DeletedAt, &m.SensorID, &m.AccessPointID, &m.FloorID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan survey measurements")
+			return
+		}
+		ms = append(ms, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error().Err(err).Msg("Rows iteration error")
+		return
+	}
+
+	log.Debug().Msgf("Retrieved %d survey measurements", len(ms))
+	return
+}
+
+// SoftDeleteSurveyMeasurement soft delete a survey measurement
+func (p *postgres) SoftDeleteSurveyMeasurement(measurementUUID uuid.UUID) (err error) {
+	query := `UPDATE survey_measurements SET deleted_at = NOW() WHERE id = $1`
+	commandTag, err := p.Pool.Exec(context.Background(), query, measurementUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to soft delete survey measurement")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("No survey measurement found with the uuid: %v", measurementUUID)
+		return
+	}
+	log.Debug().Msg("Survey measurement deleted_at timestamp updated successfully")
+	return
+}
+
+// RestoreSurveyMeasurement restore a survey measurement
+func (p *postgres) RestoreSurveyMeasurement(measurementUUID uuid.UUID) (err error) {
+	query := `UPDATE survey_measurements SET deleted_at = NULL WHERE id = $1`
+	commandTag, err := p.Pool.Exec(context.Background(), query, measurementUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to restore survey measurement")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		log.Error().Msgf("No survey measurement found with the uuid: %v", measurementUUID)
+		return
+	}
+	log.Debug().Msg("Survey measurement deleted_at timestamp set null successfully")
+	return
+}
+
+// ApplySurveyCalibration stores the calibration fitted to the survey of a floor in one transaction, either all of it
+// is stored or nothing: the correction factors of the sensors and access points of the floor and its path-loss
+// exponents, nil factors and exponents are kept. pgx.ErrNoRows is returned when one of them is not live
+func (p *postgres) ApplySurveyCalibration(f *Floor, sensors []*SensorPatch, accessPoints []*AccessPoint) (err error) {
+	ctx := context.Background()
+	tx, err := p.Pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to begin transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	exec := func(query string, args ...any) error {
+		commandTag, err := tx.Exec(ctx, query, args...)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to execute update")
+			return err
+		}
+		if commandTag.RowsAffected() == 0 {
+			log.Error().Msgf("No row found with the uuid: %v", args[len(args)-1])
+			return pgx.ErrNoRows
+		}
+		return nil
+	}
+	for _, s := range sensors {
+		query, params, err := sensorPatchQuery(s)
+		if err != nil {
+			return err
+		}
+		if err = exec(query, params...); err != nil {
+			return err
+		}
+	}
+	for _, ap := range accessPoints {
+		query := `UPDATE access_points SET updated_at = NOW(), correction_factor24 = COALESCE($1, correction_factor24),
+			correction_factor5 = COALESCE($2, correction_factor5), correction_factor6 = COALESCE($3, correction_factor6)
+			WHERE id = $4 AND deleted_at IS NULL`
+		if err = exec(query, ap.CorrectionFactor24, ap.CorrectionFactor5, ap.CorrectionFactor6, ap.ID); err != nil {
+			return
+		}
+	}
+	exponents := f.PathLossExponent24 != nil || f.PathLossExponent5 != nil || f.PathLossExponent6 != nil
+	if exponents {
+		query := `UPDATE floors SET updated_at = NOW(), path_loss_exponent24 = COALESCE($1, path_loss_exponent24),
+			path_loss_exponent5 = COALESCE($2, path_loss_exponent5), path_loss_exponent6 = COALESCE($3, path_loss_exponent6)
+			WHERE id = $4 AND deleted_at IS NULL`
+		if err = exec(query, f.PathLossExponent24, f.PathLossExponent5, f.PathLossExponent6, f.ID); err != nil {
+			return
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to commit survey calibration")
+		return
+	}
+
+	// The exponents of the floor change every matrix of the floor
+	if exponents {
+		p.invalidateMatrices(`SELECT $1::uuid`, f.ID)
+	} else if len(sensors) > 0 {
+		p.invalidateMatricesOfKind(MatrixKindSensors, `SELECT $1::uuid`, f.ID)
+	}
+	if len(accessPoints) > 0 {
+		p.invalidateAccessPointMatrices(`SELECT $1::uuid`, f.ID)
+	}
+	return
+}
@@ -38,15 +38,148 @@ func (p *postgres) GetUserByUsername(username string) (u User, err error) {
 	return
 }
 
-// CreateRefreshToken creates a refresh token
-func (p *postgres) CreateRefreshToken(rt RefreshToken) (id uuid.UUID, err error) {
-	query := `INSERT INTO refresh_tokens (token, user_id)
-			VALUES ($1, $2)
+// GetUserByID retrieves a user
+func (p *postgres) GetUserByID(userUUID uuid.UUID) (u User, err error) {
+	query := `SELECT * FROM users WHERE id = $1 AND deleted_at IS NULL`
+	row := p.Pool.QueryRow(context.Background(), query, userUUID)
+	err = row.Scan(&u.ID, &u.Username, &u.Password, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msgf("No user found with uuid %v", userUUID)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve user")
+		return
+	}
+	return
+}
+
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
+const refreshTokenColumns = `id, token, expiry, user_id, family_id, device, ip, created_at, revoked_at, replaced_by`
+
+// CreateRefreshToken creates a refresh token, a new token family is started if FamilyID is not set
+func (p *postgres) CreateRefreshToken(rt *RefreshToken) (id uuid.UUID, err error) {
+	query := `INSERT INTO refresh_tokens (token, expiry, user_id, family_id, device, ip)
+			VALUES ($1, $2, $3, COALESCE($4, gen_random_uuid()), $5, $6)
+			RETURNING id, family_id`
+	var familyID *uuid.UUID
+	if rt.FamilyID != uuid.Nil {
+		familyID = &rt.FamilyID
+	}
+	row := p.Pool.QueryRow(context.Background(), query, rt.Token, rt.Expiry, rt.UserID, familyID, rt.Device, rt.IP)
+	err = row.Scan(&id, &rt.FamilyID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create refresh token")
+	}
+	return
+}
+
+// GetRefreshToken retrieves a refresh token by its hash, revoked and expired tokens included
+func (p *postgres) GetRefreshToken(tokenHash string) (rt *RefreshToken, err error) {
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE token = $1`
+	row := p.Pool.QueryRow(context.Background(), query, tokenHash)
+	rt = &RefreshToken{}
+	err = row.Scan(&rt.ID, &rt.Token, &rt.Expiry, &rt.UserID, &rt.FamilyID, &rt.Device, &rt.IP, &rt.CreatedAt, &rt.RevokedAt, &rt.ReplacedBy)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Debug().Msg("No refresh token found")
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve refresh token")
+	}
+	return
+}
+
+// RotateRefreshToken revokes the old token and creates the next one of the same family.
+// ErrRefreshTokenReused is returned if the old token has already been revoked.
+func (p *postgres) RotateRefreshToken(oldUUID uuid.UUID, next *RefreshToken) (id uuid.UUID, err error) {
+	ctx := context.Background()
+	tx, err := p.Pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to begin transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO refresh_tokens (token, expiry, user_id, family_id, device, ip)
+			VALUES ($1, $2, $3, $4, $5, $6)
 			RETURNING id`
-	row := p.Pool.QueryRow(context.Background(), query, rt.Token, rt.UserID)
-	err = row.Scan(&id)
+	err = tx.QueryRow(ctx, query, next.Token, next.Expiry, next.UserID, next.FamilyID, next.Device, next.IP).Scan(&id)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to create user")
+		log.Error().Err(err).Msg("Failed to create refresh token")
+		return
+	}
+
+	commandTag, err := tx.Exec(ctx, `UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = $2 WHERE id = $1 AND revoked_at IS NULL`, oldUUID, id)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to revoke refresh token")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		return uuid.Nil, ErrRefreshTokenReused
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to commit refresh token rotation")
+	}
+	return
+}
+
+// RevokeRefreshTokenFamily revokes all active tokens of the family
+func (p *postgres) RevokeRefreshTokenFamily(familyUUID uuid.UUID) (err error) {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+	commandTag, err := p.Pool.Exec(context.Background(), query, familyUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to revoke refresh token family")
+		return
+	}
+	log.Debug().Msgf("Revoked %d refresh tokens of family %v", commandTag.RowsAffected(), familyUUID)
+	return
+}
+
+// GetSessions retrieves the active sessions of the user
+func (p *postgres) GetSessions(userUUID uuid.UUID) (sessions []*Session, err error) {
+	query := `
+SELECT rt.family_id, rt.device, rt.ip, f.created_at, rt.created_at, rt.expiry
+FROM refresh_tokens rt
+JOIN (SELECT family_id, MIN(created_at) AS created_at FROM refresh_tokens WHERE user_id = $1 GROUP BY family_id) f ON f.family_id = rt.family_id
+WHERE rt.user_id = $1 AND rt.revoked_at IS NULL AND rt.expiry > NOW()
+ORDER BY rt.created_at DESC`
+	rows, err := p.Pool.Query(context.Background(), query, userUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve sessions")
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s := new(Session)
+		err = rows.Scan(&s.ID, &s.Device, &s.IP, &s.CreatedAt, &s.LastUsedAt, &s.Expiry)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan session")
+			return
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error().Err(err).Msg("Rows iteration error")
+		return
+	}
+	return
+}
+
+// RevokeSession revokes the session of the user
+func (p *postgres) RevokeSession(userUUID, familyUUID uuid.UUID) (err error) {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND family_id = $2 AND revoked_at IS NULL`
+	commandTag, err := p.Pool.Exec(context.Background(), query, userUUID, familyUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to revoke session")
+		return
+	}
+	if commandTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
 	}
 	return
 }
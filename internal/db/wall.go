@@ -11,25 +11,29 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+const wallColumns = `id, x1, y1, x2, y2, z_bottom, z_top, created_at, updated_at, deleted_at, floor_id, wall_type_id`
+
 // CreateWall creates a wall
 func (p *postgres) CreateWall(w *Wall) (id uuid.UUID, err error) {
-	query := `INSERT INTO walls (x1, y1, x2, y2, floor_id, wall_type_id)
-			VALUES ($1, $2, $3, $4, $5, $6)
+	query := `INSERT INTO walls (x1, y1, x2, y2, z_bottom, z_top, floor_id, wall_type_id)
+			VALUES ($1, $2, $3, $4, COALESCE($5, 0), $6, $7, $8)
 			RETURNING id`
-	row := p.Pool.QueryRow(context.Background(), query, w.X1, w.Y1, w.X2, w.Y2, w.FloorID, w.WallTypeID)
+	row := p.Pool.QueryRow(context.Background(), query, w.X1, w.Y1, w.X2, w.Y2, w.ZBottom, w.ZTop, w.FloorID, w.WallTypeID)
 	err = row.Scan(&id)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create wall")
+		return
 	}
+	p.invalidateMatrices(`SELECT id FROM floors WHERE id = $1`, w.FloorID)
 	return
 }
 
 // GetWall retrieves a wall
 func (p *postgres) GetWall(wallUUID uuid.UUID) (w *Wall, err error) {
-	query := `SELECT * FROM walls WHERE id = $1 AND deleted_at IS NULL`
+	query := `SELECT ` + wallColumns + ` FROM walls WHERE id = $1 AND deleted_at IS NULL`
 	row := p.Pool.QueryRow(context.Background(), query, wallUUID)
 	w = &Wall{}
-	err = row.Scan(&w.ID, &w.X1, &w.Y1, &w.X2, &w.Y2, &w.CreatedAt, &w.UpdatedAt, &w.DeletedAt, &w.FloorID, &w.WallTypeID)
+	err = row.Scan(&w.ID, &w.X1, &w.Y1, &w.X2, &w.Y2, &w.ZBottom, &w.ZTop, &w.CreatedAt, &w.UpdatedAt, &w.DeletedAt, &w.FloorID, &w.WallTypeID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			log.Error().Err(err).Msgf("No wall found with uuid %v", wallUUID)
@@ -63,7 +67,7 @@ func (p *postgres) IsWallSoftDeleted(wallUUID uuid.UUID) (isDeleted bool, err er
 
 // GetWalls retrieves walls
 func (p *postgres) GetWalls(floorUUID uuid.UUID) (ws []*Wall, err error) {
-	query := `SELECT * FROM walls WHERE floor_id = $1 AND deleted_at IS NULL`
+	query := `SELECT ` + wallColumns + ` FROM walls WHERE floor_id = $1 AND deleted_at IS NULL`
 	rows, err := p.Pool.Query(context.Background(), query, floorUUID)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to retrieve walls")
@@ -74,7 +78,7 @@ func (p *postgres) GetWalls(floorUUID uuid.UUID) (ws []*Wall, err error) {
 	var w *Wall
 	for rows.Next() {
 		w = new(Wall)
-		err = rows.Scan(&w.ID, &w.X1, &w.Y1, &w.X2, &w.Y2, &w.CreatedAt, &w.UpdatedAt, &w.DeletedAt, &w.FloorID, &w.WallTypeID)
+		err = rows.Scan(&w.ID, &w.X1, &w.Y1, &w.X2, &w.Y2, &w.ZBottom, &w.ZTop, &w.CreatedAt, &w.UpdatedAt, &w.DeletedAt, &w.FloorID, &w.WallTypeID)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to scan walls")
 			return
@@ -93,7 +97,7 @@ func (p *postgres) GetWalls(floorUUID uuid.UUID) (ws []*Wall, err error) {
 
 func (p *postgres) GetWallsDetailed(floorUUID uuid.UUID) (walls []*WallDetailed, err error) {
 	query := `
-SELECT w.id, w.x1, w.y1, w.x2, w.y2, w.created_at, w.updated_at, w.deleted_at, w.floor_id, w.wall_type_id, wt.id, wt.name, wt.color, wt.attenuation24, wt.attenuation5, wt.attenuation6, wt.thickness, wt.created_at, wt.updated_at, wt.deleted_at, wt.site_id
+SELECT w.id, w.x1, w.y1, w.x2, w.y2, w.z_bottom, w.z_top, w.created_at, w.updated_at, w.deleted_at, w.floor_id, w.wall_type_id, wt.id, wt.name, wt.color, wt.attenuation24, wt.attenuation5, wt.attenuation6, wt.thickness, wt.created_at, wt.updated_at, wt.deleted_at, wt.site_id
 FROM walls w
 LEFT JOIN wall_types wt ON w.wall_type_id = wt.id
 WHERE w.floor_id = $1 AND w.deleted_at IS NULL AND wt.deleted_at IS NULL
@@ -112,7 +116,7 @@ WHERE w.floor_id = $1 AND w.deleted_at IS NULL AND wt.deleted_at IS NULL
 		wt := new(WallType)
 
 		err = rows.Scan(
-			&w.ID, &w.X1, &w.Y1, &w.X2, &w.Y2, &w.CreatedAt, &w.UpdatedAt, &w.DeletedAt, &w.FloorID, &w.WallTypeID,
+			&w.ID, &w.X1, &w.Y1, &w.X2, &w.Y2, &w.ZBottom, &w.ZTop, &w.CreatedAt, &w.UpdatedAt, &w.DeletedAt, &w.FloorID, &w.WallTypeID,
 			&wt.ID, &wt.Name, &wt.Color, &wt.Attenuation24, &wt.Attenuation5, &wt.Attenuation6, &wt.Thickness, &wt.CreatedAt, &wt.UpdatedAt, &wt.DeletedAt, &wt.SiteID,
 		)
 		if err != nil {
@@ -157,6 +161,7 @@ func (p *postgres) SoftDeleteWall(wallUUID uuid.UUID) (err error) {
 		return
 	}
 	log.Debug().Msg("Wall deleted_at timestamp updated successfully")
+	p.invalidateMatrices(`SELECT floor_id FROM walls WHERE id = $1`, wallUUID)
 	return
 }
 
@@ -173,6 +178,7 @@ func (p *postgres) RestoreWall(wallUUID uuid.UUID) (err error) {
 		return
 	}
 	log.Debug().Msg("Wall deleted_at timestamp set null successfully")
+	p.invalidateMatrices(`SELECT floor_id FROM walls WHERE id = $1`, wallUUID)
 	return
 }
 
@@ -203,6 +209,18 @@ func (p *postgres) PatchUpdateWall(w *Wall) (err error) {
 		params = append(params, w.Y2)
 		paramID++
 	}
+	if w.ZBottom != nil {
+		updates = append(updates, fmt.Sprintf("z_bottom = $%d", paramID))
+		params = append(params, w.ZBottom)
+		paramID++
+	}
+	if w.ResetZTop {
+		updates = append(updates, "z_top = NULL")
+	} else if w.ZTop != nil {
+		updates = append(updates, fmt.Sprintf("z_top = $%d", paramID))
+		params = append(params, w.ZTop)
+		paramID++
+	}
 
 	if len(updates) == 0 {
 		log.Error().Msg("No fields provided for update")
@@ -218,5 +236,6 @@ func (p *postgres) PatchUpdateWall(w *Wall) (err error) {
 		return
 	}
 
+	p.invalidateMatrices(`SELECT floor_id FROM walls WHERE id = $1`, w.ID)
 	return
 }
@@ -104,6 +104,7 @@ func (p *postgres) SoftDeleteWallType(wallTypeUUID uuid.UUID) (err error) {
 		return
 	}
 	log.Debug().Msg("Wall type deleted_at timestamp updated successfully")
+	p.invalidateMatrices(`SELECT floor_id FROM walls WHERE wall_type_id = $1`, wallTypeUUID)
 	return
 }
 
@@ -120,6 +121,7 @@ func (p *postgres) RestoreWallType(wallTypeUUID uuid.UUID) (err error) {
 		return
 	}
 	log.Debug().Msg("Wall type deleted_at timestamp set null successfully")
+	p.invalidateMatrices(`SELECT floor_id FROM walls WHERE wall_type_id = $1`, wallTypeUUID)
 	return
 }
 
@@ -176,5 +178,6 @@ func (p *postgres) PatchUpdateWallType(wt *WallType) (err error) {
 		return
 	}
 
+	p.invalidateMatrices(`SELECT floor_id FROM walls WHERE wall_type_id = $1`, wt.ID)
 	return
 }
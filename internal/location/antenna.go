@@ -0,0 +1,136 @@
+package location
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"location-backend/internal/db"
+	. "math"
+	"slices"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// AntennaPattern is a validated radiation diagram, see db.Diagram. Gains between the samples are interpolated linearly.
+type AntennaPattern struct {
+	step float64   // Degrees between the samples
+	hor  []float64 // Gains in dBi of the horizontal cut from the boresight clockwise
+	vert []float64 // Gains in dBi of the vertical cut from the boresight down
+	peak float64   // Maximum gain in dBi
+}
+
+// ParseAntennaPattern decodes and validates a radiation diagram stored as JSON, an empty or null diagram is nil
+func ParseAntennaPattern(raw json.RawMessage) (*AntennaPattern, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var d db.Diagram
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return nil, fmt.Errorf("diagram is not valid JSON: %w", err)
+	}
+	return NewAntennaPattern(d)
+}
+
+// NewAntennaPattern validates a radiation diagram. The angles must start at 0 and go with a constant step
+// dividing 360 degrees, e.g. 10 or 15 degrees, each angle of the circle sampled once.
+func NewAntennaPattern(d db.Diagram) (*AntennaPattern, error) {
+	if len(d.Degree) < 2 {
+		return nil, errors.New("diagram needs at least 2 angles")
+	}
+	angles := make([]int, 0, len(d.Degree))
+	for key := range d.Degree {
+		// Only the canonical spelling is accepted, so "00" or "+0" can't sample an angle twice
+		angle, err := strconv.Atoi(key)
+		if err != nil || strconv.Itoa(angle) != key || angle < 0 || angle >= 360 {
+			return nil, fmt.Errorf("diagram angle %q is not a whole number of degrees from 0 to 359", key)
+		}
+		angles = append(angles, angle)
+	}
+	slices.Sort(angles)
+	if len(slices.Compact(slices.Clone(angles))) != len(angles) {
+		return nil, errors.New("diagram angles must be sampled once")
+	}
+	step := angles[1] - angles[0]
+	if angles[0] != 0 || step <= 0 || 360%step != 0 || len(angles) != 360/step {
+		return nil, errors.New("diagram angles must start at 0 and go with a constant step dividing 360 degrees")
+	}
+
+	p := &AntennaPattern{step: float64(step), peak: Inf(-1)}
+	for i, angle := range angles {
+		if angle != i*step {
+			return nil, fmt.Errorf("diagram angle %d breaks the step of %d degrees", angle, step)
+		}
+		g := d.Degree[strconv.Itoa(angle)]
+		if IsNaN(g.HorGain) || IsInf(g.HorGain, 0) || IsNaN(g.VertGain) || IsInf(g.VertGain, 0) {
+			return nil, fmt.Errorf("diagram gain at %d degrees is not finite", angle)
+		}
+		p.hor = append(p.hor, g.HorGain)
+		p.vert = append(p.vert, g.VertGain)
+		p.peak = Max(p.peak, Max(g.HorGain, g.VertGain))
+	}
+	return p, nil
+}
+
+// Peak returns the maximum gain of the pattern in dBi
+func (p *AntennaPattern) Peak() float64 {
+	return p.peak
+}
+
+// sample interpolates the cut at the angle in degrees
+func (p *AntennaPattern) sample(cut []float64, angle float64) float64 {
+	angle = Mod(angle, 360)
+	if angle < 0 {
+		angle += 360
+	}
+	i := int(angle / p.step)
+	frac := angle/p.step - float64(i)
+	i %= len(cut)
+	return cut[i] + frac*(cut[(i+1)%len(cut)]-cut[i])
+}
+
+// Gain returns the gain in dBi in the direction hor degrees clockwise from the boresight and vert degrees below it.
+// The cuts are combined as the horizontal gain minus the vertical loss from the peak.
+func (p *AntennaPattern) Gain(hor, vert float64) float64 {
+	return p.sample(p.hor, hor) + p.sample(p.vert, vert) - p.peak
+}
+
+// Antenna is a radiation pattern mounted on a sensor or an access point, isotropic when Pattern is nil
+type Antenna struct {
+	Pattern  *AntennaPattern
+	Azimuth  float64 // Boresight in degrees clockwise from the top of the plan
+	Tilt     float64 // Downtilt of the boresight in degrees
+	Relative bool    // Gains are relative to the peak, the emission power already includes it (EIRP)
+}
+
+// newAntenna mounts the diagram, an invalid diagram is ignored and the antenna radiates uniformly
+func newAntenna(diagram json.RawMessage, azimuth, tilt int, relative bool) Antenna {
+	pattern, err := ParseAntennaPattern(diagram)
+	if err != nil {
+		log.Warn().Err(err).Msg("Invalid radiation diagram, the antenna is considered isotropic")
+	}
+	return Antenna{Pattern: pattern, Azimuth: float64(azimuth), Tilt: float64(tilt), Relative: relative}
+}
+
+// gain returns the gain in dB towards the point dx, dy, dz meters away from the antenna, y going down the plan.
+// ok is false for an isotropic antenna.
+func (a Antenna) gain(dx, dy, dz float64) (gain float64, ok bool) {
+	if a.Pattern == nil {
+		return 0, false
+	}
+	var hor float64 = 0
+	if dx != 0 || dy != 0 {
+		hor = Atan2(dx, -dy)*180/Pi - a.Azimuth
+	}
+	// Angle below the horizon, behind the antenna the vertical cut goes on over the nadir
+	var down float64 = Atan2(-dz, Hypot(dx, dy)) * 180 / Pi
+	var vert float64 = down
+	if Cos(hor*Pi/180) < 0 {
+		vert = 180 - down
+	}
+	gain = a.Pattern.Gain(hor, vert-a.Tilt)
+	if a.Relative {
+		gain -= a.Pattern.peak
+	}
+	return gain, true
+}
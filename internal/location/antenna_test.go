@@ -0,0 +1,85 @@
+package location
+
+import (
+	"location-backend/internal/db"
+	"math"
+	"strconv"
+	"testing"
+)
+
+// sectorDiagram is a diagram of 10 degrees step radiating gain dBi at the boresight,
+// losing 1 dB per degree horizontally and 0.5 dB per degree vertically off it
+func sectorDiagram(gain float64) db.Diagram {
+	d := db.Diagram{Degree: map[string]db.Degree{}}
+	for angle := 0; angle < 360; angle += 10 {
+		off := float64(min(angle, 360-angle))
+		d.Degree[strconv.Itoa(angle)] = db.Degree{HorGain: gain - off, VertGain: gain - off/2}
+	}
+	return d
+}
+
+func TestAntennaPatternInterpolation(t *testing.T) {
+	p, err := NewAntennaPattern(sectorDiagram(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		hor, vert float64
+		want      float64
+	}{
+		{0, 0, 8},
+		{5, 0, 3},        // Between the samples of 0 and 10 degrees
+		{-5, 0, 3},       // Counter-clockwise wraps around 360 degrees
+		{355, 20, -7},    // Off both cuts
+		{180, 180, -262}, // Behind
+	}
+	for _, c := range cases {
+		if got := p.Gain(c.hor, c.vert); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("gain at %v/%v degrees is %v dBi, want %v dBi", c.hor, c.vert, got, c.want)
+		}
+	}
+}
+
+func TestAntennaRotation(t *testing.T) {
+	p, _ := NewAntennaPattern(sectorDiagram(8))
+	// Pointing east of the plan and tilted 10 degrees down
+	a := Antenna{Pattern: p, Azimuth: 90, Tilt: 10}
+	if g, _ := a.gain(10, 0, -10*math.Tan(10*math.Pi/180)); math.Abs(g-8) > 1e-9 {
+		t.Errorf("gain along the boresight is %v dBi, want 8 dBi", g)
+	}
+	// North of the plan is up, y goes down
+	if g, _ := a.gain(0, -10, 0); math.Abs(g-(8-90-5)) > 1e-9 {
+		t.Errorf("gain to the north is %v dBi, want %v dBi", g, 8-90-5)
+	}
+	a.Relative = true
+	if g, _ := a.gain(10, 0, -10*math.Tan(10*math.Pi/180)); math.Abs(g) > 1e-9 {
+		t.Errorf("relative gain along the boresight is %v dB, want 0 dB", g)
+	}
+	if _, ok := (Antenna{}).gain(1, 1, 1); ok {
+		t.Errorf("an antenna without a pattern must be isotropic")
+	}
+}
+
+func TestParseAntennaPatternRejectsInvalidDiagrams(t *testing.T) {
+	for _, raw := range []string{
+		`{"degree": {"0": {}, "10": {}}}`,                         // Does not cover the circle
+		`{"degree": {"0": {}, "120": {}, "200": {}}}`,             // Uneven step
+		`{"degree": {"90": {}, "180": {}, "270": {}, "360": {}}}`, // No zero
+		`{"degree": {"0": {}, "a": {}}}`,
+		`{"degree": {"0": {}, "00": {}}}`,                        // Zero twice
+		`{"degree": {"+0": {}, "-0": {}}}`,                       // Zero twice with signs
+		`{"degree": {"0": {}, "00": {}, "180": {}}}`,             // Zero twice beside a valid step
+		`{"degree": {"0": {}, "090": {}, "180": {}, "270": {}}}`, // Leading zero
+		`[1, 2]`,
+	} {
+		if _, err := ParseAntennaPattern([]byte(raw)); err == nil {
+			t.Errorf("diagram %s is accepted", raw)
+		}
+	}
+	if p, err := ParseAntennaPattern([]byte(`null`)); p != nil || err != nil {
+		t.Errorf("a null diagram must be no pattern")
+	}
+	if _, err := ParseAntennaPattern([]byte(`{"degree": {"0": {}, "180": {}}}`)); err != nil {
+		t.Errorf("a diagram of 180 degrees step is rejected: %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package location
+
+import (
+	"cmp"
+	"encoding/json"
+	"location-backend/internal/db"
+	. "math"
+	"slices"
+)
+
+// Slab is the attenuation in dB of the floor slabs between an emitter and the floor for a ray crossing them perpendicularly
+type Slab struct {
+	Attenuation24 float64
+	Attenuation5  float64
+	Attenuation6  float64
+}
+
+// add returns the attenuation of both slabs stacked
+func (s Slab) add(other Slab) Slab {
+	return Slab{s.Attenuation24 + other.Attenuation24, s.Attenuation5 + other.Attenuation5, s.Attenuation6 + other.Attenuation6}
+}
+
+// loss returns the negative losses for 2.4, 5 and 6 GHz bands of a ray of distance meters rising or falling dz meters.
+// An oblique ray goes a longer way through the slabs, at most SLAB_MAX_OBLIQUITY times their thickness.
+func (s Slab) loss(distance float64, dz float64) (float64, float64, float64) {
+	k := SLAB_MAX_OBLIQUITY
+	if dz != 0 {
+		k = Min(distance/Abs(dz), k)
+	}
+	return -s.Attenuation24 * k, -s.Attenuation5 * k, -s.Attenuation6 * k
+}
+
+// FloorHeight returns the height of the floor in meters, FLOOR_HEIGHT_METER when it is not set
+func FloorHeight(f *db.Floor) float64 {
+	if f.Height == nil || *f.Height <= 0 {
+		return FLOOR_HEIGHT_METER
+	}
+	return *f.Height
+}
+
+// FloorElevation returns the elevation of the floor in meters, the floor number times its height when it is not set
+func FloorElevation(f *db.Floor) float64 {
+	if f.Elevation != nil {
+		return *f.Elevation
+	}
+	var number int
+	if f.Number != nil {
+		number = *f.Number
+	}
+	return float64(number) * FloorHeight(f)
+}
+
+// floorSlab returns the attenuation of the slab under the floor, the defaults for the bands that are not set
+func floorSlab(f *db.Floor) Slab {
+	slab := Slab{SLAB_ATTENUATION24, SLAB_ATTENUATION5, SLAB_ATTENUATION6}
+	if f.SlabAttenuation24 != nil {
+		slab.Attenuation24 = *f.SlabAttenuation24
+	}
+	if f.SlabAttenuation5 != nil {
+		slab.Attenuation5 = *f.SlabAttenuation5
+	}
+	if f.SlabAttenuation6 != nil {
+		slab.Attenuation6 = *f.SlabAttenuation6
+	}
+	return slab
+}
+
+// AdjacentFloors returns the floors up to ADJACENT_FLOORS levels above and below the floor
+// among the floors of its building, ordered by elevation. The floor itself is excluded.
+func AdjacentFloors(f *db.Floor, floors []*db.Floor) (adjacent []*db.Floor) {
+	levels := slices.Clone(floors)
+	slices.SortStableFunc(levels, func(a, b *db.Floor) int {
+		return cmp.Compare(FloorElevation(a), FloorElevation(b))
+	})
+	i := slices.IndexFunc(levels, func(l *db.Floor) bool { return l.ID == f.ID })
+	if i < 0 {
+		return nil
+	}
+	for j := max(i-ADJACENT_FLOORS, 0); j <= min(i+ADJACENT_FLOORS, len(levels)-1); j++ {
+		if j != i {
+			adjacent = append(adjacent, levels[j])
+		}
+	}
+	return
+}
+
+// slabBetween returns the attenuation of the slabs between the floor and another floor of the building.
+// A floor level rests on its slab, so the slabs of the floors above the lower floor up to the upper floor are crossed.
+// Floors between them must be among the adjacent floors of the floor.
+func slabBetween(f *db.Floor, other *db.Floor) (slab Slab) {
+	low, high := FloorElevation(f), FloorElevation(other)
+	if low > high {
+		low, high = high, low
+	}
+	for _, l := range append([]*db.Floor{f}, f.AdjacentFloors...) {
+		if e := FloorElevation(l); e > low && e <= high {
+			slab = slab.add(floorSlab(l))
+		}
+	}
+	return
+}
+
+// addAdjacentEmitters adds the access points of the adjacent floors to the emitters of the floor coverage.
+// Floor plans of a building are assumed to be aligned, so plan coordinates are only rescaled, and the walls
+// of the floor attenuate the signal between their bottom and top.
+func (inputData *InputData) addAdjacentEmitters(f *db.Floor) {
+	elevation := FloorElevation(f)
+	for _, other := range f.AdjacentFloors {
+		if other.Scale == nil || *other.Scale <= 0 {
+			continue
+		}
+		// Pixels of the other plan to cells
+		k := 1 / (*other.Scale * inputData.cell_size_meters)
+		dz := FloorElevation(other) - elevation
+		slab := slabBetween(f, other)
+		for _, ap := range other.AccessPoints {
+			if ap.X == nil || ap.Y == nil {
+				continue
+			}
+			var z float64
+			if ap.Z != nil {
+				z = *ap.Z
+			}
+			inputData.sensors = append(inputData.sensors, accessPointEmitter(ap, float64(*ap.X)*k, float64(*ap.Y)*k, z+dz))
+			inputData.emissions = append(inputData.emissions, RadiosEmission(ap.Radios))
+			inputData.antennas = append(inputData.antennas, AccessPointAntenna(ap))
+			inputData.slabs = append(inputData.slabs, slab)
+		}
+	}
+}
+
+// accessPointEmitter places the access point as an emitter at the coordinates with its correction factors
+func accessPointEmitter(ap *db.AccessPointDetailed, x, y, z float64) db.Sensor {
+	emitter := db.Sensor{ID: ap.ID, Name: ap.Name, X: x, Y: y, Z: z}
+	if ap.CorrectionFactor24 != nil {
+		emitter.CorrectionFactor24 = *ap.CorrectionFactor24
+	}
+	if ap.CorrectionFactor5 != nil {
+		emitter.CorrectionFactor5 = *ap.CorrectionFactor5
+	}
+	if ap.CorrectionFactor6 != nil {
+		emitter.CorrectionFactor6 = *ap.CorrectionFactor6
+	}
+	return emitter
+}
+
+// AccessPointAntenna mounts the radiation diagram of the access point type with the rotation of the access point.
+// Radio power is EIRP, so the pattern only shapes it relative to its peak.
+func AccessPointAntenna(ap *db.AccessPointDetailed) Antenna {
+	var diagram json.RawMessage
+	if ap.AccessPointType != nil {
+		diagram = ap.AccessPointType.Diagram
+	}
+	var azimuth, tilt int
+	if ap.HorRotationOffset != nil {
+		azimuth = *ap.HorRotationOffset
+	}
+	if ap.VertRotationOffset != nil {
+		tilt = *ap.VertRotationOffset
+	}
+	return newAntenna(diagram, azimuth, tilt, true)
+}
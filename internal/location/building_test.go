@@ -0,0 +1,91 @@
+package location
+
+import (
+	"location-backend/internal/db"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// testFloor is a floor plan of 10 px per meter with an access point radiating channel 36 at (x; y) pixels, if any
+func testFloor(number int, aps ...[2]int) *db.Floor {
+	f := &db.Floor{ID: uuid.New(), Number: ptr(number), Scale: ptr(10.0)}
+	for _, xy := range aps {
+		f.AccessPoints = append(f.AccessPoints, &db.AccessPointDetailed{
+			AccessPoint: db.AccessPoint{ID: uuid.New(), X: ptr(xy[0]), Y: ptr(xy[1]), Z: ptr(2.5)},
+			Radios:      []*db.Radio{{Channel: ptr(36), Power: ptr(20)}},
+		})
+	}
+	return f
+}
+
+func TestAdjacentFloorsByElevation(t *testing.T) {
+	floors := []*db.Floor{testFloor(3), testFloor(0), testFloor(2), testFloor(1)}
+	// The floor 0 is a mezzanine between the floors 1 and 2 by its elevation
+	floors[1].Elevation = ptr(4.5)
+
+	adjacent := AdjacentFloors(floors[2], floors)
+	if len(adjacent) != 2 {
+		t.Fatalf("the floor 2 has %d adjacent floors, want 2", len(adjacent))
+	}
+	if adjacent[0] != floors[1] || adjacent[1] != floors[0] {
+		t.Errorf("adjacent floors of the floor 2 are %v and %v, want the mezzanine and the floor 3", *adjacent[0].Number, *adjacent[1].Number)
+	}
+	if adjacent := AdjacentFloors(floors[0], floors); len(adjacent) != 1 || adjacent[0] != floors[2] {
+		t.Errorf("the top floor must have one adjacent floor below")
+	}
+}
+
+func TestAccessPointLeaksThroughSlab(t *testing.T) {
+	upper := testFloor(1, [2]int{50, 50})
+	lower := testFloor(0)
+	lower.AdjacentFloors = []*db.Floor{upper}
+	upper.AdjacentFloors = []*db.Floor{lower}
+
+	same, err := NewFloorInputData(upper, CELL_SIZE_METER, 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	below, err := NewFloorInputData(lower, CELL_SIZE_METER, 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameRSSI := BuildHeatmap(same, Band5).RSSI[10][10]
+	belowRSSI := BuildHeatmap(below, Band5).RSSI[10][10]
+
+	if belowRSSI <= RSSI_INVISIBLE {
+		t.Fatalf("access point of the floor above is invisible under it")
+	}
+	// Right under the access point the ray crosses the slab perpendicularly and is FLOOR_HEIGHT_METER longer
+	if loss := sameRSSI - belowRSSI; loss < SLAB_ATTENUATION5 {
+		t.Errorf("signal through the slab is %v dB weaker, want at least %v dB", loss, SLAB_ATTENUATION5)
+	}
+}
+
+func TestWallHeightLimitsCrossedRays(t *testing.T) {
+	wall := Wall{X1: 5, Y1: 0, X2: 5, Y2: 10, Bottom: 0, Top: 2}
+	client := Client{zM: CLIENT_HEIGHT_METER}
+	cases := []struct {
+		sensor db.Sensor
+		want   bool
+	}{
+		{db.Sensor{X: 10, Y: 5, Z: 1.5}, true}, // Below the top along the whole ray
+		{db.Sensor{X: 10, Y: 5, Z: 5}, false},  // Above the top where the ray meets the wall
+		{db.Sensor{X: 6, Y: 5, Z: 2.1}, true},  // Still below the top at the wall
+		{db.Sensor{X: 10, Y: 5, Z: -4}, false}, // Under the floor, below the bottom at the wall
+		{db.Sensor{X: 0, Y: 10, Z: 1.5}, true}, // Parallel to the wall, heights overlap
+		{db.Sensor{X: 0, Y: 10, Z: -3}, true},  // Parallel to the wall, the client is within its heights
+	}
+	for _, c := range cases {
+		if got := _isWallAtRayHeight(0, 0, wall, c.sensor, client); got != c.want {
+			t.Errorf("ray to %+v: crossing %v, want %v", c.sensor, got, c.want)
+		}
+	}
+	if !_isWallAtRayHeight(0, 0, Wall{X1: 5, Y1: 0, X2: 5, Y2: 10}, db.Sensor{X: 10, Y: 5, Z: 100}, client) {
+		t.Errorf("a wall without heights must be crossed at any height")
+	}
+}
@@ -0,0 +1,71 @@
+package location
+
+import (
+	"location-backend/internal/db"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Calibration is the tuning of the engine for a site, see db.CalibrationProfile
+type Calibration struct {
+	ProfileID               *uuid.UUID // nil for the default calibration
+	Version                 int
+	RSSICutoff              float64
+	RSSIInvisible           float64
+	CorrectionCoefficient24 float64
+	CorrectionCoefficient5  float64
+	CorrectionCoefficient6  float64
+	CalculateWalls          bool
+	MinAccuracy             float64
+	MaxAccuracy             float64
+	ResultLength            int
+	OneSensorResultLength   float64
+	InfoAgingTime           time.Duration
+}
+
+// DefaultCalibration is the calibration of sites without a profile
+var DefaultCalibration = Calibration{
+	RSSICutoff:              RSII_CUTOFF,
+	RSSIInvisible:           RSSI_INVISIBLE,
+	CorrectionCoefficient24: CORRECTION_COEFFICIENT_24,
+	CorrectionCoefficient5:  CORRECTION_COEFFICIENT_5,
+	CorrectionCoefficient6:  CORRECTION_COEFFICIENT_6,
+	CalculateWalls:          CALCULATE_WALLS,
+	MinAccuracy:             MIN_ACCURACY,
+	MaxAccuracy:             MAX_ACCURACY,
+	ResultLength:            RESULT_LENGTH_SMALL,
+	OneSensorResultLength:   ONE_SENSOR_RESULT_LENGTH,
+	InfoAgingTime:           time.Duration(INFO_AGING_TIME) * time.Second,
+}
+
+// NewCalibration converts a calibration profile, nil profiles and fields take the defaults
+func NewCalibration(cp *db.CalibrationProfile) Calibration {
+	cal := DefaultCalibration
+	if cp == nil {
+		return cal
+	}
+	id := cp.ID
+	cal.ProfileID = &id
+	cal.Version = cp.Version
+	setIfNotNil(&cal.RSSICutoff, cp.RSSICutoff)
+	setIfNotNil(&cal.RSSIInvisible, cp.RSSIInvisible)
+	setIfNotNil(&cal.CorrectionCoefficient24, cp.CorrectionCoefficient24)
+	setIfNotNil(&cal.CorrectionCoefficient5, cp.CorrectionCoefficient5)
+	setIfNotNil(&cal.CorrectionCoefficient6, cp.CorrectionCoefficient6)
+	setIfNotNil(&cal.CalculateWalls, cp.CalculateWalls)
+	setIfNotNil(&cal.MinAccuracy, cp.MinAccuracy)
+	setIfNotNil(&cal.MaxAccuracy, cp.MaxAccuracy)
+	setIfNotNil(&cal.ResultLength, cp.ResultLength)
+	setIfNotNil(&cal.OneSensorResultLength, cp.OneSensorResultLength)
+	if cp.InfoAgingTime != nil {
+		cal.InfoAgingTime = time.Duration(*cp.InfoAgingTime) * time.Second
+	}
+	return cal
+}
+
+func setIfNotNil[T any](dst *T, src *T) {
+	if src != nil {
+		*dst = *src
+	}
+}
@@ -23,7 +23,7 @@ const CORRECTION_COEFFICIENT_6 float64 = 0
 const CALCULATE_WALLS = true
 
 // Частота для 2.4 ГГц диапазона (по умолчанию 2437 МГц – 6 канал)
-const FREQUENCY24 int = 2437
+const FREQUENCY24 float64 = 2437
 
 // Коэффициент распространения сигнала для 2.4 ГГц
 const PENETRATION_FACTOR24 float64 = 1
@@ -31,8 +31,8 @@ const PENETRATION_FACTOR24 float64 = 1
 // Коэффициент затухания для 2.4 ГГц
 const ATTENUATION_FACTOR24 float64 = 2 // 2.5 или 2.71
 
-// Частота для 5 ГГц диапазона
-const FREQUENCY5 int = 5250 // 48 - 52 channel
+// Частота для 5 ГГц диапазона (по умолчанию 5250 МГц – между 48 и 52 каналами)
+const FREQUENCY5 float64 = 5250
 
 // Коэффициент распространения сигнала для 5 ГГц
 const PENETRATION_FACTOR5 float64 = 1 // был 6
@@ -40,8 +40,8 @@ const PENETRATION_FACTOR5 float64 = 1 // был 6
 // Коэффициент затухания для 5 ГГц
 const ATTENUATION_FACTOR5 float64 = 2 // 3
 
-// Частота для 6 ГГц диапазона
-const FREQUENCY6 int = 5250 // пересчитать
+// Частота для 6 ГГц диапазона (по умолчанию 5975 МГц – 5 канал)
+const FREQUENCY6 float64 = 5975
 
 // Коэффициент распространения сигнала для 6 ГГц
 const PENETRATION_FACTOR6 float64 = 1 // пересчитать
@@ -90,8 +90,116 @@ const EIRP float64 = 18
 // Слишком высокое – возможны ошибки соединения с БД из - за таймаутов.
 const BUFFER_MAX_LENGTH int = 10000
 
-// Размер ячейки.Ниже 0.25 лучше не ставить.
-//const CELL_SIZE_METER = 0.25;
+// Размер ячейки по умолчанию. Ниже 0.25 лучше не ставить.
+const CELL_SIZE_METER float64 = 0.5
 
-// Добавочный размер карты в мерах.Позволяет рассчитывать территорию вне помещения.
-//const AREA_INDENT_METER = 0;
+// Минимально допустимый размер ячейки.
+const MIN_CELL_SIZE_METER float64 = 0.25
+
+// Добавочный размер карты в метрах. Позволяет рассчитывать территорию вне помещения.
+const AREA_INDENT_METER float64 = 0
+
+// Количество строк сетки в одной части карты, части рассчитываются параллельно.
+const MATRIX_TILE_ROWS int = 8
+
+// Максимальное количество ячеек в одной карте. Защищает от расчёта карт с миллионами точек.
+const MAX_MATRIX_CELLS int = 2000000
+
+////////////
+// heatmap consts
+
+// Высота клиентского устройства над полом в метрах
+const CLIENT_HEIGHT_METER float64 = 1
+
+// Запас в долях ячейки индекса стен, на который расширяются границы при поиске, чтобы ошибки округления не теряли стены.
+const WALL_INDEX_EPSILON float64 = 1e-6
+
+////////////
+// building consts
+
+// Высота этажа по умолчанию в метрах: от пола этажа до пола следующего этажа
+const FLOOR_HEIGHT_METER float64 = 3
+
+// Затухание перекрытия между этажами по умолчанию в дБ для диапазонов 2,4, 5 и 6 ГГц (железобетон)
+const SLAB_ATTENUATION24 float64 = 15
+const SLAB_ATTENUATION5 float64 = 20
+const SLAB_ATTENUATION6 float64 = 23
+
+// Количество этажей сверху и снизу, точки доступа которых учитываются в покрытии этажа
+const ADJACENT_FLOORS int = 1
+
+// Максимальный множитель затухания перекрытия для наклонного луча: путь сквозь перекрытие не длиннее стольких его толщин
+const SLAB_MAX_OBLIQUITY float64 = 3
+
+////////////
+// propagation consts
+
+// Параметры многостенной модели COST-231 (COST 231 Final Report, 1,8 ГГц), используются во всех диапазонах.
+// Постоянные потери Lc получаются при подборе потерь стен регрессией и по отчёту близки к нулю.
+const COST231_LC float64 = 0
+const COST231_LIGHT_WALL float64 = 3.4 // дБ, лёгкие стены: гипсокартон, тонкий лёгкий бетон
+const COST231_HEAVY_WALL float64 = 6.9 // дБ, тяжёлые стены: несущие, бетон, кирпич
+
+// Стены толще стольких метров считаются тяжёлыми в модели COST-231
+const COST231_HEAVY_WALL_THICKNESS float64 = 0.1
+
+////////////
+// coverage consts
+
+// Пороги RSSI в dBm по умолчанию, для которых отчёт о покрытии считает долю площади этажа
+var COVERAGE_THRESHOLDS = []float64{-67, -70, -75}
+
+// Минимальная площадь дыры в покрытии в м² по умолчанию, меньшие дыры не попадают в отчёт
+const COVERAGE_MIN_HOLE_AREA float64 = 10
+
+////////////
+// interference consts
+
+// Спектральная плотность теплового шума в dBm/Гц
+const THERMAL_NOISE_DBM_PER_HZ float64 = -174
+
+// Коэффициент шума приёмника клиента в дБ
+const NOISE_FIGURE float64 = 7
+
+// Подавление соседнего неперекрывающегося канала в дБ по спектральной маске передатчика
+const ADJACENT_CHANNEL_REJECTION float64 = 28
+
+// Уровень помехи в dBm, начиная с которого клиент слышит преамбулы мешающей точки доступа
+const INTERFERENCE_DETECT_RSSI float64 = -82
+
+// Количество пар радиомодулей с наибольшим перекрытием в отчёте о помехах по умолчанию
+const INTERFERENCE_PAIRS int = 10
+
+////////////
+// channel plan consts
+
+// Разрешённые основные каналы по умолчанию: неперекрывающиеся каналы 2,4 ГГц, каналы 5 ГГц до 161 и PSC-каналы 6 ГГц
+var PLAN_CHANNELS24 = []int{1, 6, 11}
+var PLAN_CHANNELS5 = []int{36, 40, 44, 48, 52, 56, 60, 64, 100, 104, 108, 112, 116, 120, 124, 128, 132, 136, 140, 144, 149, 153, 157, 161}
+var PLAN_CHANNELS6 = []int{5, 21, 37, 53, 69, 85, 101, 117, 133, 149, 165, 181, 197, 213, 229}
+
+// На сколько дБ планировщик может снизить мощность радиомодуля, если диапазон мощности не задан
+const PLAN_POWER_RANGE int = 6
+
+// Допустимая мощность радиомодуля в dBm, планировщик не предлагает и не применяет мощности вне этого диапазона
+const PLAN_MIN_POWER int = 0
+const PLAN_MAX_POWER int = 30
+
+// Шаг перебора мощности в дБ
+const PLAN_POWER_STEP int = 1
+
+// Максимальное количество ячеек, по которым оценивается план, большие этажи прореживаются
+const PLAN_MAX_CELLS int = 4000
+
+// Максимальное количество проходов по радиомодулям при поиске плана
+const PLAN_ROUNDS int = 4
+
+// Вес площади без покрытия относительно площади помех: 1 м² без покрытия стоит как столько м² помех
+const PLAN_COVERAGE_WEIGHT float64 = 4
+
+// Штраф за DFS-канал при предпочтении "avoid" в долях оцениваемой площади
+const PLAN_DFS_PENALTY float64 = 0.01
+
+// Каналы 5 ГГц с DFS (U-NII-2A и U-NII-2C)
+const DFS_FIRST_CHANNEL int = 52
+const DFS_LAST_CHANNEL int = 144
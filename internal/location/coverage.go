@@ -0,0 +1,178 @@
+package location
+
+import (
+	"cmp"
+	"location-backend/internal/db"
+	. "math"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// CoverageReport holds the coverage statistics of a floor for one band.
+// The floor area is the grid of its coverage matrix without AREA_INDENT_METER around the plan.
+type CoverageReport struct {
+	Band       Band                `json:"band"`
+	CellSize   float64             `json:"cellSize"`
+	Area       float64             `json:"area"` // Square meters
+	Thresholds []ThresholdCoverage `json:"thresholds"`
+	Cells      []CellCoverage      `json:"cells,omitempty"`
+}
+
+// ThresholdCoverage is the floor area receiving at least RSSI dBm and the holes below it
+type ThresholdCoverage struct {
+	RSSI    float64        `json:"rssi"`
+	Area    float64        `json:"area"` // Square meters
+	Percent float64        `json:"percent"`
+	Holes   []CoverageHole `json:"holes"`
+}
+
+// CoverageHole is a connected area of cells below a threshold, coordinates are in meters
+type CoverageHole struct {
+	Area      float64 `json:"area"` // Square meters
+	X         float64 `json:"x"`    // Centroid
+	Y         float64 `json:"y"`
+	MinX      float64 `json:"minX"`
+	MinY      float64 `json:"minY"`
+	MaxX      float64 `json:"maxX"`
+	MaxY      float64 `json:"maxY"`
+	WorstRSSI float64 `json:"worstRssi"`
+}
+
+// CellCoverage is the strongest and the second strongest access point of a cell at (X; Y) meters, nil when not heard
+type CellCoverage struct {
+	X      float64   `json:"x"`
+	Y      float64   `json:"y"`
+	First  *APSignal `json:"first"`
+	Second *APSignal `json:"second"`
+}
+
+// APSignal is the RSSI in dBm of an access point
+type APSignal struct {
+	ID   uuid.UUID `json:"id"`
+	RSSI float64   `json:"rssi"`
+}
+
+// NewCoverageReport computes the coverage statistics of the band from the rows of a stored coverage matrix.
+// Signals at or below invisible dBm are not heard. Holes smaller than minHoleArea square meters are left out.
+func NewCoverageReport(m *db.Matrix, rows []*db.MatrixRow, band Band, thresholds []float64, minHoleArea float64, invisible float64) *CoverageReport {
+	indent := int(Ceil(AREA_INDENT_METER / m.CellSize))
+	minX, minY := max(m.MinX, 0), max(m.MinY, 0)
+	maxX, maxY := m.MaxX-indent, m.MaxY-indent
+	width, height := max(maxX-minX+1, 0), max(maxY-minY+1, 0)
+	cellArea := m.CellSize * m.CellSize
+
+	r := &CoverageReport{
+		Band:     band,
+		CellSize: m.CellSize,
+		Area:     float64(width*height) * cellArea,
+		Cells:    make([]CellCoverage, width*height),
+	}
+	for i := range r.Cells {
+		r.Cells[i].X = float64(minX+i%width) * m.CellSize
+		r.Cells[i].Y = float64(minY+i/width) * m.CellSize
+	}
+	for _, row := range rows {
+		x, y := int(Round(row.X/m.CellSize))-minX, int(Round(row.Y/m.CellSize))-minY
+		rssi := MatrixRowRSSI(row, band)
+		if x < 0 || y < 0 || x >= width || y >= height || rssi <= invisible {
+			continue
+		}
+		cell := &r.Cells[y*width+x]
+		signal := &APSignal{ID: row.SensorID, RSSI: rssi}
+		switch {
+		case cell.First == nil || rssi > cell.First.RSSI:
+			cell.First, cell.Second = signal, cell.First
+		case cell.Second == nil || rssi > cell.Second.RSSI:
+			cell.Second = signal
+		}
+	}
+
+	best := make([]float64, len(r.Cells))
+	for i, cell := range r.Cells {
+		best[i] = invisible
+		if cell.First != nil {
+			best[i] = cell.First.RSSI
+		}
+	}
+	for _, threshold := range thresholds {
+		t := ThresholdCoverage{RSSI: threshold, Holes: []CoverageHole{}}
+		var covered int
+		for _, rssi := range best {
+			if rssi >= threshold {
+				covered++
+			}
+		}
+		t.Area = float64(covered) * cellArea
+		if len(best) > 0 {
+			t.Percent = float64(covered) * 100 / float64(len(best))
+		}
+		for _, hole := range coverageHoles(best, width, threshold) {
+			hole.Area = float64(hole.cells) * cellArea
+			if hole.Area < minHoleArea {
+				continue
+			}
+			t.Holes = append(t.Holes, hole.inMeters(minX, minY, m.CellSize))
+		}
+		slices.SortFunc(t.Holes, func(a, b CoverageHole) int { return cmp.Compare(b.Area, a.Area) })
+		r.Thresholds = append(r.Thresholds, t)
+	}
+	return r
+}
+
+// cellHole is a coverage hole with the number of its cells
+type cellHole struct {
+	CoverageHole
+	cells int
+}
+
+// inMeters returns the hole with its centroid and bounds in meters
+func (h cellHole) inMeters(minX, minY int, cellSize float64) CoverageHole {
+	hole := h.CoverageHole
+	hole.X = (hole.X/float64(h.cells) + float64(minX)) * cellSize
+	hole.Y = (hole.Y/float64(h.cells) + float64(minY)) * cellSize
+	hole.MinX = (hole.MinX + float64(minX)) * cellSize
+	hole.MinY = (hole.MinY + float64(minY)) * cellSize
+	hole.MaxX = (hole.MaxX + float64(minX)) * cellSize
+	hole.MaxY = (hole.MaxY + float64(minY)) * cellSize
+	return hole
+}
+
+// coverageHoles finds the areas of side-adjacent cells of the grid below the threshold.
+// Coordinates of the holes are grid indexes, X and Y are the sums of the cell coordinates.
+func coverageHoles(best []float64, width int, threshold float64) (holes []cellHole) {
+	visited := make([]bool, len(best))
+	var stack []int
+	for start := range best {
+		if visited[start] || best[start] >= threshold {
+			continue
+		}
+		h := cellHole{CoverageHole: CoverageHole{MinX: Inf(1), MinY: Inf(1), MaxX: Inf(-1), MaxY: Inf(-1), WorstRSSI: Inf(1)}}
+		visited[start] = true
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			h.cells++
+			x, y := float64(i%width), float64(i/width)
+			h.X, h.Y = h.X+x, h.Y+y
+			h.MinX, h.MaxX = Min(h.MinX, x), Max(h.MaxX, x)
+			h.MinY, h.MaxY = Min(h.MinY, y), Max(h.MaxY, y)
+			h.WorstRSSI = Min(h.WorstRSSI, best[i])
+
+			for _, n := range [4]int{i - width, i + width, i - 1, i + 1} {
+				if n < 0 || n >= len(best) || visited[n] || best[n] >= threshold {
+					continue
+				}
+				// Left and right neighbors are in the same row
+				if (n == i-1 || n == i+1) && n/width != i/width {
+					continue
+				}
+				visited[n] = true
+				stack = append(stack, n)
+			}
+		}
+		holes = append(holes, h)
+	}
+	return
+}
@@ -0,0 +1,65 @@
+package location
+
+import (
+	"location-backend/internal/db"
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCoverageReport(t *testing.T) {
+	// 10 x 4 cells of 1 m, the access point a covers the left half at -60 dBm and b the right half at -72 dBm
+	// but for a hole of 2 x 2 cells at -90 dBm, each heard at -80 dBm on the other half
+	m := &db.Matrix{CellSize: 1, MinX: 0, MinY: 0, MaxX: 9, MaxY: 3}
+	a, b := uuid.New(), uuid.New()
+	var rows []*db.MatrixRow
+	for y := 0; y <= 3; y++ {
+		for x := 0; x <= 9; x++ {
+			rssiA, rssiB := -80.0, -72.0
+			if x < 5 {
+				rssiA, rssiB = -60, -80
+			}
+			if (x == 7 || x == 8) && y >= 2 {
+				rssiB = -90
+			}
+			rows = append(rows,
+				&db.MatrixRow{SensorID: a, RSSI5: rssiA, X: float64(x), Y: float64(y)},
+				&db.MatrixRow{SensorID: b, RSSI5: rssiB, X: float64(x), Y: float64(y)},
+			)
+		}
+	}
+
+	r := NewCoverageReport(m, rows, Band5, []float64{-67, -75}, 1, RSSI_INVISIBLE)
+	if r.Area != 40 {
+		t.Errorf("floor area is %v m², want 40 m²", r.Area)
+	}
+	strong, weak := r.Thresholds[0], r.Thresholds[1]
+	if strong.Percent != 50 || weak.Percent != 90 {
+		t.Errorf("coverage is %v%% at -67 dBm and %v%% at -75 dBm, want 50%% and 90%%", strong.Percent, weak.Percent)
+	}
+	if len(strong.Holes) != 1 || strong.Holes[0].Area != 20 {
+		t.Fatalf("holes at -67 dBm are %+v, want the right half", strong.Holes)
+	}
+	if len(weak.Holes) != 1 {
+		t.Fatalf("holes at -75 dBm are %+v, want one", weak.Holes)
+	}
+	hole := weak.Holes[0]
+	if hole.Area != 4 || hole.MinX != 7 || hole.MaxX != 8 || hole.MinY != 2 || hole.MaxY != 3 || hole.WorstRSSI != -80 {
+		t.Errorf("hole at -75 dBm is %+v, want 4 m² from (7; 2) to (8; 3) at -80 dBm", hole)
+	}
+	if math.Abs(hole.X-7.5) > 1e-9 || math.Abs(hole.Y-2.5) > 1e-9 {
+		t.Errorf("hole centroid is (%v; %v), want (7.5; 2.5)", hole.X, hole.Y)
+	}
+	if holes := NewCoverageReport(m, rows, Band5, []float64{-75}, 5, RSSI_INVISIBLE).Thresholds[0].Holes; len(holes) != 0 {
+		t.Errorf("a hole smaller than the minimum area is reported")
+	}
+
+	cell := r.Cells[1*10+2]
+	if cell.First == nil || cell.First.ID != a || cell.Second == nil || cell.Second.ID != b || cell.Second.RSSI != -80 {
+		t.Errorf("cell (2; 1) is %+v, want a then b", cell)
+	}
+	if cell := r.Cells[3*10+8]; cell.First == nil || cell.First.ID != a || cell.Second.ID != b {
+		t.Errorf("cell (8; 3) in the hole is %+v, want a then b", cell)
+	}
+}
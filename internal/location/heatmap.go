@@ -0,0 +1,270 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"location-backend/internal/db"
+	. "math"
+	"strconv"
+	"strings"
+)
+
+// Band is a Wi-Fi frequency band
+type Band int
+
+const (
+	Band24 Band = 24
+	Band5  Band = 5
+	Band6  Band = 6
+)
+
+// String returns the band as ParseBand reads it
+func (b Band) String() string {
+	if b == Band24 {
+		return "2.4"
+	}
+	return strconv.Itoa(int(b))
+}
+
+// ParseBand parses a band given as "2.4", "24", "5" or "6" (an optional "GHz" suffix is ignored)
+func ParseBand(s string) (Band, error) {
+	switch strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "ghz") {
+	case "2.4", "24":
+		return Band24, nil
+	case "5":
+		return Band5, nil
+	case "6":
+		return Band6, nil
+	}
+	return 0, fmt.Errorf("unknown band %q", s)
+}
+
+// rssi returns the RSSI of the matrix point for the band
+func (p MatrixPoint) rssi(band Band) float64 {
+	switch band {
+	case Band24:
+		return p.rssi24
+	case Band5:
+		return p.rssi5
+	case Band6:
+		return p.rssi6
+	}
+	return RSSI_INVISIBLE
+}
+
+// Heatmap is a predicted coverage grid of a floor. RSSI[y][x] holds the strongest RSSI in dBm
+// received in the cell from any emitter, cell (0; 0) starts at (MinX; MinY) cells of the floor plan.
+// A heatmap of another Layer of the interference map holds the values of the layer instead.
+type Heatmap struct {
+	Band     Band        `json:"band"`
+	Layer    string      `json:"layer,omitempty"`
+	CellSize float64     `json:"cellSize"`
+	MinX     int         `json:"minX"`
+	MinY     int         `json:"minY"`
+	Width    int         `json:"width"`
+	Height   int         `json:"height"`
+	RSSI     [][]float64 `json:"rssi"`
+}
+
+// NewHeatmap creates a heatmap covering cells from (minX; minY) to (maxX; maxY) inclusive
+func NewHeatmap(band Band, cellSize float64, minX, minY, maxX, maxY int) *Heatmap {
+	hm := &Heatmap{
+		Band:     band,
+		CellSize: cellSize,
+		MinX:     minX,
+		MinY:     minY,
+		Width:    maxX - minX + 1,
+		Height:   maxY - minY + 1,
+	}
+	hm.RSSI = make([][]float64, hm.Height)
+	for y := range hm.RSSI {
+		hm.RSSI[y] = make([]float64, hm.Width)
+		for x := range hm.RSSI[y] {
+			hm.RSSI[y][x] = RSSI_INVISIBLE
+		}
+	}
+	return hm
+}
+
+// Add keeps the strongest RSSI of the cell
+func (hm *Heatmap) Add(x, y int, rssi float64) {
+	x, y = x-hm.MinX, y-hm.MinY
+	if x < 0 || y < 0 || x >= hm.Width || y >= hm.Height {
+		return
+	}
+	if rssi > hm.RSSI[y][x] {
+		hm.RSSI[y][x] = rssi
+	}
+}
+
+// BuildHeatmap runs the matrix generator and reduces its rows to the strongest RSSI per cell
+func BuildHeatmap(inputData InputData, band Band) *Heatmap {
+	hm := NewHeatmap(band, inputData.cell_size_meters, inputData.minX, inputData.minY, inputData.maxX, inputData.maxY)
+	for point := range GenerateMatrixRow(inputData) {
+		hm.Add(point.x, point.y, point.rssi(band))
+	}
+	return hm
+}
+
+// NewFloorInputData builds the generator input for a floor coverage map.
+// Access points of the floor act as emitters radiating the channels and power of their radios (see RadiosEmission),
+// mounted with their antennas (see AccessPointAntenna), and the floor walls attenuate their signal.
+// The correction factors of an access point are added to its signal as those of a sensor.
+// Access points of Floor.AdjacentFloors leak through the slabs between the floors.
+// Coordinates of access points and walls are image pixels, Floor.Scale is the number of pixels per meter.
+// The grid covers widthPx x heightPx pixels of the floor plan, if the size is unknown (zero)
+// it is derived from the floor geometry.
+func NewFloorInputData(f *db.Floor, cellSizeMeters float64, widthPx, heightPx int) (inputData InputData, err error) {
+	var emitters []db.Sensor
+	var emissions []Emission
+	var antennas []Antenna
+	for _, ap := range f.AccessPoints {
+		if ap.X == nil || ap.Y == nil {
+			continue
+		}
+		var z float64
+		if ap.Z != nil {
+			z = *ap.Z
+		}
+		emitters = append(emitters, accessPointEmitter(ap, float64(*ap.X), float64(*ap.Y), z))
+		emissions = append(emissions, RadiosEmission(ap.Radios))
+		antennas = append(antennas, AccessPointAntenna(ap))
+	}
+	inputData, err = newFloorInputData(f, emitters, cellSizeMeters, widthPx, heightPx)
+	inputData.emissions = emissions
+	inputData.antennas = antennas
+	inputData.slabs = make([]Slab, len(emitters))
+	inputData.addAdjacentEmitters(f)
+	return
+}
+
+// NewFloorSensorsInputData builds the generator input for the positioning matrix of a floor.
+// Sensors of the floor receive the signal of a client placed in every cell, the grid is the same
+// as for NewFloorInputData.
+func NewFloorSensorsInputData(f *db.Floor, cellSizeMeters float64, widthPx, heightPx int) (inputData InputData, err error) {
+	var receivers []db.Sensor
+	for _, s := range f.Sensors {
+		receivers = append(receivers, *s)
+	}
+	return newFloorInputData(f, receivers, cellSizeMeters, widthPx, heightPx)
+}
+
+// newFloorInputData converts sensors, floor walls and obstacles from image pixels to cells and builds the grid around them
+func newFloorInputData(f *db.Floor, sensors []db.Sensor, cellSizeMeters float64, widthPx, heightPx int) (inputData InputData, err error) {
+	if f.Scale == nil || *f.Scale <= 0 {
+		return inputData, errors.New("floor scale is not set")
+	}
+	if cellSizeMeters < MIN_CELL_SIZE_METER {
+		return inputData, fmt.Errorf("cell size must be at least %v m", MIN_CELL_SIZE_METER)
+	}
+	// Pixels to cells
+	k := 1 / (*f.Scale * cellSizeMeters)
+
+	for i := range sensors {
+		widthPx = max(widthPx, int(Ceil(sensors[i].X)))
+		heightPx = max(heightPx, int(Ceil(sensors[i].Y)))
+		sensors[i].X *= k
+		sensors[i].Y *= k
+	}
+
+	var walls []Wall
+	for _, w := range f.Walls {
+		if w.X1 == nil || w.Y1 == nil || w.X2 == nil || w.Y2 == nil || w.WallType == nil {
+			continue
+		}
+		wt := w.WallType
+		if wt.Thickness == nil || wt.Attenuation24 == nil || wt.Attenuation5 == nil || wt.Attenuation6 == nil {
+			continue
+		}
+		// A wall without a top reaches the ceiling
+		var bottom float64
+		top := FloorHeight(f)
+		if w.ZBottom != nil {
+			bottom = *w.ZBottom
+		}
+		if w.ZTop != nil {
+			top = *w.ZTop
+		}
+		walls = append(walls, Wall{
+			ID:            w.ID,
+			X1:            float64(*w.X1) * k,
+			Y1:            float64(*w.Y1) * k,
+			X2:            float64(*w.X2) * k,
+			Y2:            float64(*w.Y2) * k,
+			Bottom:        bottom,
+			Top:           top,
+			Thickness:     *wt.Thickness,
+			Attenuation24: *wt.Attenuation24,
+			Attenuation5:  *wt.Attenuation5,
+			Attenuation6:  *wt.Attenuation6,
+		})
+		widthPx = max(widthPx, *w.X1, *w.X2)
+		heightPx = max(heightPx, *w.Y1, *w.Y2)
+	}
+
+	var obstacles []Obstacle
+	for _, o := range f.Obstacles {
+		if len(o.Points) < 3 || o.Attenuation24 == nil || o.Attenuation5 == nil || o.Attenuation6 == nil {
+			continue
+		}
+		obstacle := Obstacle{
+			ID:            o.ID,
+			Attenuation24: *o.Attenuation24,
+			Attenuation5:  *o.Attenuation5,
+			Attenuation6:  *o.Attenuation6,
+			Top:           FloorHeight(f),
+		}
+		if o.ZBottom != nil {
+			obstacle.Bottom = *o.ZBottom
+		}
+		if o.ZTop != nil {
+			obstacle.Top = *o.ZTop
+		}
+		for _, pt := range o.Points {
+			obstacle.Points = append(obstacle.Points, XYcoordinate{x: pt.X * k, y: pt.Y * k})
+			widthPx = max(widthPx, int(Ceil(pt.X)))
+			heightPx = max(heightPx, int(Ceil(pt.Y)))
+		}
+		obstacles = append(obstacles, obstacle)
+	}
+
+	indent := int(Ceil(AREA_INDENT_METER / cellSizeMeters))
+	inputData = InputData{
+		client:           Client{trSignalPower: int(EIRP), trAntGain: 0, zM: CLIENT_HEIGHT_METER},
+		walls:            walls,
+		obstacles:        obstacles,
+		sensors:          sensors,
+		cell_size_meters: cellSizeMeters,
+		minX:             -indent,
+		minY:             -indent,
+		maxX:             int(Ceil(float64(widthPx)*k)) + indent,
+		maxY:             int(Ceil(float64(heightPx)*k)) + indent,
+		revision:         f.GeometryRevision,
+	}
+	if cells := inputData.Cells(); cells > MAX_MATRIX_CELLS {
+		return inputData, fmt.Errorf("matrix of %d cells exceeds the limit of %d cells, increase the cell size", cells, MAX_MATRIX_CELLS)
+	}
+	return
+}
+
+// Cells returns the number of grid cells of the input
+func (inputData InputData) Cells() int {
+	return (inputData.maxX - inputData.minX + 1) * (inputData.maxY - inputData.minY + 1)
+}
+
+// Sample returns the RSSI at fractional floor cell coordinates, interpolated bilinearly between cell centers
+func (hm *Heatmap) Sample(x, y float64) float64 {
+	x, y = x-float64(hm.MinX), y-float64(hm.MinY)
+	if hm.Width == 0 || hm.Height == 0 || x < -0.5 || y < -0.5 || x > float64(hm.Width)-0.5 || y > float64(hm.Height)-0.5 {
+		return RSSI_INVISIBLE
+	}
+	x = Max(0, Min(x, float64(hm.Width-1)))
+	y = Max(0, Min(y, float64(hm.Height-1)))
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, hm.Width-1), min(y0+1, hm.Height-1)
+	tx, ty := x-float64(x0), y-float64(y0)
+
+	top := hm.RSSI[y0][x0]*(1-tx) + hm.RSSI[y0][x1]*tx
+	bottom := hm.RSSI[y1][x0]*(1-tx) + hm.RSSI[y1][x1]*tx
+	return top*(1-ty) + bottom*ty
+}
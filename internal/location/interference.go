@@ -0,0 +1,307 @@
+package location
+
+import (
+	"cmp"
+	"fmt"
+	"location-backend/internal/db"
+	. "math"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// Heatmap layers of the interference map
+const (
+	LayerRSSI         = "rssi"         // RSSI of the serving access point, dBm
+	LayerInterference = "interference" // Sum of the interference, dBm
+	LayerSINR         = "sinr"         // dB
+	LayerMCS          = "mcs"          // MCS index
+	LayerRate         = "rate"         // Mbps
+)
+
+// heMCS is the minimum SINR in dB and the data rate in Mbps of 802.11ax MCS indexes
+// for one spatial stream of 20 MHz with the guard interval of 800 ns
+var heMCS = []struct {
+	sinr float64
+	rate float64
+}{
+	{2, 8.6}, {5, 17.2}, {9, 25.8}, {11, 34.4}, {15, 51.6}, {18, 68.8},
+	{20, 77.4}, {25, 86}, {29, 103.2}, {31, 114.7}, {34, 129}, {37, 143.4},
+}
+
+// heDataSubcarriers is the number of data subcarriers of 802.11ax channels by width in MHz
+var heDataSubcarriers = map[float64]float64{20: 234, 40: 468, 80: 980, 160: 1960}
+
+// overlap returns the share of the power of the interferer falling into the channel c.
+// Overlapping channels share the power by the width in common, the next channel without overlap
+// leaks through the transmit spectral mask with ADJACENT_CHANNEL_REJECTION.
+func (c RadioChannel) overlap(interferer RadioChannel) (share float64, adjacent bool) {
+	if c.Band != interferer.Band || c.Width <= 0 || interferer.Width <= 0 {
+		return 0, false
+	}
+	low := Max(c.Frequency-c.Width/2, interferer.Frequency-interferer.Width/2)
+	high := Min(c.Frequency+c.Width/2, interferer.Frequency+interferer.Width/2)
+	if high > low {
+		return (high - low) / interferer.Width, false
+	}
+	if low-high < interferer.Width {
+		return Min(c.Width, interferer.Width) / interferer.Width * Pow(10, -ADJACENT_CHANNEL_REJECTION/10), true
+	}
+	return 0, false
+}
+
+// noise returns the thermal noise in dBm of the channel at the client
+func (c RadioChannel) noise() float64 {
+	return THERMAL_NOISE_DBM_PER_HZ + 10*Log10(c.Width*1e6) + NOISE_FIGURE
+}
+
+// rate returns the MCS index and the data rate in Mbps of one spatial stream at the SINR, -1 and zero when
+// the SINR is below MCS 0
+func (c RadioChannel) rate(sinr float64) (mcs int, rate float64) {
+	mcs = -1
+	for i, m := range heMCS {
+		if sinr >= m.sinr {
+			mcs, rate = i, m.rate
+		}
+	}
+	if mcs < 0 {
+		return
+	}
+	subcarriers, ok := heDataSubcarriers[c.Width]
+	if !ok {
+		subcarriers = heDataSubcarriers[20]
+	}
+	// Symbols of 12.8 us plus the guard interval
+	gi := 0.8
+	if c.GuardInterval > 0 {
+		gi = float64(c.GuardInterval) / 1000
+	}
+	rate *= subcarriers / heDataSubcarriers[20] * (12.8 + 0.8) / (12.8 + gi)
+	return mcs, Round(rate*10) / 10
+}
+
+// FloorChannels returns the channel of every radio of the access points of the floor and its adjacent floors
+// keyed by radio, see AccessPointChannels
+func FloorChannels(f *db.Floor) map[uuid.UUID]RadioChannel {
+	channels := make(map[uuid.UUID]RadioChannel)
+	for _, floor := range append([]*db.Floor{f}, f.AdjacentFloors...) {
+		for _, ap := range floor.AccessPoints {
+			for _, c := range AccessPointChannels(ap) {
+				channels[c.RadioID] = c
+			}
+		}
+	}
+	return channels
+}
+
+// bandRadios groups the channels of the radios of the band by access point, the strongest radio first.
+// A matrix row holds the signal of the strongest radio, see RadiosEmission, the signal of another radio of the band
+// is the row RSSI plus its power minus the power of the first one.
+func bandRadios(channels map[uuid.UUID]RadioChannel, band Band) map[uuid.UUID][]RadioChannel {
+	radios := make(map[uuid.UUID][]RadioChannel)
+	for _, c := range channels {
+		if c.Band == band {
+			radios[c.AccessPointID] = append(radios[c.AccessPointID], c)
+		}
+	}
+	for _, apRadios := range radios {
+		slices.SortFunc(apRadios, func(a, b RadioChannel) int {
+			if c := cmp.Compare(b.Power, a.Power); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.RadioID.String(), b.RadioID.String())
+		})
+	}
+	return radios
+}
+
+// CellInterference is the signal of the radio serving a cell, the strongest one, and the interference
+// of the other radios
+type CellInterference struct {
+	Serving      uuid.UUID // Access point of the serving radio
+	RSSI         float64   // dBm
+	Interference float64   // dBm, minus infinity without interferers
+	SINR         float64   // dB
+	MCS          int       // -1 when the SINR is too low
+	Rate         float64   // Mbps of one spatial stream
+}
+
+// RadioPair is the interference between two radios on overlapping or adjacent channels of a band,
+// the radios of an access point radiating the same band make pairs too
+type RadioPair struct {
+	A        uuid.UUID `json:"a"` // Access points
+	B        uuid.UUID `json:"b"`
+	RadioA   uuid.UUID `json:"radioA"`
+	RadioB   uuid.UUID `json:"radioB"`
+	ChannelA int       `json:"channelA"`
+	ChannelB int       `json:"channelB"`
+	WidthA   float64   `json:"widthA"` // MHz
+	WidthB   float64   `json:"widthB"`
+	Overlap  float64   `json:"overlap"` // Largest share of the power of one radio in the channel of the other
+	Adjacent bool      `json:"adjacent"`
+	// Area in square meters where one access point serves and the other interferes at INTERFERENCE_DETECT_RSSI or more
+	Area              float64 `json:"area"`
+	WorstInterference float64 `json:"worstInterference"` // Strongest interference in dBm of one in the area of the other
+}
+
+// InterferenceMap is the co-channel and adjacent channel interference of a floor in a band.
+// Cells[y][x] is nil when no access point is heard, cell (0; 0) starts at (MinX; MinY) cells of the floor plan.
+type InterferenceMap struct {
+	Band     Band
+	CellSize float64
+	MinX     int
+	MinY     int
+	Width    int
+	Height   int
+	Cells    [][]*CellInterference
+	Pairs    []RadioPair // Sorted by the area, the largest first
+}
+
+// cellSignal is the RSSI of a radio in a cell
+type cellSignal struct {
+	radio RadioChannel
+	rssi  float64
+}
+
+// NewInterferenceMap computes the interference of the band from the rows of a stored coverage matrix.
+// channels are the channels of the radios, see FloorChannels, every radio of the band is heard.
+// Signals at or below invisible dBm are not heard.
+func NewInterferenceMap(m *db.Matrix, rows []*db.MatrixRow, band Band, channels map[uuid.UUID]RadioChannel, invisible float64) *InterferenceMap {
+	im := &InterferenceMap{
+		Band:     band,
+		CellSize: m.CellSize,
+		MinX:     m.MinX,
+		MinY:     m.MinY,
+		Width:    m.MaxX - m.MinX + 1,
+		Height:   m.MaxY - m.MinY + 1,
+	}
+	radios := bandRadios(channels, band)
+	signals := make([][]cellSignal, im.Width*im.Height)
+	for _, row := range rows {
+		x, y := int(Round(row.X/m.CellSize))-m.MinX, int(Round(row.Y/m.CellSize))-m.MinY
+		apRadios := radios[row.SensorID]
+		if x < 0 || y < 0 || x >= im.Width || y >= im.Height || len(apRadios) == 0 {
+			continue
+		}
+		rssi := MatrixRowRSSI(row, band)
+		for _, c := range apRadios {
+			if radioRSSI := rssi + c.Power - apRadios[0].Power; radioRSSI > invisible {
+				signals[y*im.Width+x] = append(signals[y*im.Width+x], cellSignal{radio: c, rssi: radioRSSI})
+			}
+		}
+	}
+
+	pairs := make(map[[2]uuid.UUID]*RadioPair)
+	cellArea := m.CellSize * m.CellSize
+	im.Cells = make([][]*CellInterference, im.Height)
+	for y := range im.Cells {
+		im.Cells[y] = make([]*CellInterference, im.Width)
+		for x := range im.Cells[y] {
+			heard := signals[y*im.Width+x]
+			if len(heard) == 0 {
+				continue
+			}
+			serving := slices.MaxFunc(heard, func(a, b cellSignal) int { return cmp.Compare(a.rssi, b.rssi) })
+			servingChannel := serving.radio
+
+			var interference float64 // mW
+			for _, s := range heard {
+				if s.radio.RadioID == servingChannel.RadioID {
+					continue
+				}
+				share, _ := servingChannel.overlap(s.radio)
+				if share == 0 {
+					continue
+				}
+				power := s.rssi + 10*Log10(share)
+				interference += Pow(10, power/10)
+				if power < INTERFERENCE_DETECT_RSSI {
+					continue
+				}
+				pair := pairOf(pairs, servingChannel, s.radio)
+				pair.Area += cellArea
+				pair.WorstInterference = Max(pair.WorstInterference, power)
+			}
+
+			cell := &CellInterference{Serving: servingChannel.AccessPointID, RSSI: serving.rssi, Interference: 10 * Log10(interference)}
+			cell.SINR = serving.rssi - 10*Log10(interference+Pow(10, servingChannel.noise()/10))
+			cell.MCS, cell.Rate = servingChannel.rate(cell.SINR)
+			im.Cells[y][x] = cell
+		}
+	}
+
+	im.Pairs = make([]RadioPair, 0, len(pairs))
+	for _, pair := range pairs {
+		im.Pairs = append(im.Pairs, *pair)
+	}
+	slices.SortFunc(im.Pairs, func(a, b RadioPair) int {
+		if c := cmp.Compare(b.Area, a.Area); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.WorstInterference, a.WorstInterference)
+	})
+	return im
+}
+
+// pairOf returns the pair of the radios, created on the first interference between them
+func pairOf(pairs map[[2]uuid.UUID]*RadioPair, ca, cb RadioChannel) *RadioPair {
+	if ca.RadioID.String() > cb.RadioID.String() {
+		ca, cb = cb, ca
+	}
+	key := [2]uuid.UUID{ca.RadioID, cb.RadioID}
+	if pair, ok := pairs[key]; ok {
+		return pair
+	}
+	shareA, adjacent := ca.overlap(cb)
+	shareB, _ := cb.overlap(ca)
+	pair := &RadioPair{
+		A: ca.AccessPointID, B: cb.AccessPointID,
+		RadioA: ca.RadioID, RadioB: cb.RadioID,
+		ChannelA: ca.Channel, ChannelB: cb.Channel,
+		WidthA: ca.Width, WidthB: cb.Width,
+		Overlap:           Max(shareA, shareB),
+		Adjacent:          adjacent,
+		WorstInterference: Inf(-1),
+	}
+	pairs[key] = pair
+	return pair
+}
+
+// layerValues reads the value of every layer from a cell
+var layerValues = map[string]func(c *CellInterference) float64{
+	LayerRSSI:         func(c *CellInterference) float64 { return c.RSSI },
+	LayerInterference: func(c *CellInterference) float64 { return c.Interference },
+	LayerSINR:         func(c *CellInterference) float64 { return Round(c.SINR*10) / 10 },
+	LayerMCS:          func(c *CellInterference) float64 { return float64(c.MCS) },
+	LayerRate:         func(c *CellInterference) float64 { return c.Rate },
+}
+
+// ValidateLayer checks the name of a heatmap layer, see the Layer constants
+func ValidateLayer(layer string) error {
+	if layerValues[layer] == nil {
+		return fmt.Errorf("unknown layer %q", layer)
+	}
+	return nil
+}
+
+// Layer returns a layer of the map as a heatmap, see the Layer constants.
+// Cells without a serving access point and without interference hold RSSI_INVISIBLE.
+func (im *InterferenceMap) Layer(layer string) (*Heatmap, error) {
+	if err := ValidateLayer(layer); err != nil {
+		return nil, err
+	}
+	value := layerValues[layer]
+	hm := NewHeatmap(im.Band, im.CellSize, im.MinX, im.MinY, im.MinX+im.Width-1, im.MinY+im.Height-1)
+	hm.Layer = layer
+	for y, row := range im.Cells {
+		for x, cell := range row {
+			if cell == nil {
+				continue
+			}
+			if v := value(cell); !IsInf(v, 0) {
+				hm.RSSI[y][x] = v
+			}
+		}
+	}
+	return hm, nil
+}
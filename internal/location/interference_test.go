@@ -0,0 +1,148 @@
+package location
+
+import (
+	"location-backend/internal/db"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func testChannel(t *testing.T, channel int, bandwidth string) RadioChannel {
+	t.Helper()
+	c, err := NewRadioChannel(&db.Radio{ID: uuid.New(), Channel: ptr(channel), Power: ptr(20), Bandwidth: ptr(bandwidth)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// testChannels keys the channels by radio, see FloorChannels
+func testChannels(aps map[uuid.UUID][]RadioChannel) map[uuid.UUID]RadioChannel {
+	channels := make(map[uuid.UUID]RadioChannel)
+	for ap, radios := range aps {
+		for _, c := range radios {
+			c.AccessPointID = ap
+			channels[c.RadioID] = c
+		}
+	}
+	return channels
+}
+
+func TestChannelOverlap(t *testing.T) {
+	acr := math.Pow(10, -ADJACENT_CHANNEL_REJECTION/10)
+	cases := []struct {
+		name       string
+		victim     RadioChannel
+		interferer RadioChannel
+		share      float64
+		adjacent   bool
+	}{
+		{"co-channel", testChannel(t, 36, "20"), testChannel(t, 36, "20"), 1, false},
+		{"80 MHz over 20 MHz", testChannel(t, 40, "20"), testChannel(t, 36, "80MHz"), 0.25, false},
+		{"20 MHz inside 80 MHz", testChannel(t, 36, "80"), testChannel(t, 44, "20"), 1, false},
+		{"40 MHz halves", testChannel(t, 44, "40"), testChannel(t, 40, "40"), acr, true}, // Blocks 36-40 and 44-48 touch
+		{"adjacent 5 GHz", testChannel(t, 36, "20"), testChannel(t, 40, "20"), acr, true},
+		{"far 5 GHz", testChannel(t, 36, "20"), testChannel(t, 48, "20"), 0, false},
+		{"2.4 GHz 1 and 3", testChannel(t, 1, "20"), testChannel(t, 3, "20"), 0.5, false},
+		{"2.4 GHz 1 and 6", testChannel(t, 1, "20"), testChannel(t, 6, "20"), acr, true},
+		{"2.4 GHz 1 and 11", testChannel(t, 1, "20"), testChannel(t, 11, "20"), 0, false},
+	}
+	for _, c := range cases {
+		share, adjacent := c.victim.overlap(c.interferer)
+		if math.Abs(share-c.share) > 1e-12 || adjacent != c.adjacent {
+			t.Errorf("%s: share %v adjacent %v, want %v %v", c.name, share, adjacent, c.share, c.adjacent)
+		}
+	}
+}
+
+func TestInterferenceMap(t *testing.T) {
+	// Two access points on channel 36 hear each other in the middle of a 10 x 1 cells corridor,
+	// the third on channel 149 is heard everywhere at -72 dBm and never interferes
+	a, b, other := uuid.New(), uuid.New(), uuid.New()
+	channels := testChannels(map[uuid.UUID][]RadioChannel{
+		a:     {testChannel(t, 36, "20")},
+		b:     {testChannel(t, 36, "20")},
+		other: {testChannel(t, 149, "20")},
+	})
+	m := &db.Matrix{CellSize: 1, MaxX: 9, MaxY: 0}
+	var rows []*db.MatrixRow
+	for x := 0; x <= 9; x++ {
+		rows = append(rows,
+			&db.MatrixRow{SensorID: a, RSSI5: -50 - 5*float64(x), X: float64(x)},
+			&db.MatrixRow{SensorID: b, RSSI5: -95 + 5*float64(x), X: float64(x)},
+			&db.MatrixRow{SensorID: other, RSSI5: -72, X: float64(x)},
+		)
+	}
+	im := NewInterferenceMap(m, rows, Band5, channels, RSSI_INVISIBLE)
+
+	// At x = 0 a serves at -50 dBm, b interferes at -95 dBm
+	cell := im.Cells[0][0]
+	noise := THERMAL_NOISE_DBM_PER_HZ + 10*math.Log10(20e6) + NOISE_FIGURE
+	want := -50 - 10*math.Log10(math.Pow(10, -9.5)+math.Pow(10, noise/10))
+	if cell.Serving != a || math.Abs(cell.SINR-want) > 1e-9 {
+		t.Errorf("cell 0 is served by %v at %v dB SINR, want a at %v dB", cell.Serving, cell.SINR, want)
+	}
+	if cell.MCS != 11 || cell.Rate != 143.4 {
+		t.Errorf("cell 0 has MCS %d at %v Mbps, want MCS 11 at 143.4 Mbps", cell.MCS, cell.Rate)
+	}
+	// In the middle b serves at -70 dBm and a interferes at -75 dBm
+	if mid := im.Cells[0][5]; mid.Serving != b || math.Abs(mid.SINR-5) > 0.1 || mid.MCS != 0 {
+		t.Errorf("cell 5 is %+v, want b with SINR about 5 dB at MCS 0", mid)
+	}
+
+	if len(im.Pairs) != 1 {
+		t.Fatalf("%d interfering pairs, want 1", len(im.Pairs))
+	}
+	// b reaches -82 dBm from x = 3 on, a down to x = 6
+	pair := im.Pairs[0]
+	if pair.Area != 4 || pair.Overlap != 1 || pair.Adjacent || pair.WorstInterference != -75 {
+		t.Errorf("pair is %+v, want 4 m² of co-channel interference up to -75 dBm", pair)
+	}
+
+	hm, err := im.Layer(LayerRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hm.RSSI[0][0] != 143.4 || hm.Layer != LayerRate {
+		t.Errorf("rate layer holds %v in cell 0, want 143.4 Mbps", hm.RSSI[0][0])
+	}
+	if _, err := im.Layer("snr"); err == nil {
+		t.Errorf("unknown layer is accepted")
+	}
+}
+
+func TestInterferenceMapRadios(t *testing.T) {
+	// a radiates 5 GHz from channel 36 at 20 dBm and from channel 149 at 17 dBm, b from channel 149 at 20 dBm.
+	// The second radio of a is 3 dB below the row of a and interferes where b serves.
+	a, b := uuid.New(), uuid.New()
+	second := testChannel(t, 149, "20")
+	second.Power = 17
+	channels := testChannels(map[uuid.UUID][]RadioChannel{
+		a: {testChannel(t, 36, "20"), second},
+		b: {testChannel(t, 149, "20")},
+	})
+	m := &db.Matrix{CellSize: 1, MaxX: 9, MaxY: 0}
+	var rows []*db.MatrixRow
+	for x := 0; x <= 9; x++ {
+		rows = append(rows,
+			&db.MatrixRow{SensorID: a, RSSI5: -50 - 5*float64(x), X: float64(x)},
+			&db.MatrixRow{SensorID: b, RSSI5: -95 + 5*float64(x), X: float64(x)},
+		)
+	}
+	im := NewInterferenceMap(m, rows, Band5, channels, RSSI_INVISIBLE)
+
+	if cell := im.Cells[0][0]; cell.Serving != a || cell.Interference > -200 {
+		t.Errorf("cell 0 is %+v, want a without co-channel interference", cell)
+	}
+	// At x = 5 b serves at -70 dBm and the second radio of a interferes at -78 dBm
+	if len(im.Pairs) != 1 {
+		t.Fatalf("%d interfering pairs, want 1", len(im.Pairs))
+	}
+	pair := im.Pairs[0]
+	radios := []uuid.UUID{pair.RadioA, pair.RadioB}
+	if !slices.Contains(radios, second.RadioID) || pair.Area != 1 || pair.WorstInterference != -78 {
+		t.Errorf("pair is %+v, want the second radio of a interfering on 1 m² at -78 dBm", pair)
+	}
+}
@@ -30,12 +30,12 @@ func _getMatrix(matrixRowGenerator chan MatrixPoint, mapId uuid.UUID) ([]PointRo
 
 		if lastId != id {
 			// pointRowsToInsert.push({ id: id, map_id: mapId, x: x_m, y: y_m });
-			pointRowsToInsert = append(pointRowsToInsert, PointRow{id: id, map_id: mapId, x: x_m, y: y_m})
+			pointRowsToInsert = append(pointRowsToInsert, PointRow{ID: id, MatrixID: mapId, X: x_m, Y: y_m})
 			lastId = id
 		}
 
 		// matrixRowsToInsert.push({ point_id: id, sensor_id: sensorId, rssi24: rssi24, rssi5: rssi5, rssi6: rssi6, distance: distance });
-		matrixRowsToInsert = append(matrixRowsToInsert, MatrixRow{point_id: id, sensor_id: sensorId, rssi24: rssi24, rssi5: rssi5, rssi6: rssi6, distance: distance})
+		matrixRowsToInsert = append(matrixRowsToInsert, MatrixRow{PointID: id, SensorID: sensorId, RSSI24: rssi24, RSSI5: rssi5, RSSI6: rssi6, Distance: distance, MatrixID: mapId})
 	}
 
 	return pointRowsToInsert, matrixRowsToInsert
@@ -78,10 +78,12 @@ func CreateMatrix(mapId uuid.UUID, inputData InputData) ([]PointRow, []MatrixRow
 
 type Wall struct {
 	ID            uuid.UUID
-	X1            int
-	Y1            int
-	X2            int
-	Y2            int
+	X1            float64
+	Y1            float64
+	X2            float64
+	Y2            float64
+	Bottom        float64 // Meters above the floor
+	Top           float64 // Meters above the floor, no limit when zero
 	Thickness     float64
 	Attenuation24 float64
 	Attenuation5  float64
@@ -91,7 +93,15 @@ type Wall struct {
 type InputData struct {
 	client           Client
 	walls            []Wall
+	obstacles        []Obstacle
 	sensors          []db.Sensor
+	emissions        []Emission // Signal of every sensor acting as an emitter, defaultEmission when nil
+	slabs            []Slab     // Slabs between every sensor and the floor, none when nil
+	antennas         []Antenna  // Antenna of every sensor, made of the sensor diagram and rotation when nil
+	model            PropagationModel
+	calibration      *Calibration // DefaultCalibration when nil
+	workers          int          // Number of generator workers, GOMAXPROCS when zero
+	revision         int64        // Geometry revision of the floor
 	cell_size_meters float64
 	minX             int
 	minY             int
@@ -99,18 +109,47 @@ type InputData struct {
 	maxY             int
 }
 
-type PointRow struct {
-	id     int
-	map_id uuid.UUID
-	x      float64
-	y      float64
+// CellSize returns the cell size of the grid of the input in meters
+func (inputData InputData) CellSize() float64 {
+	return inputData.cell_size_meters
 }
 
-type MatrixRow struct {
-	point_id  int
-	sensor_id uuid.UUID
-	rssi24    float64
-	rssi5     float64
-	rssi6     float64
-	distance  float64
+// Model returns the propagation model of the input, DefaultPropagationModel when it is not set
+func (inputData InputData) Model() PropagationModel {
+	if inputData.model == nil {
+		return DefaultPropagationModel
+	}
+	return inputData.model
+}
+
+// WithModel returns the input predicted with the propagation model
+func (inputData InputData) WithModel(model PropagationModel) InputData {
+	inputData.model = model
+	return inputData
+}
+
+// Calibration returns the calibration of the input, DefaultCalibration when it is not set
+func (inputData InputData) Calibration() Calibration {
+	if inputData.calibration == nil {
+		return DefaultCalibration
+	}
+	return *inputData.calibration
 }
+
+// WithCalibration returns the input predicted with the calibration
+func (inputData InputData) WithCalibration(cal Calibration) InputData {
+	inputData.calibration = &cal
+	return inputData
+}
+
+// WithWorkers returns the input generated by the number of workers, zero uses GOMAXPROCS workers
+func (inputData InputData) WithWorkers(workers int) InputData {
+	inputData.workers = workers
+	return inputData
+}
+
+// PointRow is a row of the points table
+type PointRow = db.Point
+
+// MatrixRow is a row of the matrix table
+type MatrixRow = db.MatrixRow
@@ -3,15 +3,14 @@
 package location
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"location-backend/internal/db"
 	. "math"
+	"runtime"
+	"sync"
 
 	"github.com/google/uuid"
-
 	// "location-backend/internal/logger"
-	"github.com/rs/zerolog/log"
 )
 
 // import logger from "../../../logger"
@@ -46,132 +45,342 @@ type Client struct {
 
 // type Generator chan MatrixPoint
 
+// GenerateMatrixRow predicts the RSSI of every sensor in every cell of the grid, see GenerateMatrixRowContext
 func GenerateMatrixRow(inputData InputData) chan MatrixPoint {
-	// var client, walls, sensors, cell_size_meters, minX, minY, maxX, maxY int = inputData;
-	var client Client = inputData.client
-	var walls []Wall = inputData.walls
-	var sensors []db.Sensor = inputData.sensors
-	var minX int = inputData.minX
-	var minY int = inputData.minY
-	var maxX int = inputData.maxX
-	var maxY int = inputData.maxY
-	var cell_size_meters float64 = inputData.cell_size_meters
-
-	var i int = 0
-
-	ch := make(chan MatrixPoint)
+	return GenerateMatrixRowContext(context.Background(), inputData)
+}
+
+// GenerateMatrixRowContext predicts the RSSI of every sensor in every cell of the grid.
+// The grid is split into tiles of MATRIX_TILE_ROWS rows computed by the workers of the input (see WithWorkers),
+// at most two tiles per worker are held ahead of the reader. Rows are sent in the order of a sequential scan:
+// cells row by row, sensors in the input order. The channel is closed early when the context is cancelled.
+func GenerateMatrixRowContext(ctx context.Context, inputData InputData) chan MatrixPoint {
+	g := newMatrixGenerator(inputData)
+	workers := inputData.workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	tilesCount := (g.maxY - g.minY + MATRIX_TILE_ROWS) / MATRIX_TILE_ROWS
+
+	ch := make(chan MatrixPoint, len(g.sensors))
 	go func() {
+		defer close(ch) // Close the channel when done
 
-		for y := minY; y < maxY+1; y++ {
-			for x := minX; x < maxX+1; x++ {
-				i++
-				var matrixWithPoint MatrixPoint = MatrixPoint{
-					id: i,
-					// sensorId: -1,
-					x:        x,
-					y:        y,
-					x_m:      float64(x) * cell_size_meters,
-					y_m:      float64(y) * cell_size_meters,
-					rssi24:   RSSI_INVISIBLE,
-					rssi5:    RSSI_INVISIBLE,
-					rssi6:    RSSI_INVISIBLE,
-					distance: DISTANCE_INVISIBLE,
+		// Every tile has its own buffered slot, so a worker never waits for the reader
+		results := make([]chan []MatrixPoint, tilesCount)
+		for t := range results {
+			results[t] = make(chan []MatrixPoint, 1)
+		}
+		window := make(chan struct{}, 2*workers)
+		tiles := make(chan int)
+
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for t := range tiles {
+					results[t] <- g.tile(ctx, t)
 				}
-
-				for _, sensor := range sensors {
-					var distance float64 = _getDistance(x, y, client, sensor, cell_size_meters)
-					var freeSpaceRSSI24, freeSpaceRSSI5, freeSpaceRSSI6 = _getFreeSpaceRSSI(x, y, client, sensor, distance)
-					var wallsLoss24 float64 = 0
-					var wallsLoss5 float64 = 0
-					var wallsLoss6 float64 = 0
-
-					if CALCULATE_WALLS {
-						if freeSpaceRSSI24 >= RSII_CUTOFF || freeSpaceRSSI5 >= RSII_CUTOFF || freeSpaceRSSI6 >= RSII_CUTOFF {
-							wallsLoss24, wallsLoss5, wallsLoss6 = _getWallsAttenuation(x, y, walls, sensor, client, cell_size_meters)
-						}
-					}
-
-					var tempRSSI24 float64 = freeSpaceRSSI24 + wallsLoss24 + CORRECTION_COEFFICIENT_24
-					// var rssi_24 float64 = (tempRSSI24 >= RSII_CUTOFF) ? Number(tempRSSI24.toFixed(1)) : RSSI_INVISIBLE;
-					var rssi_24 float64
-					var rssi_5 float64
-					var rssi_6 float64
-					if tempRSSI24 >= RSII_CUTOFF {
-						rssi_24 = Round(tempRSSI24*10) / 10 // округление до 1 знака
-					} else {
-						rssi_24 = RSSI_INVISIBLE
-					}
-
-					var tempRSSI5 float64 = freeSpaceRSSI5 + wallsLoss5 + CORRECTION_COEFFICIENT_5
-					// var rssi_5 float64 = (tempRSSI5 >= RSII_CUTOFF) ? Number(tempRSSI5.toFixed(1)) : RSSI_INVISIBLE;
-					if tempRSSI5 >= RSII_CUTOFF {
-						rssi_5 = Round(tempRSSI5*10) / 10
-					} else {
-						rssi_5 = RSSI_INVISIBLE
-					}
-
-					var tempRSSI6 float64 = freeSpaceRSSI6 + wallsLoss6 + CORRECTION_COEFFICIENT_6
-					// var rssi_6 float64 = (tempRSSI6 >= RSII_CUTOFF) ? Number(tempRSSI6.toFixed(1)) : RSSI_INVISIBLE;
-					if tempRSSI6 >= RSII_CUTOFF {
-						rssi_6 = Round(tempRSSI6*10) / 10
-					} else {
-						rssi_6 = RSSI_INVISIBLE
-					}
-
-					distance = Round(distance*10) / 10
-
-					matrixWithPoint.sensorId = sensor.ID
-					matrixWithPoint.rssi24 = rssi_24
-					matrixWithPoint.rssi5 = rssi_5
-					matrixWithPoint.rssi6 = rssi_6
-					matrixWithPoint.distance = distance
-
-					ch <- matrixWithPoint
+			}()
+		}
+		defer wg.Wait()
+
+		go func() {
+			defer close(tiles)
+			for t := 0; t < tilesCount; t++ {
+				select {
+				case window <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				select {
+				case tiles <- t:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		for t := 0; t < tilesCount; t++ {
+			var rows []MatrixPoint
+			select {
+			case rows = <-results[t]:
+			case <-ctx.Done():
+				return
+			}
+			<-window
+			for _, row := range rows {
+				select {
+				case ch <- row:
+				case <-ctx.Done():
+					return
 				}
 			}
 		}
-		close(ch) // Close the channel when done
 	}()
 	return ch
 }
 
+// matrixGenerator holds the unpacked input of the matrix generator
+type matrixGenerator struct {
+	client           Client
+	walls            *wallIndex
+	obstacles        []obstacleBounds
+	sensors          []db.Sensor
+	emissions        []Emission
+	slabs            []Slab
+	antennas         []Antenna
+	model            PropagationModel
+	cal              Calibration
+	minX             int
+	minY             int
+	maxX             int
+	maxY             int
+	cell_size_meters float64
+}
+
+func newMatrixGenerator(inputData InputData) *matrixGenerator {
+	g := &matrixGenerator{
+		client:           inputData.client,
+		walls:            newWallIndex(modelWalls(inputData.Model(), inputData.walls)),
+		obstacles:        newObstacleBounds(inputData.obstacles),
+		sensors:          inputData.sensors,
+		emissions:        inputData.emissions,
+		slabs:            inputData.slabs,
+		antennas:         inputData.antennas,
+		model:            inputData.Model(),
+		cal:              inputData.Calibration(),
+		minX:             inputData.minX,
+		minY:             inputData.minY,
+		maxX:             inputData.maxX,
+		maxY:             inputData.maxY,
+		cell_size_meters: inputData.cell_size_meters,
+	}
+	if g.emissions == nil {
+		g.emissions = make([]Emission, len(g.sensors))
+		for i := range g.emissions {
+			g.emissions[i] = defaultEmission(g.client)
+		}
+	}
+	if g.antennas == nil {
+		g.antennas = make([]Antenna, len(g.sensors))
+		for i, sensor := range g.sensors {
+			g.antennas[i] = newAntenna(sensor.Diagram, sensor.HorRotationOffset, sensor.VertRotationOffset, false)
+		}
+	}
+	return g
+}
+
+// tile computes the rows of the tile t, a cancelled tile is returned incomplete
+func (g *matrixGenerator) tile(ctx context.Context, t int) []MatrixPoint {
+	fromY := g.minY + t*MATRIX_TILE_ROWS
+	toY := min(fromY+MATRIX_TILE_ROWS-1, g.maxY)
+	width := g.maxX - g.minX + 1
+	rows := make([]MatrixPoint, 0, (toY-fromY+1)*width*len(g.sensors))
+	var buf cellScratch // Reused by every cell of the tile
+
+	for y := fromY; y <= toY; y++ {
+		if ctx.Err() != nil {
+			return rows
+		}
+		for x := g.minX; x <= g.maxX; x++ {
+			// Point ids are numbered from 1 in the order of a sequential scan
+			var id int = (y-g.minY)*width + (x - g.minX) + 1
+			rows = g.cell(rows, &buf, id, x, y)
+		}
+	}
+	return rows
+}
+
+// cellScratch holds the buffers of the rays of a cell
+type cellScratch struct {
+	candidates []int     // Walls the ray may cross, see wallIndex.candidates
+	crossings  []float64 // Crossings of the ray with an obstacle polygon
+}
+
+// cell appends the rows of every sensor in the cell (x; y)
+func (g *matrixGenerator) cell(rows []MatrixPoint, buf *cellScratch, id int, x int, y int) []MatrixPoint {
+	var client Client = g.client
+	var cal Calibration = g.cal
+	var model PropagationModel = g.model
+	var cell_size_meters float64 = g.cell_size_meters
+
+	var matrixWithPoint MatrixPoint = MatrixPoint{
+		id: id,
+		// sensorId: -1,
+		x:        x,
+		y:        y,
+		x_m:      float64(x) * cell_size_meters,
+		y_m:      float64(y) * cell_size_meters,
+		rssi24:   cal.RSSIInvisible,
+		rssi5:    cal.RSSIInvisible,
+		rssi6:    cal.RSSIInvisible,
+		distance: DISTANCE_INVISIBLE,
+	}
+
+	for i_s, sensor := range g.sensors {
+		var emission Emission = g.emissions[i_s]
+		var distance float64 = _getDistance(x, y, client, sensor, cell_size_meters)
+		var freeSpaceRSSI24, freeSpaceRSSI5, freeSpaceRSSI6 = _getFreeSpaceRSSI(x, y, client, sensor, g.antennas[i_s], emission, model, distance, cell_size_meters)
+		var wallsLoss24 float64 = 0
+		var wallsLoss5 float64 = 0
+		var wallsLoss6 float64 = 0
+		var slabLoss24, slabLoss5, slabLoss6 float64
+
+		// Emitters of other floors, sensor.Z is relative to the floor
+		if i_s < len(g.slabs) {
+			slabLoss24, slabLoss5, slabLoss6 = g.slabs[i_s].loss(distance, sensor.Z-client.zM)
+			freeSpaceRSSI24 += slabLoss24
+			freeSpaceRSSI5 += slabLoss5
+			freeSpaceRSSI6 += slabLoss6
+		}
+
+		if cal.CalculateWalls && model.CountsWalls() {
+			if freeSpaceRSSI24 >= cal.RSSICutoff || freeSpaceRSSI5 >= cal.RSSICutoff || freeSpaceRSSI6 >= cal.RSSICutoff {
+				buf.candidates = g.walls.candidates(float64(x), float64(y), sensor.X, sensor.Y, buf.candidates)
+				wallsLoss24, wallsLoss5, wallsLoss6 = _getIndexedWallsAttenuation(x, y, g.walls.walls, buf.candidates, sensor, client, cell_size_meters, cal.RSSICutoff)
+				if len(g.obstacles) > 0 {
+					obstaclesLoss24, obstaclesLoss5, obstaclesLoss6 := _getObstaclesAttenuation(x, y, g.obstacles, sensor, client, distance, &buf.crossings)
+					wallsLoss24 += obstaclesLoss24
+					wallsLoss5 += obstaclesLoss5
+					wallsLoss6 += obstaclesLoss6
+				}
+			}
+		}
+
+		var tempRSSI24 float64 = freeSpaceRSSI24 + wallsLoss24 + cal.CorrectionCoefficient24
+		// var rssi_24 float64 = (tempRSSI24 >= RSII_CUTOFF) ? Number(tempRSSI24.toFixed(1)) : RSSI_INVISIBLE;
+		var rssi_24 float64
+		var rssi_5 float64
+		var rssi_6 float64
+		if tempRSSI24 >= cal.RSSICutoff {
+			rssi_24 = Round(tempRSSI24*10) / 10 // округление до 1 знака
+		} else {
+			rssi_24 = cal.RSSIInvisible
+		}
+
+		var tempRSSI5 float64 = freeSpaceRSSI5 + wallsLoss5 + cal.CorrectionCoefficient5
+		// var rssi_5 float64 = (tempRSSI5 >= RSII_CUTOFF) ? Number(tempRSSI5.toFixed(1)) : RSSI_INVISIBLE;
+		if tempRSSI5 >= cal.RSSICutoff {
+			rssi_5 = Round(tempRSSI5*10) / 10
+		} else {
+			rssi_5 = cal.RSSIInvisible
+		}
+
+		var tempRSSI6 float64 = freeSpaceRSSI6 + wallsLoss6 + cal.CorrectionCoefficient6
+		// var rssi_6 float64 = (tempRSSI6 >= RSII_CUTOFF) ? Number(tempRSSI6.toFixed(1)) : RSSI_INVISIBLE;
+		if tempRSSI6 >= cal.RSSICutoff {
+			rssi_6 = Round(tempRSSI6*10) / 10
+		} else {
+			rssi_6 = cal.RSSIInvisible
+		}
+
+		distance = Round(distance*10) / 10
+
+		matrixWithPoint.sensorId = sensor.ID
+		matrixWithPoint.rssi24 = rssi_24
+		matrixWithPoint.rssi5 = rssi_5
+		matrixWithPoint.rssi6 = rssi_6
+		matrixWithPoint.distance = distance
+
+		rows = append(rows, matrixWithPoint)
+	}
+	return rows
+}
+
 /**
  * Returns the negative numbers of total walls attenuation for 2.4, 5 and 6 HHz bands.
+ * Every wall is tested, see _getIndexedWallsAttenuation for the walls of the index.
  * @param clientX
  * @param clientY
  * @param walls
  * @param sensor
  * @param client
+ * @param rssi_cutoff Loss below which the link is invisible in every band.
  * @returns
  */
-func _getWallsAttenuation(clientX int, clientY int, walls []Wall, sensor db.Sensor, client Client, cell_size_meters float64) (float64, float64, float64) {
+func _getWallsAttenuation(clientX int, clientY int, walls []Wall, sensor db.Sensor, client Client, cell_size_meters float64, rssi_cutoff float64) (float64, float64, float64) {
 	var loss24 float64 = 0
 	var loss5 float64 = 0
 	var loss6 float64 = 0
 
 	for _, wall := range walls {
-		var wall_path_length_through float64 = getWallPathLengthThrough(XYZcoordinate{x: float64(clientX), y: float64(clientY), z: client.zM},
-			XYZcoordinate{x: sensor.X, y: sensor.Y, z: sensor.Z},
-			XYZcoordinate{x: float64(wall.X1), y: float64(wall.Y1), z: 0},
-			XYZcoordinate{x: float64(wall.X2), y: float64(wall.Y2), z: 0},
-			wall.Thickness,
-			cell_size_meters)
-
-		if wall_path_length_through != 0 {
-			var pathDivideThickness float64 = wall_path_length_through / wall.Thickness
-			loss24 -= wall.Attenuation24 * pathDivideThickness
-			loss5 -= wall.Attenuation5 * pathDivideThickness
-			loss6 -= wall.Attenuation6 * pathDivideThickness
-
-			if loss24 <= RSII_CUTOFF && loss5 <= RSII_CUTOFF && loss6 <= RSII_CUTOFF {
-				break
-			}
+		if _addWallAttenuation(clientX, clientY, wall, sensor, client, cell_size_meters, rssi_cutoff, &loss24, &loss5, &loss6) {
+			break
 		}
 	}
 
 	return loss24, loss5, loss6
 }
 
+/**
+ * Returns the same attenuation as _getWallsAttenuation testing only the candidate walls.
+ * @param walls All walls of the floor.
+ * @param candidates Ascending indexes of the walls that the ray may cross (see wallIndex.candidates).
+ * @returns
+ */
+func _getIndexedWallsAttenuation(clientX int, clientY int, walls []Wall, candidates []int, sensor db.Sensor, client Client, cell_size_meters float64, rssi_cutoff float64) (float64, float64, float64) {
+	var loss24 float64 = 0
+	var loss5 float64 = 0
+	var loss6 float64 = 0
+
+	for _, i := range candidates {
+		if _addWallAttenuation(clientX, clientY, walls[i], sensor, client, cell_size_meters, rssi_cutoff, &loss24, &loss5, &loss6) {
+			break
+		}
+	}
+
+	return loss24, loss5, loss6
+}
+
+/**
+ * Adds the attenuation of the wall crossed by the ray between the client and the sensor to the losses.
+ * @returns True when the losses are below the cutoff in every band and the remaining walls can be skipped.
+ */
+func _addWallAttenuation(clientX int, clientY int, wall Wall, sensor db.Sensor, client Client, cell_size_meters float64, rssi_cutoff float64, loss24 *float64, loss5 *float64, loss6 *float64) bool {
+	var wall_path_length_through float64 = getWallPathLengthThrough(XYZcoordinate{x: float64(clientX), y: float64(clientY), z: client.zM},
+		XYZcoordinate{x: sensor.X, y: sensor.Y, z: sensor.Z},
+		XYZcoordinate{x: wall.X1, y: wall.Y1, z: 0},
+		XYZcoordinate{x: wall.X2, y: wall.Y2, z: 0},
+		wall.Thickness,
+		cell_size_meters)
+
+	if wall_path_length_through == 0 || !_isWallAtRayHeight(clientX, clientY, wall, sensor, client) {
+		return false
+	}
+	var pathDivideThickness float64 = wall_path_length_through / wall.Thickness
+	*loss24 -= wall.Attenuation24 * pathDivideThickness
+	*loss5 -= wall.Attenuation5 * pathDivideThickness
+	*loss6 -= wall.Attenuation6 * pathDivideThickness
+
+	return *loss24 <= rssi_cutoff && *loss5 <= rssi_cutoff && *loss6 <= rssi_cutoff
+}
+
+/**
+ * Returns whether the ray between the client and the sensor crosses the wall between its bottom and top.
+ * The height is taken where the ray meets the wall line, a ray parallel to the wall crosses it when their heights overlap.
+ */
+func _isWallAtRayHeight(clientX int, clientY int, wall Wall, sensor db.Sensor, client Client) bool {
+	if wall.Bottom <= 0 && wall.Top <= 0 {
+		return true
+	}
+	var top float64 = wall.Top
+	if top <= 0 {
+		top = Inf(1)
+	}
+	var rayX, rayY float64 = sensor.X - float64(clientX), sensor.Y - float64(clientY)
+	var wallX, wallY float64 = wall.X2 - wall.X1, wall.Y2 - wall.Y1
+	var determinant float64 = rayX*wallY - rayY*wallX
+	if determinant == 0 {
+		return Max(client.zM, sensor.Z) >= wall.Bottom && Min(client.zM, sensor.Z) <= top
+	}
+	var t float64 = ((wall.X1-float64(clientX))*wallY - (wall.Y1-float64(clientY))*wallX) / determinant
+	t = Max(0, Min(t, 1))
+	var z float64 = client.zM + t*(sensor.Z-client.zM)
+	return z >= wall.Bottom && z <= top
+}
+
 /**
  * Returns the distance in meters between client and sensor.
  * @param clientX Client x coordinate.
@@ -186,25 +395,31 @@ func _getDistance(clientX int, clientY int, client Client, sensor db.Sensor, cel
 
 /**
  * Returns the free space pass loss in dB.
- * @param frequency Transmission frequency in GHz.
+ * @param frequency Transmission frequency in MHz.
  * @param attenuation_factor Attenuation factor.
  * @param penetration_factor Penetration factor.
  * @param distance Transmission distance.
  * @returns Free space pass loss in dB.
  */
-func _getFSPL(frequency int, attenuation_factor float64, penetration_factor float64, distance float64) float64 {
+func _getFSPL(frequency float64, attenuation_factor float64, penetration_factor float64, distance float64) float64 {
 	if distance < 1 {
 		distance = 1
 	}
-	return 20*Log10(float64(frequency)) + 10*attenuation_factor*Log10(distance) + penetration_factor - 24
+	return 20*Log10(frequency) + 10*attenuation_factor*Log10(distance) + penetration_factor - 24
 }
 
-func _approximateAzimuth(azimuth float64, delta float64) (int, error) {
-	if delta == 0 {
-		return 0, errors.New("delta cannot be zero")
+/**
+ * Returns the RSSI of one band without walls and antenna gain.
+ * @param model Propagation model.
+ * @param frequency Transmission frequency in MHz, zero when the band is not radiated.
+ * @param power EIRP in dBm.
+ * @returns RSSI in dBm, minus infinity when the band is not radiated.
+ */
+func _getBandFreeSpaceRSSI(model PropagationModel, band Band, frequency float64, power float64, distance float64) float64 {
+	if frequency <= 0 {
+		return Inf(-1)
 	}
-
-	return int(Floor((azimuth+Floor(delta/2))/delta)*delta) % 360, nil
+	return power - model.PathLoss(band, frequency, distance)
 }
 
 /**
@@ -213,71 +428,26 @@ func _approximateAzimuth(azimuth float64, delta float64) (int, error) {
  * @param clientY Client y coordinate.
  * @param client Client`s parameters.
  * @param sensor Sensor.
+ * @param antenna Antenna of the sensor, sensor.RxAntGain applies in every direction without a pattern.
+ * @param emission Frequency and EIRP of every band, bands with zero frequency are invisible.
+ * @param model Propagation model.
  * @param distance Distance between client and sensors in meters.
+ * @param cell_size_meters Cell size in meters.
  * @returns Tuple of RSSI for 2.4, 5 and 6 HHz bands.
  */
-func _getFreeSpaceRSSI(clientX int, clientY int, client Client, sensor db.Sensor, distance float64) (float64, float64, float64) {
-	var fspl24 float64 = _getFSPL(FREQUENCY24, ATTENUATION_FACTOR24, PENETRATION_FACTOR24, distance)
-	var fspl5 float64 = _getFSPL(FREQUENCY5, ATTENUATION_FACTOR5, PENETRATION_FACTOR5, distance)
-	var fspl6 float64 = _getFSPL(FREQUENCY6, ATTENUATION_FACTOR6, PENETRATION_FACTOR6, distance)
-
-	var freeSpaceRSSI24 float64 = float64(client.trSignalPower) + float64(client.trAntGain) - fspl24 + sensor.CorrectionFactor24
-	var freeSpaceRSSI5 float64 = float64(client.trSignalPower) + float64(client.trAntGain) - fspl5 + sensor.CorrectionFactor5
-	var freeSpaceRSSI6 float64 = float64(client.trSignalPower) + float64(client.trAntGain) - fspl6 + sensor.CorrectionFactor6
-
-	var ant_gain float64 = 2
-
-	var diagram db.Diagram
-	err := json.Unmarshal(sensor.Diagram, &diagram)
-	if err != nil {
-		var delta int = 0
-		if _, ok := diagram.Degree["10"]; ok {
-			delta = 10
-		}
-		if _, ok := diagram.Degree["15"]; ok {
-			delta = 15
-		}
-
-		if delta == 0 {
-			log.Warn().Msg(`The radiation diagram can have only the step of 10 or 15 degrees.
-            Check that the radiation diagram for sensor with id = ${sensor.id} is filled out correctly.
-            By default, the antenna gain of ${sensor.rx_ant_gain} will be used for all directions.`)
-		} else {
-			hor_azimuth, error := _approximateAzimuth(
-				float64(getHorizontalAzimuthDeg(
-					XYZcoordinate{x: sensor.X, y: sensor.Y, z: sensor.Z},
-					XYZcoordinate{float64(clientX), float64(clientY), client.zM},
-					0)),
-				float64(delta))
-			if error != nil {
-				goto errorHandling
-			}
-
-			vert_azimuth, err := _approximateAzimuth(
-				float64(getVerticalAzimuthDeg(
-					XYZcoordinate{x: sensor.X, y: sensor.Y, z: sensor.Z},
-					XYZcoordinate{float64(clientX), float64(clientY), client.zM},
-					0)),
-				float64(delta))
-			if err != nil {
-				goto errorHandling
-			}
-
-			ant_gain = (diagram.Degree[string(hor_azimuth)].HorGain + diagram.Degree[string(vert_azimuth)].VertGain) / 2 // окр до десятых
-
-		}
-	} else {
-		freeSpaceRSSI24 += sensor.RxAntGain
-		freeSpaceRSSI5 += sensor.RxAntGain
-		freeSpaceRSSI6 += sensor.RxAntGain
+func _getFreeSpaceRSSI(clientX int, clientY int, client Client, sensor db.Sensor, antenna Antenna, emission Emission, model PropagationModel, distance float64, cell_size_meters float64) (float64, float64, float64) {
+	var freeSpaceRSSI24 float64 = _getBandFreeSpaceRSSI(model, Band24, emission.Frequency24, emission.Power24, distance) + sensor.CorrectionFactor24
+	var freeSpaceRSSI5 float64 = _getBandFreeSpaceRSSI(model, Band5, emission.Frequency5, emission.Power5, distance) + sensor.CorrectionFactor5
+	var freeSpaceRSSI6 float64 = _getBandFreeSpaceRSSI(model, Band6, emission.Frequency6, emission.Power6, distance) + sensor.CorrectionFactor6
+
+	var ant_gain float64 = sensor.RxAntGain
+	if gain, ok := antenna.gain(
+		(float64(clientX)-sensor.X)*cell_size_meters,
+		(float64(clientY)-sensor.Y)*cell_size_meters,
+		client.zM-sensor.Z,
+	); ok {
+		ant_gain = gain
 	}
 
-	return freeSpaceRSSI24, freeSpaceRSSI5, freeSpaceRSSI6
-
-errorHandling:
-	ant_gain = sensor.RxAntGain
-	freeSpaceRSSI24 += ant_gain
-	freeSpaceRSSI5 += ant_gain
-	freeSpaceRSSI6 += ant_gain
-	return freeSpaceRSSI24, freeSpaceRSSI5, freeSpaceRSSI6
+	return freeSpaceRSSI24 + ant_gain, freeSpaceRSSI5 + ant_gain, freeSpaceRSSI6 + ant_gain
 }
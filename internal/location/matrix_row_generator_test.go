@@ -0,0 +1,109 @@
+package location
+
+import (
+	"context"
+	"fmt"
+	"location-backend/internal/db"
+	"math/rand"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// syntheticInput builds a floor of width x height cells of 0.5 m with randomly placed sensors and walls
+func syntheticInput(width, height, sensors, walls int) InputData {
+	rnd := rand.New(rand.NewSource(1))
+	inputData := InputData{
+		client:           Client{trSignalPower: int(EIRP), zM: CLIENT_HEIGHT_METER},
+		cell_size_meters: 0.5,
+		maxX:             width - 1,
+		maxY:             height - 1,
+	}
+	for i := 0; i < sensors; i++ {
+		inputData.sensors = append(inputData.sensors, db.Sensor{
+			ID: uuid.New(),
+			X:  rnd.Float64() * float64(width),
+			Y:  rnd.Float64() * float64(height),
+			Z:  2.5,
+		})
+	}
+	for i := 0; i < walls; i++ {
+		x, y := rnd.Float64()*float64(width), rnd.Float64()*float64(height)
+		inputData.walls = append(inputData.walls, Wall{
+			ID:            uuid.New(),
+			X1:            x,
+			Y1:            y,
+			X2:            x + rnd.Float64()*20 - 10,
+			Y2:            y + rnd.Float64()*20 - 10,
+			Thickness:     0.2,
+			Attenuation24: 5,
+			Attenuation5:  8,
+			Attenuation6:  10,
+		})
+	}
+	return inputData
+}
+
+func collect(ch chan MatrixPoint) (rows []MatrixPoint) {
+	for row := range ch {
+		rows = append(rows, row)
+	}
+	return
+}
+
+func TestGenerateMatrixRowOrderDoesNotDependOnWorkers(t *testing.T) {
+	inputData := syntheticInput(37, 29, 3, 10)
+	sequential := collect(GenerateMatrixRow(inputData.WithWorkers(1)))
+	parallel := collect(GenerateMatrixRow(inputData.WithWorkers(8)))
+
+	if want := 37 * 29 * 3; len(sequential) != want {
+		t.Fatalf("got %d rows, want %d", len(sequential), want)
+	}
+	if len(parallel) != len(sequential) {
+		t.Fatalf("parallel run produced %d rows, sequential %d", len(parallel), len(sequential))
+	}
+	for i := range sequential {
+		if parallel[i] != sequential[i] {
+			t.Fatalf("row %d differs: %+v != %+v", i, parallel[i], sequential[i])
+		}
+		if want := i/3 + 1; sequential[i].id != want {
+			t.Fatalf("row %d has point id %d, want %d", i, sequential[i].id, want)
+		}
+	}
+}
+
+func TestGenerateMatrixRowContextStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := GenerateMatrixRowContext(ctx, syntheticInput(200, 200, 5, 0).WithWorkers(4))
+	<-ch
+	cancel()
+
+	var rest int
+	for range ch {
+		rest++
+	}
+	if rest >= 200*200*5-1 {
+		t.Errorf("generator did not stop after cancel, %d rows were sent", rest)
+	}
+}
+
+func BenchmarkGenerateMatrixRow(b *testing.B) {
+	inputData := syntheticInput(200, 100, 10, 50)
+	for _, workers := range []int{1, 2, 4, 0} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			in := inputData.WithWorkers(workers)
+			for i := 0; i < b.N; i++ {
+				for range GenerateMatrixRow(in) {
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMatrixBatches(b *testing.B) {
+	inputData := syntheticInput(200, 100, 10, 50)
+	for i := 0; i < b.N; i++ {
+		for range MatrixBatches(context.Background(), inputData) {
+		}
+	}
+}
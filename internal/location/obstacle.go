@@ -0,0 +1,142 @@
+package location
+
+import (
+	"location-backend/internal/db"
+	. "math"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// Obstacle is a polygon attenuating the signal per meter of the ray inside it, e.g. a column, a shaft or a crowd zone.
+// Points are in cells of the matrix as walls are.
+type Obstacle struct {
+	ID            uuid.UUID
+	Points        []XYcoordinate
+	Attenuation24 float64 // dB per meter
+	Attenuation5  float64
+	Attenuation6  float64
+	Bottom        float64 // Meters above the floor
+	Top           float64 // Meters above the floor, no limit when zero
+}
+
+// obstacleBounds is an obstacle with its bounding box, rays outside the box skip the polygon
+type obstacleBounds struct {
+	Obstacle
+	minX, minY, maxX, maxY float64
+}
+
+func newObstacleBounds(obstacles []Obstacle) []obstacleBounds {
+	bounds := make([]obstacleBounds, 0, len(obstacles))
+	for _, o := range obstacles {
+		if len(o.Points) < 3 {
+			continue
+		}
+		b := obstacleBounds{Obstacle: o, minX: Inf(1), minY: Inf(1), maxX: Inf(-1), maxY: Inf(-1)}
+		for _, pt := range o.Points {
+			b.minX, b.maxX = Min(b.minX, pt.x), Max(b.maxX, pt.x)
+			b.minY, b.maxY = Min(b.minY, pt.y), Max(b.maxY, pt.y)
+		}
+		bounds = append(bounds, b)
+	}
+	return bounds
+}
+
+// contains reports whether the point is inside the polygon by the even-odd rule
+func (o *obstacleBounds) contains(x, y float64) bool {
+	inside := false
+	for i, j := 0, len(o.Points)-1; i < len(o.Points); j, i = i, i+1 {
+		a, b := o.Points[i], o.Points[j]
+		if (a.y > y) != (b.y > y) && x < a.x+(y-a.y)*(b.x-a.x)/(b.y-a.y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// pathLength returns the part of the segment (x1; y1)-(x2; y2), from 0 to 1, that lies inside the polygon
+// between the parameters from and to. ts is a scratch buffer.
+func (o *obstacleBounds) pathLength(x1, y1, x2, y2, from, to float64, ts []float64) (float64, []float64) {
+	if Max(x1, x2) < o.minX || Min(x1, x2) > o.maxX || Max(y1, y2) < o.minY || Min(y1, y2) > o.maxY {
+		return 0, ts
+	}
+	rayX, rayY := x2-x1, y2-y1
+	ts = append(ts[:0], from, to)
+	for i, j := 0, len(o.Points)-1; i < len(o.Points); j, i = i, i+1 {
+		a, b := o.Points[j], o.Points[i]
+		edgeX, edgeY := b.x-a.x, b.y-a.y
+		determinant := rayX*edgeY - rayY*edgeX
+		if determinant == 0 {
+			continue
+		}
+		t := ((a.x-x1)*edgeY - (a.y-y1)*edgeX) / determinant
+		u := ((a.x-x1)*rayY - (a.y-y1)*rayX) / determinant
+		if t > from && t < to && u >= 0 && u <= 1 {
+			ts = append(ts, t)
+		}
+	}
+	slices.Sort(ts)
+
+	// The segment is inside or outside the polygon between consecutive crossings
+	var inside float64
+	for i := 1; i < len(ts); i++ {
+		if ts[i] == ts[i-1] {
+			continue
+		}
+		t := (ts[i-1] + ts[i]) / 2
+		if o.contains(x1+t*rayX, y1+t*rayY) {
+			inside += ts[i] - ts[i-1]
+		}
+	}
+	return inside, ts
+}
+
+// heightSpan returns the parameters, from 0 to 1, of the part of the ray rising from z1 to z2 meters
+// that lies between bottom and top, from > to when there is none
+func heightSpan(z1, z2, bottom, top float64) (from, to float64) {
+	if top <= 0 {
+		top = Inf(1)
+	}
+	if z1 == z2 {
+		if z1 >= bottom && z1 <= top {
+			return 0, 1
+		}
+		return 1, 0
+	}
+	from, to = (bottom-z1)/(z2-z1), (top-z1)/(z2-z1)
+	if from > to {
+		from, to = to, from
+	}
+	return Max(from, 0), Min(to, 1)
+}
+
+/**
+ * Returns the negative numbers of total obstacles attenuation for 2.4, 5 and 6 HHz bands.
+ * @param distance Distance between client and sensor in meters.
+ * @param ts Scratch buffer of crossing parameters.
+ * @returns
+ */
+func _getObstaclesAttenuation(clientX int, clientY int, obstacles []obstacleBounds, sensor db.Sensor, client Client, distance float64, ts *[]float64) (float64, float64, float64) {
+	var loss24 float64 = 0
+	var loss5 float64 = 0
+	var loss6 float64 = 0
+
+	for i := range obstacles {
+		o := &obstacles[i]
+		from, to := heightSpan(client.zM, sensor.Z, o.Bottom, o.Top)
+		if from >= to {
+			continue
+		}
+		var inside float64
+		inside, *ts = o.pathLength(float64(clientX), float64(clientY), sensor.X, sensor.Y, from, to, *ts)
+		if inside == 0 {
+			continue
+		}
+		var meters float64 = inside * distance
+		loss24 -= o.Attenuation24 * meters
+		loss5 -= o.Attenuation5 * meters
+		loss6 -= o.Attenuation6 * meters
+	}
+
+	return loss24, loss5, loss6
+}
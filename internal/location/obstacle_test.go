@@ -0,0 +1,64 @@
+package location
+
+import (
+	"location-backend/internal/db"
+	"math"
+	"testing"
+)
+
+func polygon(points ...float64) (pts []XYcoordinate) {
+	for i := 0; i+1 < len(points); i += 2 {
+		pts = append(pts, XYcoordinate{x: points[i], y: points[i+1]})
+	}
+	return
+}
+
+func TestObstaclePathLength(t *testing.T) {
+	square := Obstacle{Points: polygon(2, 2, 6, 2, 6, 6, 2, 6)}
+	// U shape open to the top, a horizontal ray at y = 5 crosses both of its arms
+	u := Obstacle{Points: polygon(0, 0, 9, 0, 9, 6, 6, 6, 6, 3, 3, 3, 3, 6, 0, 6)}
+	cases := []struct {
+		name           string
+		obstacle       Obstacle
+		x1, y1, x2, y2 float64
+		want           float64 // Part of the ray inside
+	}{
+		{"through", square, 0, 4, 10, 4, 0.4},
+		{"diagonal", square, 0, 0, 8, 8, 0.5},
+		{"starts inside", square, 4, 4, 8, 4, 0.5},
+		{"inside", square, 3, 3, 5, 5, 1},
+		{"misses", square, 0, 7, 10, 7, 0},
+		{"concave", u, -1, 5, 10, 5, 6.0 / 11},
+	}
+	for _, c := range cases {
+		b := newObstacleBounds([]Obstacle{c.obstacle})[0]
+		got, _ := b.pathLength(c.x1, c.y1, c.x2, c.y2, 0, 1, nil)
+		if math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s: %v of the ray inside, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestObstaclesAttenuationByHeight(t *testing.T) {
+	// A rack 1.5 m high, the ray rises from 1 m to 2 m across it
+	obstacles := newObstacleBounds([]Obstacle{{Points: polygon(2, -1, 6, -1, 6, 1, 2, 1), Attenuation5: 2, Top: 1.5}})
+	client := Client{zM: 1}
+	sensor := db.Sensor{X: 8, Y: 0, Z: 2}
+	distance := _getDistance(0, 0, client, sensor, 1)
+	var ts []float64
+
+	_, loss5, _ := _getObstaclesAttenuation(0, 0, obstacles, sensor, client, distance, &ts)
+	// Inside from x = 2 to x = 4 where the ray reaches 1.5 m
+	if want := -2 * 0.25 * distance; math.Abs(loss5-want) > 1e-9 {
+		t.Errorf("loss %v dB, want %v dB", loss5, want)
+	}
+
+	sensor.Z = 5
+	if _, loss5, _ := _getObstaclesAttenuation(0, 0, obstacles, sensor, client, distance, &ts); loss5 != 0 {
+		t.Errorf("a ray rising above the rack before reaching it is attenuated by %v dB", loss5)
+	}
+	sensor.Z = 1.2
+	if _, loss5, _ := _getObstaclesAttenuation(0, 0, obstacles, sensor, client, distance, &ts); math.Abs(loss5+2*0.5*distance) > 1e-9 {
+		t.Errorf("a ray below the top of the rack is attenuated by %v dB, want %v dB", loss5, -2*0.5*distance)
+	}
+}
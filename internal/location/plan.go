@@ -0,0 +1,497 @@
+package location
+
+import (
+	"cmp"
+	"fmt"
+	"location-backend/internal/db"
+	. "math"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// DFS preferences of a band plan
+const (
+	DFSAllow   = "allow"   // DFS channels are planned as any other
+	DFSAvoid   = "avoid"   // DFS channels are planned when they save more than PLAN_DFS_PENALTY of the area
+	DFSExclude = "exclude" // DFS channels are not planned
+)
+
+// PlanBand restricts the plan of a band. Channels are the allowed primary channels, PLAN_CHANNELS24,
+// PLAN_CHANNELS5 or PLAN_CHANNELS6 when empty. The power range in dBm defaults to PLAN_POWER_RANGE below
+// the current power of every radio up to the current power.
+type PlanBand struct {
+	Channels []int  `json:"channels"`
+	DFS      string `json:"dfs"` // DFSAvoid when empty
+	MinPower *int   `json:"minPower"`
+	MaxPower *int   `json:"maxPower"`
+}
+
+// PlanOptions are the bands to plan keyed by "2.4", "5" or "6", every band with the defaults when empty,
+// and the RSSI in dBm the plan keeps the floor covered with, COVERAGE_THRESHOLDS[0] when nil
+type PlanOptions struct {
+	Bands      map[string]PlanBand `json:"bands"`
+	TargetRSSI *float64            `json:"targetRssi"`
+}
+
+// PlanFloor is the stored coverage matrix of a floor the plan is evaluated on.
+// Signals at or below Invisible dBm are not heard.
+type PlanFloor struct {
+	Matrix    *db.Matrix
+	Rows      []*db.MatrixRow
+	Invisible float64
+}
+
+// PlanScore evaluates the channels and powers of a band on the sampled cells of the floors
+type PlanScore struct {
+	ContendedArea float64 `json:"contendedArea"` // Square meters where the serving radio hears an interferer, see INTERFERENCE_DETECT_RSSI
+	UncoveredArea float64 `json:"uncoveredArea"` // Square meters below the target RSSI
+	DFSRadios     int     `json:"dfsRadios"`     // Planned radios on DFS channels
+	Cost          float64 `json:"cost"`          // Minimised by the plan
+}
+
+// BandPlan is the score of a band before and after the plan
+type BandPlan struct {
+	Band   Band      `json:"band"`
+	Radios int       `json:"radios"` // Planned radios
+	Area   float64   `json:"area"`   // Square meters the plan is evaluated on
+	Before PlanScore `json:"before"`
+	After  PlanScore `json:"after"`
+}
+
+// RadioChange is the planned channel and power of a radio
+type RadioChange struct {
+	AccessPointID  uuid.UUID `json:"accessPointId"`
+	RadioID        uuid.UUID `json:"radioId"`
+	Band           Band      `json:"band"`
+	Channel        int       `json:"channel"`
+	PlannedChannel int       `json:"plannedChannel"`
+	Power          int       `json:"power"` // dBm
+	PlannedPower   int       `json:"plannedPower"`
+}
+
+// ChannelPlan is the planned channels and powers, Changes list only the radios the plan changes
+type ChannelPlan struct {
+	Bands   []BandPlan    `json:"bands"`
+	Changes []RadioChange `json:"changes"`
+}
+
+// planSignal is the RSSI of a radio in a cell at zero dBm of power
+type planSignal struct {
+	radio int
+	gain  float64
+}
+
+// planCell is a sampled cell, weight is the area in square meters it stands for
+type planCell struct {
+	weight  float64
+	signals []planSignal
+}
+
+// planRadio is a radio of the band, the planned ones have the candidate channels and powers
+type planRadio struct {
+	ap       uuid.UUID
+	current  RadioChannel
+	channel  RadioChannel
+	planned  bool
+	channels []RadioChannel
+	powers   []float64
+	cells    []int
+}
+
+// bandPlanner searches the channels and powers of the radios of a band by coordinate descent
+type bandPlanner struct {
+	dfs    string
+	target float64
+	area   float64
+	radios []*planRadio
+	cells  []planCell
+}
+
+// NewChannelPlan plans the channels and powers of the radios of the planned access points in every band of
+// the options. channels are the channels of every radio heard on the floors, see FloorChannels, every radio
+// of a planned access point is planned and radios of the others keep theirs. The plan minimises the area
+// of co-channel and adjacent channel interference heard by clients while keeping the area covered at the target RSSI.
+// Invalid options are an error.
+func NewChannelPlan(floors []PlanFloor, channels map[uuid.UUID]RadioChannel, planned []uuid.UUID, opts PlanOptions) (*ChannelPlan, error) {
+	bands, err := opts.bands()
+	if err != nil {
+		return nil, err
+	}
+	target := COVERAGE_THRESHOLDS[0]
+	if opts.TargetRSSI != nil {
+		target = *opts.TargetRSSI
+	}
+
+	plan := &ChannelPlan{Bands: []BandPlan{}, Changes: []RadioChange{}}
+	for _, band := range []Band{Band24, Band5, Band6} {
+		pb, ok := bands[band]
+		if !ok {
+			continue
+		}
+		bp := newBandPlanner(band, pb, target, floors, channels, planned)
+		if bp == nil {
+			continue
+		}
+		result := BandPlan{Band: band, Area: bp.area, Before: bp.score()}
+		bp.optimize()
+		result.After = bp.score()
+		for _, r := range bp.radios {
+			if !r.planned {
+				continue
+			}
+			result.Radios++
+			if r.channel.Channel == r.current.Channel && r.channel.Power == r.current.Power {
+				continue
+			}
+			plan.Changes = append(plan.Changes, RadioChange{
+				AccessPointID:  r.ap,
+				RadioID:        r.current.RadioID,
+				Band:           band,
+				Channel:        r.current.Channel,
+				PlannedChannel: r.channel.Channel,
+				Power:          int(r.current.Power),
+				PlannedPower:   int(r.channel.Power),
+			})
+		}
+		plan.Bands = append(plan.Bands, result)
+	}
+	return plan, nil
+}
+
+// bands validates the options and returns the plan of every band
+func (o PlanOptions) bands() (map[Band]PlanBand, error) {
+	bands := make(map[Band]PlanBand)
+	if len(o.Bands) == 0 {
+		for _, band := range []Band{Band24, Band5, Band6} {
+			bands[band] = PlanBand{}
+		}
+	}
+	for key, pb := range o.Bands {
+		band, err := ParseBand(key)
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range pb.Channels {
+			if _, err := ChannelFrequency(band, ch); err != nil {
+				return nil, err
+			}
+		}
+		switch pb.DFS {
+		case "", DFSAllow, DFSAvoid, DFSExclude:
+		default:
+			return nil, fmt.Errorf("unknown DFS preference %q", pb.DFS)
+		}
+		if (pb.MinPower != nil && *pb.MinPower < PLAN_MIN_POWER) || (pb.MaxPower != nil && *pb.MaxPower > PLAN_MAX_POWER) {
+			return nil, fmt.Errorf("power of band %v must be from %d to %d dBm", key, PLAN_MIN_POWER, PLAN_MAX_POWER)
+		}
+		if pb.MinPower != nil && pb.MaxPower != nil && *pb.MinPower > *pb.MaxPower {
+			return nil, fmt.Errorf("minimum power of band %v is above the maximum", key)
+		}
+		bands[band] = pb
+	}
+	return bands, nil
+}
+
+// CheckRadioChange validates the planned settings of a radio, see db.ApplyRadioSettings. The planned channel
+// must be a channel of the band of the radio, the radio can't move to another band, every 20 MHz channel of its
+// bonding must be valid and the power must be from PLAN_MIN_POWER to PLAN_MAX_POWER dBm.
+func CheckRadioChange(current, planned *db.Radio) error {
+	band, err := RadioBand(current)
+	if err != nil {
+		return err
+	}
+	if plannedBand, err := RadioBand(planned); err != nil || plannedBand != band {
+		return fmt.Errorf("channel %d moves radio %v out of band %v", *planned.Channel, planned.ID, band)
+	}
+	if planned.Power == nil || *planned.Power < PLAN_MIN_POWER || *planned.Power > PLAN_MAX_POWER {
+		return fmt.Errorf("power of radio %v must be from %d to %d dBm", planned.ID, PLAN_MIN_POWER, PLAN_MAX_POWER)
+	}
+	c, err := NewRadioChannel(planned)
+	if err != nil {
+		return err
+	}
+	for _, sub := range c.subchannels() {
+		if _, err := ChannelFrequency(band, sub); err != nil {
+			return fmt.Errorf("channel %d of radio %v is not valid for its width: %w", c.Channel, planned.ID, err)
+		}
+	}
+	return nil
+}
+
+// IsDFS tells whether a 20 MHz channel of the band requires dynamic frequency selection
+func IsDFS(band Band, channel int) bool {
+	return band == Band5 && channel >= DFS_FIRST_CHANNEL && channel <= DFS_LAST_CHANNEL
+}
+
+// subchannels returns the 20 MHz channels of the (bonded) channel
+func (c RadioChannel) subchannels() []int {
+	n := max(int(c.Width)/20, 1)
+	centre := c.Channel + int(Round((c.Frequency-mustChannelFrequency(c.Band, c.Channel))/5))
+	first := centre - 2*(n-1)
+	subchannels := make([]int, n)
+	for i := range subchannels {
+		subchannels[i] = first + 4*i
+	}
+	return subchannels
+}
+
+// mustChannelFrequency returns the centre frequency of a channel known to be valid
+func mustChannelFrequency(band Band, channel int) float64 {
+	frequency, _ := ChannelFrequency(band, channel)
+	return frequency
+}
+
+// isDFS tells whether any 20 MHz channel of the (bonded) channel requires DFS
+func (c RadioChannel) isDFS() bool {
+	return slices.ContainsFunc(c.subchannels(), func(ch int) bool { return IsDFS(c.Band, ch) })
+}
+
+// newBandPlanner samples the cells of the floors where a planned radio of the band is heard,
+// nil is returned when no planned radio is heard
+func newBandPlanner(band Band, pb PlanBand, target float64, floors []PlanFloor, channels map[uuid.UUID]RadioChannel, planned []uuid.UUID) *bandPlanner {
+	bp := &bandPlanner{dfs: pb.DFS, target: target}
+	if bp.dfs == "" {
+		bp.dfs = DFSAvoid
+	}
+	// Radios in a stable order for the same plan on every request
+	radios := bandRadios(channels, band)
+	aps := make([]uuid.UUID, 0, len(radios))
+	for ap := range radios {
+		aps = append(aps, ap)
+	}
+	slices.SortFunc(aps, func(a, b uuid.UUID) int { return cmp.Compare(a.String(), b.String()) })
+	index := make(map[uuid.UUID]int, len(channels))
+	for _, ap := range aps {
+		for _, c := range radios[ap] {
+			index[c.RadioID] = len(bp.radios)
+			bp.radios = append(bp.radios, &planRadio{ap: ap, current: c, channel: c, planned: slices.Contains(planned, ap)})
+		}
+	}
+
+	var cells []planCell
+	for _, f := range floors {
+		m := f.Matrix
+		width, height := m.MaxX-m.MinX+1, m.MaxY-m.MinY+1
+		grid := make([][]planSignal, width*height)
+		for _, row := range f.Rows {
+			x, y := int(Round(row.X/m.CellSize))-m.MinX, int(Round(row.Y/m.CellSize))-m.MinY
+			apRadios := radios[row.SensorID]
+			if x < 0 || y < 0 || x >= width || y >= height || len(apRadios) == 0 {
+				continue
+			}
+			// The radios of an access point share the path of the row, see bandRadios
+			gain := MatrixRowRSSI(row, band) - apRadios[0].Power
+			for _, c := range apRadios {
+				if gain+c.Power > f.Invisible {
+					grid[y*width+x] = append(grid[y*width+x], planSignal{radio: index[c.RadioID], gain: gain})
+				}
+			}
+		}
+		for _, signals := range grid {
+			if slices.ContainsFunc(signals, func(s planSignal) bool { return bp.radios[s.radio].planned }) {
+				cells = append(cells, planCell{weight: m.CellSize * m.CellSize, signals: signals})
+			}
+		}
+	}
+	if len(cells) == 0 {
+		return nil
+	}
+	// Large floors are thinned out, every kept cell stands for the skipped ones
+	stride := (len(cells) + PLAN_MAX_CELLS - 1) / PLAN_MAX_CELLS
+	for i := 0; i < len(cells); i += stride {
+		cell := cells[i]
+		cell.weight *= float64(stride)
+		bp.area += cell.weight
+		for _, s := range cell.signals {
+			bp.radios[s.radio].cells = append(bp.radios[s.radio].cells, len(bp.cells))
+		}
+		bp.cells = append(bp.cells, cell)
+	}
+
+	allowed := pb.Channels
+	if len(allowed) == 0 {
+		allowed = map[Band][]int{Band24: PLAN_CHANNELS24, Band5: PLAN_CHANNELS5, Band6: PLAN_CHANNELS6}[band]
+	}
+	for _, r := range bp.radios {
+		if !r.planned {
+			continue
+		}
+		r.channels = bp.candidateChannels(r.current, allowed)
+		r.powers = candidatePowers(r.current.Power, pb)
+	}
+	return bp
+}
+
+// candidateChannels returns the channels of the width of the radio with an allowed primary channel,
+// one per bonded channel. The current primary channel is preferred within its bonded channel.
+func (bp *bandPlanner) candidateChannels(current RadioChannel, allowed []int) (candidates []RadioChannel) {
+	primaries := slices.Clone(allowed)
+	if i := slices.Index(primaries, current.Channel); i > 0 {
+		primaries = append(append([]int{current.Channel}, primaries[:i]...), primaries[i+1:]...)
+	}
+	for _, ch := range primaries {
+		frequency, err := ChannelFrequency(current.Band, ch)
+		if err != nil {
+			continue
+		}
+		c := current
+		c.Channel = ch
+		c.Frequency = frequency
+		if current.Band != Band24 {
+			c.Frequency += float64(5 * (BondedChannel(current.Band, ch, int(current.Width)) - ch))
+		}
+		valid := !slices.ContainsFunc(c.subchannels(), func(sub int) bool {
+			_, err := ChannelFrequency(c.Band, sub)
+			return err != nil || bp.dfs == DFSExclude && IsDFS(c.Band, sub)
+		})
+		if !valid || slices.ContainsFunc(candidates, func(o RadioChannel) bool { return o.Frequency == c.Frequency }) {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+	return
+}
+
+// candidatePowers returns the powers in dBm of the range of the band, see PlanBand
+func candidatePowers(current float64, pb PlanBand) (powers []float64) {
+	low, high := Max(current-float64(PLAN_POWER_RANGE), float64(PLAN_MIN_POWER)), Min(current, float64(PLAN_MAX_POWER))
+	if pb.MinPower != nil {
+		low = float64(*pb.MinPower)
+	}
+	if pb.MaxPower != nil {
+		high = float64(*pb.MaxPower)
+	} else {
+		high = Max(high, low)
+	}
+	for p := high; p >= low; p -= float64(PLAN_POWER_STEP) {
+		powers = append(powers, p)
+	}
+	return
+}
+
+// cellScore returns whether the serving radio of the cell hears an interferer, the sum of the shares
+// of the power of the interferers it hears and whether the cell is below the target
+func (bp *bandPlanner) cellScore(cell *planCell) (contended bool, interference float64, uncovered bool) {
+	serving := slices.MaxFunc(cell.signals, func(a, b planSignal) int {
+		return cmp.Compare(a.gain+bp.radios[a.radio].channel.Power, b.gain+bp.radios[b.radio].channel.Power)
+	})
+	servingChannel := bp.radios[serving.radio].channel
+	for _, s := range cell.signals {
+		if s.radio == serving.radio {
+			continue
+		}
+		c := bp.radios[s.radio].channel
+		share, _ := servingChannel.overlap(c)
+		if share == 0 || s.gain+c.Power+10*Log10(share) < INTERFERENCE_DETECT_RSSI {
+			continue
+		}
+		contended = true
+		interference += share
+	}
+	return contended, interference, serving.gain+servingChannel.Power < bp.target
+}
+
+// cellCost is the cost of a cell: the interfering area weighted by the shares plus the uncovered area
+// weighted by PLAN_COVERAGE_WEIGHT
+func (bp *bandPlanner) cellCost(i int) float64 {
+	cell := &bp.cells[i]
+	_, interference, uncovered := bp.cellScore(cell)
+	cost := interference
+	if uncovered {
+		cost += PLAN_COVERAGE_WEIGHT
+	}
+	return cost * cell.weight
+}
+
+// radioCost is the cost of the cells a radio is heard in with the DFS penalty of the radio
+func (bp *bandPlanner) radioCost(r *planRadio) (cost float64) {
+	for _, i := range r.cells {
+		cost += bp.cellCost(i)
+	}
+	if bp.dfs == DFSAvoid && r.channel.isDFS() {
+		cost += PLAN_DFS_PENALTY * bp.area
+	}
+	return
+}
+
+// score evaluates the current channels and powers of the radios
+func (bp *bandPlanner) score() (s PlanScore) {
+	for i := range bp.cells {
+		cell := &bp.cells[i]
+		contended, _, uncovered := bp.cellScore(cell)
+		if contended {
+			s.ContendedArea += cell.weight
+		}
+		if uncovered {
+			s.UncoveredArea += cell.weight
+		}
+		s.Cost += bp.cellCost(i)
+	}
+	for _, r := range bp.radios {
+		if r.planned && r.channel.isDFS() {
+			s.DFSRadios++
+			if bp.dfs == DFSAvoid {
+				s.Cost += PLAN_DFS_PENALTY * bp.area
+			}
+		}
+	}
+	return
+}
+
+// optimize moves every planned radio in turn to the channel, then the power, of the lowest cost
+// until no radio moves or after PLAN_ROUNDS rounds. A radio leaves a channel or power which is not allowed
+// even if the cost grows.
+func (bp *bandPlanner) optimize() {
+	for round := 0; round < PLAN_ROUNDS; round++ {
+		moved := false
+		for _, r := range bp.radios {
+			if !r.planned {
+				continue
+			}
+			if len(r.channels) > 0 {
+				channels := make([]RadioChannel, len(r.channels))
+				for i, c := range r.channels {
+					channels[i] = c
+					channels[i].Power = r.channel.Power
+				}
+				moved = bp.move(r, channels, func(a, b RadioChannel) bool {
+					return a.Channel == b.Channel && a.Frequency == b.Frequency
+				}) || moved
+			}
+			if len(r.powers) > 0 {
+				powers := make([]RadioChannel, len(r.powers))
+				for i, p := range r.powers {
+					powers[i] = r.channel
+					powers[i].Power = p
+				}
+				moved = bp.move(r, powers, func(a, b RadioChannel) bool { return a.Power == b.Power }) || moved
+			}
+		}
+		if !moved {
+			return
+		}
+	}
+}
+
+// move sets the candidate of the lowest cost, the current channel is kept on ties when it is a candidate.
+// It tells whether the radio moved.
+func (bp *bandPlanner) move(r *planRadio, candidates []RadioChannel, same func(a, b RadioChannel) bool) bool {
+	current := r.channel
+	isCurrent := func(c RadioChannel) bool { return same(c, current) }
+	best, bestCost := current, Inf(1)
+	if slices.ContainsFunc(candidates, isCurrent) {
+		bestCost = bp.radioCost(r)
+	}
+	for _, c := range candidates {
+		if isCurrent(c) {
+			continue
+		}
+		r.channel = c
+		if cost := bp.radioCost(r); cost < bestCost-1e-9 {
+			best, bestCost = c, cost
+		}
+	}
+	r.channel = best
+	return best != current
+}
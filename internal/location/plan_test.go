@@ -0,0 +1,134 @@
+package location
+
+import (
+	"location-backend/internal/db"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestChannelPlan(t *testing.T) {
+	// 10 x 4 cells of 1 m, the access point a serves the left half at -50 dBm and hears b at -70 dBm, b the other way round,
+	// both on channel 36 at 20 dBm
+	m := &db.Matrix{CellSize: 1, MinX: 0, MinY: 0, MaxX: 9, MaxY: 3}
+	a, b := uuid.New(), uuid.New()
+	var rows []*db.MatrixRow
+	for y := 0; y <= 3; y++ {
+		for x := 0; x <= 9; x++ {
+			rssiA, rssiB := -50.0, -70.0
+			if x >= 5 {
+				rssiA, rssiB = rssiB, rssiA
+			}
+			rows = append(rows,
+				&db.MatrixRow{SensorID: a, RSSI5: rssiA, X: float64(x), Y: float64(y)},
+				&db.MatrixRow{SensorID: b, RSSI5: rssiB, X: float64(x), Y: float64(y)},
+			)
+		}
+	}
+	channels := make(map[uuid.UUID]RadioChannel)
+	for _, ap := range []uuid.UUID{a, b} {
+		channel, power, width, wifi := 36, 20, "20", "5"
+		radios := []*db.Radio{{ID: uuid.New(), Channel: &channel, Power: &power, Bandwidth: &width, WiFi: &wifi}}
+		for _, c := range AccessPointChannels(&db.AccessPointDetailed{AccessPoint: db.AccessPoint{ID: ap}, Radios: radios}) {
+			channels[c.RadioID] = c
+		}
+	}
+	floors := []PlanFloor{{Matrix: m, Rows: rows, Invisible: RSSI_INVISIBLE}}
+	first := a
+	if b.String() < a.String() {
+		first = b
+	}
+
+	plan, err := NewChannelPlan(floors, channels, []uuid.UUID{a, b}, PlanOptions{Bands: map[string]PlanBand{"5": {Channels: []int{36, 40}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Bands) != 1 || plan.Bands[0].Before.ContendedArea != 40 || plan.Bands[0].After.ContendedArea != 0 {
+		t.Fatalf("bands are %+v, want the contended area going from 40 m² to none", plan.Bands)
+	}
+	if len(plan.Changes) != 1 {
+		t.Fatalf("changes are %+v, want one", plan.Changes)
+	}
+	if c := plan.Changes[0]; c.AccessPointID != first || c.Channel != 36 || c.PlannedChannel != 40 || c.PlannedPower != 20 {
+		t.Errorf("change is %+v, want the first access point moved to channel 40 at the same power", c)
+	}
+
+	// Only b is planned and channel 52 is the only other one, which is excluded as DFS
+	opts := PlanOptions{Bands: map[string]PlanBand{"5": {Channels: []int{36, 52}, DFS: DFSExclude}}}
+	if plan, _ = NewChannelPlan(floors, channels, []uuid.UUID{b}, opts); len(plan.Changes) != 0 {
+		t.Errorf("changes are %+v, want none without allowed channels", plan.Changes)
+	}
+	opts.Bands["5"] = PlanBand{Channels: []int{36, 52}, DFS: DFSAllow}
+	plan, _ = NewChannelPlan(floors, channels, []uuid.UUID{b}, opts)
+	if len(plan.Changes) != 1 || plan.Changes[0].AccessPointID != b || plan.Changes[0].PlannedChannel != 52 || plan.Bands[0].After.DFSRadios != 1 {
+		t.Errorf("changes are %+v, want b moved to DFS channel 52", plan.Changes)
+	}
+
+	if _, err = NewChannelPlan(floors, channels, nil, PlanOptions{Bands: map[string]PlanBand{"5": {DFS: "never"}}}); err == nil {
+		t.Errorf("unknown DFS preference is accepted")
+	}
+}
+
+func TestChannelPlanRadios(t *testing.T) {
+	// The access point a radiates 5 GHz from channel 36 at 20 dBm and from channel 149 at 17 dBm,
+	// b only from channel 149 at 20 dBm and is not planned. The second radio of a leaves the channel of b.
+	m := &db.Matrix{CellSize: 1, MinX: 0, MinY: 0, MaxX: 9, MaxY: 0}
+	a, b := uuid.New(), uuid.New()
+	var rows []*db.MatrixRow
+	for x := 0; x <= 9; x++ {
+		rows = append(rows,
+			&db.MatrixRow{SensorID: a, RSSI5: -50 - 3*float64(x), X: float64(x)},
+			&db.MatrixRow{SensorID: b, RSSI5: -77 + 3*float64(x), X: float64(x)},
+		)
+	}
+	second := uuid.New()
+	channels := make(map[uuid.UUID]RadioChannel)
+	for _, ap := range []*db.AccessPointDetailed{
+		{AccessPoint: db.AccessPoint{ID: a}, Radios: []*db.Radio{
+			{ID: uuid.New(), Channel: ptr(36), Power: ptr(20), WiFi: ptr("5")},
+			{ID: second, Channel: ptr(149), Power: ptr(17), WiFi: ptr("5")},
+		}},
+		{AccessPoint: db.AccessPoint{ID: b}, Radios: []*db.Radio{{ID: uuid.New(), Channel: ptr(149), Power: ptr(20), WiFi: ptr("5")}}},
+	} {
+		for _, c := range AccessPointChannels(ap) {
+			channels[c.RadioID] = c
+		}
+	}
+	floors := []PlanFloor{{Matrix: m, Rows: rows, Invisible: RSSI_INVISIBLE}}
+
+	plan, err := NewChannelPlan(floors, channels, []uuid.UUID{a}, PlanOptions{Bands: map[string]PlanBand{"5": {Channels: []int{36, 149, 157}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Bands) != 1 || plan.Bands[0].Radios != 2 || plan.Bands[0].Before.ContendedArea == 0 || plan.Bands[0].After.ContendedArea != 0 {
+		t.Fatalf("bands are %+v, want both radios of a planned without contention", plan.Bands)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].RadioID != second || plan.Changes[0].PlannedChannel != 157 {
+		t.Errorf("changes are %+v, want the second radio of a moved to channel 157", plan.Changes)
+	}
+}
+
+func TestCheckRadioChange(t *testing.T) {
+	radio6 := &db.Radio{Channel: ptr(197), Power: ptr(20), Bandwidth: ptr("80")}
+	radio5 := &db.Radio{Channel: ptr(36), Power: ptr(20), Bandwidth: ptr("80")}
+	cases := []struct {
+		name    string
+		current *db.Radio
+		planned db.Radio
+		valid   bool
+	}{
+		{"6 GHz keeps its band", radio6, db.Radio{Channel: ptr(37), Power: ptr(18), Bandwidth: ptr("80"), WiFi: ptr("6")}, true},
+		{"6 GHz channel 37 without a band", radio6, db.Radio{Channel: ptr(37), Power: ptr(18), Bandwidth: ptr("80")}, false},
+		{"5 GHz claimed as 6 GHz", radio5, db.Radio{Channel: ptr(37), Power: ptr(18), Bandwidth: ptr("80"), WiFi: ptr("6")}, false},
+		{"6 GHz bonding past the band", radio6, db.Radio{Channel: ptr(229), Power: ptr(18), Bandwidth: ptr("160"), WiFi: ptr("6")}, false},
+		{"5 GHz 80 MHz", radio5, db.Radio{Channel: ptr(149), Power: ptr(18), Bandwidth: ptr("80")}, true},
+		{"negative power", radio5, db.Radio{Channel: ptr(40), Power: ptr(-3), Bandwidth: ptr("80")}, false},
+		{"power above the maximum", radio5, db.Radio{Channel: ptr(40), Power: ptr(PLAN_MAX_POWER + 1), Bandwidth: ptr("80")}, false},
+		{"unknown channel", radio5, db.Radio{Channel: ptr(30), Power: ptr(18), Bandwidth: ptr("80"), WiFi: ptr("5")}, false},
+	}
+	for _, c := range cases {
+		if err := CheckRadioChange(c.current, &c.planned); (err == nil) != c.valid {
+			t.Errorf("%s: error %v, want valid %v", c.name, err, c.valid)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package location
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"location-backend/internal/db"
+	. "math"
+	"strconv"
+	"strings"
+)
+
+// DBD_TO_DBI converts a gain relative to a half-wave dipole to a gain relative to an isotropic antenna
+const DBD_TO_DBI = 2.15
+
+// ParsePlanetPattern parses an antenna pattern in the Planet format, the format of .msi and most .ant vendor files.
+// The file has keyword lines (NAME, FREQUENCY, GAIN, ...) and the HORIZONTAL and VERTICAL sections
+// of "angle attenuation" rows counted in the section header, attenuation is in dB from the peak GAIN.
+// GAIN is in dBd unless followed by dBi. Horizontal angles go clockwise and vertical angles go down
+// as in db.Diagram, rows of fractional angles are skipped since the diagram samples whole degrees.
+// The returned diagram is not validated, see NewAntennaPattern.
+func ParsePlanetPattern(r io.Reader) (d db.Diagram, err error) {
+	var gain float64
+	var hasGain bool
+	var hor, vert map[int]float64
+	var section map[int]float64 // Section of the rows being read
+	var rows int                // Rows left in the section
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if rows > 0 {
+			if len(fields) < 2 {
+				return d, fmt.Errorf("line %d: want an angle and an attenuation", line)
+			}
+			angle, err1 := strconv.ParseFloat(fields[0], 64)
+			loss, err2 := strconv.ParseFloat(fields[1], 64)
+			if err1 != nil || err2 != nil || IsNaN(loss) || IsInf(loss, 0) {
+				return d, fmt.Errorf("line %d: invalid pattern row %q", line, scanner.Text())
+			}
+			rows--
+			if angle != Trunc(angle) {
+				continue
+			}
+			// 360 degrees duplicate 0 degrees in some files
+			section[(int(angle)%360+360)%360] = loss
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "GAIN":
+			if len(fields) < 2 {
+				return d, fmt.Errorf("line %d: GAIN has no value", line)
+			}
+			gain, err = strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return d, fmt.Errorf("line %d: invalid GAIN %q", line, fields[1])
+			}
+			if len(fields) < 3 || !strings.EqualFold(fields[2], "dBi") {
+				gain += DBD_TO_DBI
+			}
+			hasGain = true
+		case "HORIZONTAL", "VERTICAL":
+			if len(fields) < 2 {
+				return d, fmt.Errorf("line %d: %s has no number of rows", line, fields[0])
+			}
+			rows, err = strconv.Atoi(fields[1])
+			if err != nil || rows <= 0 {
+				return d, fmt.Errorf("line %d: invalid number of rows %q", line, fields[1])
+			}
+			section = make(map[int]float64, rows)
+			if strings.EqualFold(fields[0], "HORIZONTAL") {
+				hor = section
+			} else {
+				vert = section
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return d, err
+	}
+
+	if rows > 0 {
+		return d, fmt.Errorf("pattern ends %d rows before the end of its section", rows)
+	}
+	if !hasGain {
+		return d, errors.New("pattern has no GAIN")
+	}
+	if len(hor) == 0 || len(vert) == 0 {
+		return d, errors.New("pattern needs both HORIZONTAL and VERTICAL sections")
+	}
+	if len(hor) != len(vert) {
+		return d, errors.New("HORIZONTAL and VERTICAL sections sample different angles")
+	}
+
+	d.Degree = make(map[string]db.Degree, len(hor))
+	for angle, horLoss := range hor {
+		vertLoss, ok := vert[angle]
+		if !ok {
+			return d, fmt.Errorf("VERTICAL section has no %d degrees", angle)
+		}
+		d.Degree[strconv.Itoa(angle)] = db.Degree{HorGain: gain - horLoss, VertGain: gain - vertLoss}
+	}
+	return d, nil
+}
@@ -0,0 +1,60 @@
+package location
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParsePlanetPatternFiles(t *testing.T) {
+	cases := []struct {
+		file      string
+		hor, vert float64 // Direction of the expected gain in degrees
+		want      float64 // Gain in dBi
+	}{
+		{"testdata/omni.msi", 0, 0, 1.85 + DBD_TO_DBI}, // GAIN in dBd
+		{"testdata/omni.msi", 123, 0, 1.85 + DBD_TO_DBI},
+		{"testdata/omni.msi", 0, 90, 1.85 + DBD_TO_DBI - 25},
+		{"testdata/sector.ant", 0, 0, 14},
+		{"testdata/sector.ant", 30, 0, 14 - 6},
+		{"testdata/sector.ant", 330, 10, 14 - 6 - 5},
+		{"testdata/sector.ant", 180, 0, 14 - 25},
+	}
+	for _, c := range cases {
+		f, err := os.Open(c.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := ParsePlanetPattern(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", c.file, err)
+		}
+		if len(d.Degree) != 360 {
+			t.Errorf("%s: %d angles, want 360", c.file, len(d.Degree))
+		}
+		p, err := NewAntennaPattern(d)
+		if err != nil {
+			t.Fatalf("%s: %v", c.file, err)
+		}
+		if got := p.Gain(c.hor, c.vert); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s: gain at %v/%v degrees is %v dBi, want %v dBi", c.file, c.hor, c.vert, got, c.want)
+		}
+	}
+}
+
+func TestParsePlanetPatternRejectsBrokenFiles(t *testing.T) {
+	for name, file := range map[string]string{
+		"no gain":       "HORIZONTAL 2\n0 0\n180 10\nVERTICAL 2\n0 0\n180 10\n",
+		"truncated":     "GAIN 5 dBi\nHORIZONTAL 2\n0 0\n180 10\nVERTICAL 2\n0 0\n",
+		"no vertical":   "GAIN 5 dBi\nHORIZONTAL 2\n0 0\n180 10\n",
+		"bad row":       "GAIN 5 dBi\nHORIZONTAL 2\n0 0\n180 x\nVERTICAL 2\n0 0\n180 10\n",
+		"other angles":  "GAIN 5 dBi\nHORIZONTAL 2\n0 0\n180 10\nVERTICAL 2\n0 0\n90 10\n",
+		"invalid count": "GAIN 5 dBi\nHORIZONTAL many\n",
+	} {
+		if _, err := ParsePlanetPattern(strings.NewReader(file)); err == nil {
+			t.Errorf("%s: pattern is accepted", name)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package location
+
+import (
+	"errors"
+	"location-backend/internal/db"
+	. "math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Observation is the RSSI of a client device received by a sensor
+type Observation struct {
+	ClientMac string
+	SensorID  uuid.UUID
+	FloorID   uuid.UUID
+	Band      Band
+	RSSI      float64
+	Timestamp time.Time
+	MaxAge    time.Duration // How long the observation stays actual, INFO_AGING_TIME seconds when zero
+}
+
+// expired reports whether the observation is no longer actual at the moment
+func (o Observation) expired(now time.Time) bool {
+	maxAge := o.MaxAge
+	if maxAge <= 0 {
+		maxAge = time.Duration(INFO_AGING_TIME) * time.Second
+	}
+	return o.Timestamp.Add(maxAge).Before(now)
+}
+
+// observationKey identifies the latest observation of a client by a sensor in a band
+type observationKey struct {
+	sensorID uuid.UUID
+	band     Band
+}
+
+// Tracker keeps the latest observations of client devices while they are actual, see Observation.MaxAge
+type Tracker struct {
+	mu           sync.Mutex
+	observations map[string]map[observationKey]Observation
+}
+
+// NewTracker creates an empty observation tracker
+func NewTracker() *Tracker {
+	return &Tracker{observations: make(map[string]map[observationKey]Observation)}
+}
+
+// NormalizeMac converts a MAC address to the lower case form with colons
+func NormalizeMac(mac string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(mac)), "-", ":")
+}
+
+// Observe stores the observation unless a newer one of the same sensor and band is already known
+func (t *Tracker) Observe(o Observation) {
+	o.ClientMac = NormalizeMac(o.ClientMac)
+	key := observationKey{sensorID: o.SensorID, band: o.Band}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	client, ok := t.observations[o.ClientMac]
+	if !ok {
+		client = make(map[observationKey]Observation)
+		t.observations[o.ClientMac] = client
+	}
+	if prev, ok := client[key]; ok && prev.Timestamp.After(o.Timestamp) {
+		return
+	}
+	client[key] = o
+}
+
+// Recent returns the actual observations of the client, aged observations are dropped
+func (t *Tracker) Recent(clientMac string, now time.Time) (obs []Observation) {
+	clientMac = NormalizeMac(clientMac)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	client := t.observations[clientMac]
+	for key, o := range client {
+		if o.expired(now) {
+			delete(client, key)
+			continue
+		}
+		obs = append(obs, o)
+	}
+	if len(client) == 0 {
+		delete(t.observations, clientMac)
+	}
+	return
+}
+
+// Purge drops all aged observations
+func (t *Tracker) Purge(now time.Time) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for mac, client := range t.observations {
+		for key, o := range client {
+			if o.expired(now) {
+				delete(client, key)
+			}
+		}
+		if len(client) == 0 {
+			delete(t.observations, mac)
+		}
+	}
+}
+
+// StrongestFloor returns the floor of the sensor that received the client with the strongest RSSI
+func StrongestFloor(obs []Observation) (floorID uuid.UUID, ok bool) {
+	best := Inf(-1)
+	for _, o := range obs {
+		if o.RSSI > best {
+			best = o.RSSI
+			floorID = o.FloorID
+			ok = true
+		}
+	}
+	return
+}
+
+// fingerprint is the predicted RSSI of every sensor in a matrix point
+type fingerprint struct {
+	x, y float64
+	rssi map[uuid.UUID]MatrixPoint
+}
+
+// Fingerprints is the positioning matrix of a floor prepared for matching
+type Fingerprints struct {
+	MatrixID uuid.UUID
+	FloorID  uuid.UUID
+	CellSize float64
+	cal      Calibration
+	points   []fingerprint
+}
+
+// NewFingerprints groups matrix rows by point, the calibration tunes matching
+func NewFingerprints(m *db.Matrix, rows []*db.MatrixRow, cal Calibration) *Fingerprints {
+	fp := &Fingerprints{MatrixID: m.ID, FloorID: m.FloorID, CellSize: m.CellSize, cal: cal}
+	lastId := -1
+	for _, r := range rows {
+		if r.PointID != lastId {
+			fp.points = append(fp.points, fingerprint{x: r.X, y: r.Y, rssi: make(map[uuid.UUID]MatrixPoint)})
+			lastId = r.PointID
+		}
+		fp.points[len(fp.points)-1].rssi[r.SensorID] = MatrixPoint{rssi24: r.RSSI24, rssi5: r.RSSI5, rssi6: r.RSSI6}
+	}
+	return fp
+}
+
+// Cell is a matrix point matching the observations, coordinates are in meters
+type Cell struct {
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Delta float64 `json:"delta"` // Mean absolute RSSI difference in dB
+}
+
+// Position is the estimated location of a client device, coordinates are in meters
+type Position struct {
+	FloorID  uuid.UUID `json:"floorId"`
+	X        float64   `json:"x"`
+	Y        float64   `json:"y"`
+	Error    float64   `json:"error"`    // Spread of the matching cells around the position in meters
+	Accuracy float64   `json:"accuracy"` // RSSI tolerance in dB the cells were matched with
+	Sensors  int       `json:"sensors"`
+	Cells    []Cell    `json:"cells"`
+}
+
+var ErrNoObservations = errors.New("no recent observations")
+var ErrNoMatch = errors.New("no matrix points match the observations")
+
+// Locate matches the observed RSSI vector against the floor fingerprints.
+// The tolerance grows by 1 dB from MinAccuracy to MaxAccuracy of the calibration until enough points have every
+// observed RSSI within it: ResultLength points, or OneSensorResultLength times more when only one sensor
+// hears the client. The position is the centroid of the points weighted from C_MAX for an exact match
+// down to C_MIN for a match on the tolerance edge.
+func (fp *Fingerprints) Locate(obs []Observation) (pos *Position, err error) {
+	var used []Observation
+	sensors := make(map[uuid.UUID]bool)
+	for _, o := range obs {
+		if o.FloorID == fp.FloorID {
+			used = append(used, o)
+			sensors[o.SensorID] = true
+		}
+	}
+	if len(used) == 0 {
+		return nil, ErrNoObservations
+	}
+
+	cal := fp.cal
+	required := cal.ResultLength
+	if len(sensors) == 1 {
+		required = int(Ceil(float64(cal.ResultLength) * cal.OneSensorResultLength))
+	}
+
+	// Worst absolute difference and mean absolute difference of every point
+	worst := make([]float64, len(fp.points))
+	mean := make([]float64, len(fp.points))
+	for i, p := range fp.points {
+		for _, o := range used {
+			predicted := cal.RSSIInvisible
+			if mp, ok := p.rssi[o.SensorID]; ok {
+				predicted = mp.rssi(o.Band)
+			}
+			delta := Abs(predicted - o.RSSI)
+			worst[i] = Max(worst[i], delta)
+			mean[i] += delta
+		}
+		mean[i] /= float64(len(used))
+	}
+
+	var matched []int
+	var accuracy float64
+	for accuracy = cal.MinAccuracy; accuracy <= cal.MaxAccuracy; accuracy++ {
+		matched = matched[:0]
+		for i := range fp.points {
+			if worst[i] <= accuracy {
+				matched = append(matched, i)
+			}
+		}
+		if len(matched) >= required {
+			break
+		}
+	}
+	if accuracy > cal.MaxAccuracy {
+		accuracy = cal.MaxAccuracy
+	}
+	if len(matched) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	sort.SliceStable(matched, func(a, b int) bool { return mean[matched[a]] < mean[matched[b]] })
+	if len(matched) > required {
+		matched = matched[:required]
+	}
+
+	pos = &Position{FloorID: fp.FloorID, Accuracy: accuracy, Sensors: len(sensors)}
+	var weights float64
+	for _, i := range matched {
+		w := C_MAX - (C_MAX-C_MIN)*Min(mean[i]/accuracy, 1)
+		pos.X += fp.points[i].x * w
+		pos.Y += fp.points[i].y * w
+		weights += w
+		pos.Cells = append(pos.Cells, Cell{X: fp.points[i].x, Y: fp.points[i].y, Delta: Round(mean[i]*10) / 10})
+	}
+	pos.X /= weights
+	pos.Y /= weights
+
+	var spread float64
+	for _, i := range matched {
+		w := C_MAX - (C_MAX-C_MIN)*Min(mean[i]/accuracy, 1)
+		spread += w * (Pow(fp.points[i].x-pos.X, 2) + Pow(fp.points[i].y-pos.Y, 2))
+	}
+	// A position is never more precise than its cell
+	pos.Error = Max(Sqrt(spread/weights), fp.CellSize/2)
+	return
+}
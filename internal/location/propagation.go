@@ -0,0 +1,164 @@
+package location
+
+import (
+	"fmt"
+	. "math"
+)
+
+// PropagationModel predicts the path loss of a link inside a building
+type PropagationModel interface {
+	// Name is the name the model is stored by
+	Name() string
+	// PathLoss returns the loss in dB over distance meters at frequency MHz in the band, crossed walls excluded
+	PathLoss(band Band, frequency, distance float64) float64
+	// CountsWalls reports whether the attenuation of crossed walls is added to PathLoss
+	CountsWalls() bool
+}
+
+const (
+	ModelFreeSpace   = "free_space"
+	ModelLogDistance = "log_distance"
+	ModelITUP1238    = "itu_p1238"
+	ModelCOST231     = "cost231"
+)
+
+// PropagationModels lists the names of the available models
+var PropagationModels = []string{ModelFreeSpace, ModelLogDistance, ModelITUP1238, ModelCOST231}
+
+// DefaultPropagationModel is used for floors and sites without a model, it is the original formula of the engine
+var DefaultPropagationModel PropagationModel = LogDistanceModel{
+	Exponent24: ATTENUATION_FACTOR24,
+	Exponent5:  ATTENUATION_FACTOR5,
+	Exponent6:  ATTENUATION_FACTOR6,
+	Offset24:   PENETRATION_FACTOR24,
+	Offset5:    PENETRATION_FACTOR5,
+	Offset6:    PENETRATION_FACTOR6,
+}
+
+// NewPropagationModel returns the model by its name with the default parameters, an empty name is the default model
+func NewPropagationModel(name string) (PropagationModel, error) {
+	switch name {
+	case "":
+		return DefaultPropagationModel, nil
+	case ModelFreeSpace:
+		return FreeSpaceModel{}, nil
+	case ModelLogDistance:
+		return DefaultPropagationModel, nil
+	case ModelITUP1238:
+		// Office environment, the 6 GHz band is not tabulated and shares the 5 GHz coefficient
+		return ITUP1238Model{N24: 30, N5: 31, N6: 31}, nil
+	case ModelCOST231:
+		return COST231Model{Lc: COST231_LC, LightWall: COST231_LIGHT_WALL, HeavyWall: COST231_HEAVY_WALL, HeavyThickness: COST231_HEAVY_WALL_THICKNESS}, nil
+	}
+	return nil, fmt.Errorf("unknown propagation model %q", name)
+}
+
+// freeSpaceLoss returns the free space path loss in dB, distances below 1 m are taken as 1 m
+func freeSpaceLoss(frequency, distance float64) float64 {
+	return 20*Log10(frequency) + 20*Log10(Max(distance, 1)) - 27.55
+}
+
+// FreeSpaceModel is the Friis free space loss, walls are added on top of it
+type FreeSpaceModel struct{}
+
+func (FreeSpaceModel) Name() string { return ModelFreeSpace }
+
+func (FreeSpaceModel) PathLoss(band Band, frequency, distance float64) float64 {
+	return freeSpaceLoss(frequency, distance)
+}
+
+func (FreeSpaceModel) CountsWalls() bool { return true }
+
+// LogDistanceModel grows the loss by 10 * Exponent dB per decade of distance from the 1 m reference,
+// Offset is added to the loss of each band. Walls are added on top of it.
+type LogDistanceModel struct {
+	Exponent24 float64
+	Exponent5  float64
+	Exponent6  float64
+	Offset24   float64
+	Offset5    float64
+	Offset6    float64
+}
+
+func (LogDistanceModel) Name() string { return ModelLogDistance }
+
+func (m LogDistanceModel) PathLoss(band Band, frequency, distance float64) float64 {
+	switch band {
+	case Band24:
+		return _getFSPL(frequency, m.Exponent24, m.Offset24, distance)
+	case Band5:
+		return _getFSPL(frequency, m.Exponent5, m.Offset5, distance)
+	}
+	return _getFSPL(frequency, m.Exponent6, m.Offset6, distance)
+}
+
+func (LogDistanceModel) CountsWalls() bool { return true }
+
+// ITUP1238Model is the ITU-R P.1238 indoor site-general model for links on the same floor:
+// L = 20 log f + N log d - 28, N is the distance power loss coefficient of the band.
+// The coefficients already include the walls of a typical building, so walls are not added.
+type ITUP1238Model struct {
+	N24 float64
+	N5  float64
+	N6  float64
+}
+
+func (ITUP1238Model) Name() string { return ModelITUP1238 }
+
+func (m ITUP1238Model) PathLoss(band Band, frequency, distance float64) float64 {
+	n := m.N6
+	switch band {
+	case Band24:
+		n = m.N24
+	case Band5:
+		n = m.N5
+	}
+	return 20*Log10(frequency) + n*Log10(Max(distance, 1)) - 28
+}
+
+func (ITUP1238Model) CountsWalls() bool { return false }
+
+// WallModel is a propagation model with its own wall attenuation, it replaces the attenuation of the wall types
+type WallModel interface {
+	// WallAttenuation returns the attenuation in dB of the wall crossed perpendicularly in every band
+	WallAttenuation(w Wall) (attenuation24, attenuation5, attenuation6 float64)
+}
+
+// modelWalls returns the walls with the attenuation of the model when it has its own, see WallModel
+func modelWalls(model PropagationModel, walls []Wall) []Wall {
+	wm, ok := model.(WallModel)
+	if !ok {
+		return walls
+	}
+	modelled := make([]Wall, len(walls))
+	for i, w := range walls {
+		w.Attenuation24, w.Attenuation5, w.Attenuation6 = wm.WallAttenuation(w)
+		modelled[i] = w
+	}
+	return modelled
+}
+
+// COST231Model is the COST-231 multi-wall model: the free space loss plus the constant loss Lc,
+// plus the loss of every crossed wall by its category. Walls thicker than HeavyThickness meters are heavy
+// (load-bearing concrete or brick), the others are light (plasterboard, thin light concrete).
+type COST231Model struct {
+	Lc             float64 // dB
+	LightWall      float64 // dB
+	HeavyWall      float64 // dB
+	HeavyThickness float64 // Meters
+}
+
+func (COST231Model) Name() string { return ModelCOST231 }
+
+func (m COST231Model) PathLoss(band Band, frequency, distance float64) float64 {
+	return freeSpaceLoss(frequency, distance) + m.Lc
+}
+
+func (COST231Model) CountsWalls() bool { return true }
+
+func (m COST231Model) WallAttenuation(w Wall) (float64, float64, float64) {
+	if w.Thickness > m.HeavyThickness {
+		return m.HeavyWall, m.HeavyWall, m.HeavyWall
+	}
+	return m.LightWall, m.LightWall, m.LightWall
+}
@@ -0,0 +1,57 @@
+package location
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPropagationModels(t *testing.T) {
+	cases := []struct {
+		model     string
+		band      Band
+		frequency float64 // MHz
+		distance  float64 // Meters
+		loss      float64 // dB
+	}{
+		{ModelFreeSpace, Band24, 2400, 10, 60.05},
+		{ModelFreeSpace, Band24, 2400, 0.5, 40.05}, // Distances below 1 m are 1 m
+		{ModelFreeSpace, Band5, 5180, 100, 86.74},
+		{ModelLogDistance, Band24, 2400, 10, 64.6},
+		{ModelLogDistance, Band5, 5180, 1, 51.29},
+		{ModelITUP1238, Band24, 2400, 10, 69.6},
+		{ModelITUP1238, Band5, 5180, 10, 77.29},
+		{ModelITUP1238, Band6, 6000, 20, 87.89},
+		{ModelCOST231, Band24, 2400, 10, 60.05 + COST231_LC},
+		{ModelCOST231, Band5, 5180, 100, 86.74 + COST231_LC},
+	}
+	for _, c := range cases {
+		model, err := NewPropagationModel(c.model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loss := model.PathLoss(c.band, c.frequency, c.distance); math.Abs(loss-c.loss) > 0.01 {
+			t.Errorf("%s loss at %v MHz over %v m is %.2f dB, want %.2f dB", c.model, c.frequency, c.distance, loss, c.loss)
+		}
+	}
+}
+
+func TestCOST231Walls(t *testing.T) {
+	walls := []Wall{
+		{Thickness: 0.05, Attenuation24: 2, Attenuation5: 3, Attenuation6: 4},
+		{Thickness: 0.25, Attenuation24: 12, Attenuation5: 15, Attenuation6: 17},
+	}
+	model, _ := NewPropagationModel(ModelCOST231)
+	modelled := modelWalls(model, walls)
+	if w := modelled[0]; w.Attenuation24 != COST231_LIGHT_WALL || w.Attenuation5 != COST231_LIGHT_WALL || w.Attenuation6 != COST231_LIGHT_WALL {
+		t.Errorf("thin wall is %+v, want a light wall", w)
+	}
+	if w := modelled[1]; w.Attenuation24 != COST231_HEAVY_WALL || w.Attenuation6 != COST231_HEAVY_WALL {
+		t.Errorf("thick wall is %+v, want a heavy wall", w)
+	}
+	if walls[0].Attenuation24 != 2 {
+		t.Errorf("walls of the floor are changed")
+	}
+	if free := modelWalls(FreeSpaceModel{}, walls); free[1].Attenuation5 != 15 {
+		t.Errorf("free space model changes the attenuation of the wall types")
+	}
+}
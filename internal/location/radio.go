@@ -0,0 +1,212 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"location-backend/internal/db"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Emission is the signal an emitter radiates in every band. Frequencies are channel centres in MHz and
+// powers are EIRP in dBm, a band with zero frequency is not radiated.
+type Emission struct {
+	Frequency24 float64
+	Frequency5  float64
+	Frequency6  float64
+	Power24     float64
+	Power5      float64
+	Power6      float64
+}
+
+// defaultEmission is a client radiating the default channel of every band with the client power
+func defaultEmission(client Client) Emission {
+	power := float64(client.trSignalPower + client.trAntGain)
+	return Emission{
+		Frequency24: FREQUENCY24,
+		Frequency5:  FREQUENCY5,
+		Frequency6:  FREQUENCY6,
+		Power24:     power,
+		Power5:      power,
+		Power6:      power,
+	}
+}
+
+// band returns the frequency and power of the band
+func (e Emission) band(band Band) (frequency, power float64) {
+	switch band {
+	case Band24:
+		return e.Frequency24, e.Power24
+	case Band5:
+		return e.Frequency5, e.Power5
+	case Band6:
+		return e.Frequency6, e.Power6
+	}
+	return 0, 0
+}
+
+// set stores the frequency and power of the band
+func (e *Emission) set(band Band, frequency, power float64) {
+	switch band {
+	case Band24:
+		e.Frequency24, e.Power24 = frequency, power
+	case Band5:
+		e.Frequency5, e.Power5 = frequency, power
+	case Band6:
+		e.Frequency6, e.Power6 = frequency, power
+	}
+}
+
+// ChannelFrequency returns the centre frequency in MHz of a 20 MHz channel of the band
+func ChannelFrequency(band Band, channel int) (float64, error) {
+	switch band {
+	case Band24:
+		if channel == 14 {
+			return 2484, nil
+		}
+		if channel >= 1 && channel <= 13 {
+			return float64(2407 + 5*channel), nil
+		}
+	case Band5:
+		if channel >= 32 && channel <= 177 {
+			return float64(5000 + 5*channel), nil
+		}
+	case Band6:
+		if channel == 2 {
+			return 5935, nil
+		}
+		if channel >= 1 && channel <= 233 && channel%4 == 1 {
+			return float64(5950 + 5*channel), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown channel %d of band %d", channel, band)
+}
+
+// BondedChannel returns the centre channel of the bonded channel of bandwidthMHz the primary 20 MHz channel belongs to.
+// The 2.4 GHz band has no fixed bonding, its primary channel is returned as is.
+func BondedChannel(band Band, channel, bandwidthMHz int) int {
+	width := bandwidthMHz / 20
+	if width <= 1 || band == Band24 || (width != 2 && width != 4 && width != 8 && width != 16) {
+		return channel
+	}
+	first := 1
+	if band == Band5 {
+		first = 36
+		if channel >= 149 {
+			first = 149
+		}
+	}
+	if channel < first {
+		return channel
+	}
+	// Bonded channels are aligned blocks of 4 channel numbers per 20 MHz
+	block := 4 * width
+	start := first + (channel-first)/block*block
+	return start + 2*(width-1)
+}
+
+// ParseBandwidth parses a channel width given as "20", "80MHz" or "160 MHz", zero is returned for unknown widths
+func ParseBandwidth(s string) int {
+	s = strings.TrimSpace(s)
+	end := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) })
+	if end >= 0 {
+		s = s[:end]
+	}
+	mhz, _ := strconv.Atoi(s)
+	return mhz
+}
+
+// RadioBand returns the band of the radio. The WiFi field is read as a band first ("2.4", "5", "6" or "6GHz"),
+// otherwise the band is guessed by the channel number, which is ambiguous for 6 GHz channels below 14.
+func RadioBand(r *db.Radio) (Band, error) {
+	if r.WiFi != nil {
+		if band, err := ParseBand(*r.WiFi); err == nil {
+			return band, nil
+		}
+	}
+	if r.Channel == nil {
+		return 0, fmt.Errorf("radio %v has no channel", r.ID)
+	}
+	switch ch := *r.Channel; {
+	case ch >= 1 && ch <= 14:
+		return Band24, nil
+	case ch >= 32 && ch <= 177:
+		return Band5, nil
+	case ch >= 181 && ch <= 233:
+		return Band6, nil
+	}
+	return 0, fmt.Errorf("unknown channel %d of radio %v", *r.Channel, r.ID)
+}
+
+// RadioChannel is the spectrum a radio occupies in its band
+type RadioChannel struct {
+	RadioID       uuid.UUID
+	AccessPointID uuid.UUID
+	Band          Band
+	Channel       int     // Primary 20 MHz channel
+	Frequency     float64 // Centre of the (bonded) channel in MHz
+	Width         float64 // Channel width in MHz
+	Power         float64 // EIRP in dBm
+	GuardInterval int     // Nanoseconds, zero when unknown
+}
+
+// NewRadioChannel returns the channel of the radio, a radio without a channel or power is an error.
+// The channel is 20 MHz wide when the bandwidth is unknown and in the 2.4 GHz band, which has no fixed bonding.
+func NewRadioChannel(r *db.Radio) (c RadioChannel, err error) {
+	if r == nil || r.Channel == nil || r.Power == nil {
+		return c, errors.New("radio has no channel or power")
+	}
+	band, err := RadioBand(r)
+	if err != nil {
+		return
+	}
+	frequency, err := ChannelFrequency(band, *r.Channel)
+	if err != nil {
+		return
+	}
+	c = RadioChannel{RadioID: r.ID, Band: band, Channel: *r.Channel, Frequency: frequency, Width: 20, Power: float64(*r.Power)}
+	if r.Bandwidth != nil {
+		width := ParseBandwidth(*r.Bandwidth)
+		// Channel numbers are 5 MHz apart in every band
+		c.Frequency += float64(5 * (BondedChannel(band, *r.Channel, width) - *r.Channel))
+		if band != Band24 && (width == 40 || width == 80 || width == 160) {
+			c.Width = float64(width)
+		}
+	}
+	if r.GuardInterval != nil {
+		c.GuardInterval = *r.GuardInterval
+	}
+	return
+}
+
+// AccessPointChannels returns the channel of every radio of an access point, an access point radiates a band
+// from several radios. Radios without a channel or power are skipped.
+// IsActive is not considered as radios copied from templates are created inactive.
+func AccessPointChannels(ap *db.AccessPointDetailed) (channels []RadioChannel) {
+	for _, r := range ap.Radios {
+		c, err := NewRadioChannel(r)
+		if err != nil {
+			continue
+		}
+		c.AccessPointID = ap.ID
+		channels = append(channels, c)
+	}
+	return
+}
+
+// RadiosEmission builds the emission of an access point from its radios. The strongest radio of a band radiates it
+// on the centre frequency of its (bonded) channel with Radio.Power as EIRP, matrices store the path of the band once
+// per access point and the other radios of the band are weaker by their power, see bandRadios.
+// A band without configured radios is not radiated.
+func RadiosEmission(radios []*db.Radio) (e Emission) {
+	for _, c := range AccessPointChannels(&db.AccessPointDetailed{Radios: radios}) {
+		if frequency, power := e.band(c.Band); frequency != 0 && power >= c.Power {
+			continue
+		}
+		e.set(c.Band, c.Frequency, c.Power)
+	}
+	return
+}
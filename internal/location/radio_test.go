@@ -0,0 +1,89 @@
+package location
+
+import (
+	"location-backend/internal/db"
+	"testing"
+)
+
+func TestChannelFrequency(t *testing.T) {
+	cases := []struct {
+		band      Band
+		channel   int
+		frequency float64 // MHz, zero for an error
+	}{
+		{Band24, 1, 2412},
+		{Band24, 13, 2472},
+		{Band24, 14, 2484},
+		{Band24, 15, 0},
+		{Band5, 36, 5180},
+		{Band5, 165, 5825},
+		{Band5, 30, 0},
+		{Band6, 1, 5955},
+		{Band6, 2, 5935},
+		{Band6, 233, 7115},
+		{Band6, 3, 0},
+	}
+	for _, c := range cases {
+		frequency, err := ChannelFrequency(c.band, c.channel)
+		if c.frequency == 0 {
+			if err == nil {
+				t.Errorf("channel %d of band %d is accepted", c.channel, c.band)
+			}
+			continue
+		}
+		if err != nil || frequency != c.frequency {
+			t.Errorf("channel %d of band %d is %v MHz (%v), want %v MHz", c.channel, c.band, frequency, err, c.frequency)
+		}
+	}
+}
+
+func TestBondedChannel(t *testing.T) {
+	cases := []struct {
+		band           Band
+		channel, width int
+		centre         int
+	}{
+		{Band5, 36, 20, 36},
+		{Band5, 40, 40, 38},
+		{Band5, 44, 80, 42},
+		{Band5, 60, 160, 50},
+		{Band5, 157, 80, 155},
+		{Band5, 36, 30, 36},
+		{Band6, 5, 80, 7},
+		{Band6, 37, 160, 47},
+		{Band24, 6, 40, 6},
+	}
+	for _, c := range cases {
+		if centre := BondedChannel(c.band, c.channel, c.width); centre != c.centre {
+			t.Errorf("channel %d of %d MHz in band %d is centred on %d, want %d", c.channel, c.width, c.band, centre, c.centre)
+		}
+	}
+}
+
+func TestParseBandwidth(t *testing.T) {
+	cases := map[string]int{"20": 20, "80MHz": 80, "160 MHz": 160, " 40 ": 40, "wide": 0, "": 0, "MHz40": 0}
+	for s, want := range cases {
+		if mhz := ParseBandwidth(s); mhz != want {
+			t.Errorf("bandwidth %q is %d MHz, want %d MHz", s, mhz, want)
+		}
+	}
+}
+
+func TestRadiosEmission(t *testing.T) {
+	radios := []*db.Radio{
+		{Channel: ptr(6), Power: ptr(17), Bandwidth: ptr("40")},
+		{Channel: ptr(36), Power: ptr(10)},
+		{Channel: ptr(44), Power: ptr(20), Bandwidth: ptr("80MHz")},
+		{Channel: ptr(149), Power: ptr(20)},
+		{Channel: ptr(37), Power: ptr(23), Bandwidth: ptr("160"), WiFi: ptr("6GHz")},
+		{Channel: ptr(11)},
+	}
+	e := RadiosEmission(radios)
+	want := Emission{Frequency24: 2437, Power24: 17, Frequency5: 5210, Power5: 20, Frequency6: 6185, Power6: 23}
+	if e != want {
+		t.Errorf("emission is %+v, want %+v", e, want)
+	}
+	if e := RadiosEmission(nil); e != (Emission{}) {
+		t.Errorf("emission without radios is %+v, want none", e)
+	}
+}
@@ -0,0 +1,122 @@
+package location
+
+import (
+	"context"
+	"location-backend/internal/db"
+	. "math"
+
+	"github.com/google/uuid"
+)
+
+// NewMatrixHeader describes the grid and the calibration of the input as a floor matrix of the kind
+func NewMatrixHeader(floorID uuid.UUID, kind string, inputData InputData) *db.Matrix {
+	cal := inputData.Calibration()
+	return &db.Matrix{
+		Kind:                 kind,
+		CellSize:             inputData.cell_size_meters,
+		MinX:                 inputData.minX,
+		MinY:                 inputData.minY,
+		MaxX:                 inputData.maxX,
+		MaxY:                 inputData.maxY,
+		FloorID:              floorID,
+		CalibrationProfileID: cal.ProfileID,
+		CalibrationVersion:   cal.Version,
+		GeometryRevision:     inputData.revision,
+	}
+}
+
+// MatrixMatches reports whether the stored matrix has the same grid as the input
+// and was built with the same version of its calibration profile
+func MatrixMatches(m *db.Matrix, inputData InputData) bool {
+	if m == nil {
+		return false
+	}
+	cal := inputData.Calibration()
+	sameProfile := (m.CalibrationProfileID == nil) == (cal.ProfileID == nil) &&
+		(cal.ProfileID == nil || *m.CalibrationProfileID == *cal.ProfileID)
+	return sameProfile && m.CalibrationVersion == cal.Version &&
+		m.CellSize == inputData.cell_size_meters &&
+		m.MinX == inputData.minX && m.MinY == inputData.minY &&
+		m.MaxX == inputData.maxX && m.MaxY == inputData.maxY
+}
+
+// MatrixBatches runs the matrix generator and groups its output into batches of BUFFER_MAX_LENGTH matrix rows.
+// When the context is cancelled the last batch carries the context error.
+func MatrixBatches(ctx context.Context, inputData InputData) <-chan db.MatrixBatch {
+	ch := make(chan db.MatrixBatch)
+	go func() {
+		var batch db.MatrixBatch
+		var lastId = -1
+		for row := range GenerateMatrixRowContext(ctx, inputData) {
+			if lastId != row.id {
+				// A batch is only cut on a point boundary, so every row is written together with its point
+				if len(batch.Rows) >= BUFFER_MAX_LENGTH {
+					ch <- batch
+					batch = db.MatrixBatch{}
+				}
+				batch.Points = append(batch.Points, &PointRow{ID: row.id, X: row.x_m, Y: row.y_m})
+				lastId = row.id
+			}
+			batch.Rows = append(batch.Rows, &MatrixRow{PointID: row.id, SensorID: row.sensorId, RSSI24: row.rssi24, RSSI5: row.rssi5, RSSI6: row.rssi6, Distance: row.distance})
+		}
+		if err := ctx.Err(); err != nil {
+			batch = db.MatrixBatch{Err: err}
+		}
+		if len(batch.Points) > 0 || len(batch.Rows) > 0 || batch.Err != nil {
+			ch <- batch
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+// StoreMatrix generates the floor matrix of the kind and saves it in place of the previous one,
+// the previous matrix is kept if the context is cancelled
+func StoreMatrix(ctx context.Context, svc db.Service, floorID uuid.UUID, kind string, inputData InputData) (m *db.Matrix, err error) {
+	return StoreMatrixProgress(ctx, svc, floorID, kind, inputData, nil)
+}
+
+// ProgressFunc receives the percentage of a computation done
+type ProgressFunc func(percent int)
+
+// StoreMatrixProgress is StoreMatrix reporting the share of the grid points generated to progress after every batch
+func StoreMatrixProgress(ctx context.Context, svc db.Service, floorID uuid.UUID, kind string, inputData InputData, progress ProgressFunc) (m *db.Matrix, err error) {
+	m = NewMatrixHeader(floorID, kind, inputData)
+	batches := MatrixBatches(ctx, inputData)
+	if progress != nil {
+		batches = withProgress(batches, inputData.Cells(), progress)
+	}
+	m.ID, err = svc.SaveMatrix(m, batches)
+	return
+}
+
+// withProgress forwards the batches and reports the share of the total points forwarded
+func withProgress(batches <-chan db.MatrixBatch, total int, progress ProgressFunc) <-chan db.MatrixBatch {
+	ch := make(chan db.MatrixBatch)
+	go func() {
+		defer close(ch)
+		var done int
+		for batch := range batches {
+			ch <- batch
+			done += len(batch.Points)
+			if total > 0 {
+				progress(min(done*100/total, 100))
+			}
+		}
+	}()
+	return ch
+}
+
+// MatrixRowRSSI returns the RSSI of the stored matrix row for the band
+func MatrixRowRSSI(r *db.MatrixRow, band Band) float64 {
+	return MatrixPoint{rssi24: r.RSSI24, rssi5: r.RSSI5, rssi6: r.RSSI6}.rssi(band)
+}
+
+// HeatmapFromRows reduces stored matrix rows to the strongest RSSI per cell
+func HeatmapFromRows(m *db.Matrix, rows []*db.MatrixRow, band Band) *Heatmap {
+	hm := NewHeatmap(band, m.CellSize, m.MinX, m.MinY, m.MaxX, m.MaxY)
+	for _, r := range rows {
+		hm.Add(int(Round(r.X/m.CellSize)), int(Round(r.Y/m.CellSize)), MatrixRowRSSI(r, band))
+	}
+	return hm
+}
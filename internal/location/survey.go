@@ -0,0 +1,196 @@
+package location
+
+import (
+	"location-backend/internal/db"
+	. "math"
+
+	"github.com/google/uuid"
+)
+
+// SurveySample is a measured RSSI paired with the matrix prediction of the same cell
+type SurveySample struct {
+	EmitterID uuid.UUID
+	Band      Band
+	Measured  float64
+	Predicted float64
+	Distance  float64 // Meters between the point and the emitter
+}
+
+// Residuals summarizes measured minus predicted RSSI in dB
+type Residuals struct {
+	Count  int     `json:"count"`
+	Mean   float64 `json:"mean"`
+	RMSE   float64 `json:"rmse"`
+	MaxAbs float64 `json:"maxAbs"`
+}
+
+// SurveyFit is the least squares fit of the predictions of one band to the measurements
+type SurveyFit struct {
+	Band Band `json:"band"`
+	// Offsets in dB to add to the correction factor of every emitter
+	Offsets map[uuid.UUID]float64 `json:"offsets"`
+	// ExponentDelta is added to the path-loss exponent of the band
+	ExponentDelta float64 `json:"exponentDelta"`
+	// Exponent is the fitted path-loss exponent, set when the propagation model has one
+	Exponent *float64  `json:"exponent,omitempty"`
+	Before   Residuals `json:"before"`
+	After    Residuals `json:"after"`
+}
+
+// modelExponent returns the path-loss exponent of the band if the model grows the loss by 10 * exponent dB per decade
+func modelExponent(model PropagationModel, band Band) (float64, bool) {
+	switch m := model.(type) {
+	case LogDistanceModel:
+		switch band {
+		case Band24:
+			return m.Exponent24, true
+		case Band5:
+			return m.Exponent5, true
+		}
+		return m.Exponent6, true
+	case ITUP1238Model:
+		switch band {
+		case Band24:
+			return m.N24 / 10, true
+		case Band5:
+			return m.N5 / 10, true
+		}
+		return m.N6 / 10, true
+	case FreeSpaceModel, COST231Model:
+		return 2, true
+	}
+	return 0, false
+}
+
+// WithExponent returns the model with the path-loss exponent of the band replaced, see SurveyFit.Exponent.
+// Models with a fixed exponent are returned as is and false.
+func WithExponent(model PropagationModel, band Band, exponent float64) (PropagationModel, bool) {
+	switch m := model.(type) {
+	case LogDistanceModel:
+		switch band {
+		case Band24:
+			m.Exponent24 = exponent
+		case Band5:
+			m.Exponent5 = exponent
+		default:
+			m.Exponent6 = exponent
+		}
+		return m, true
+	case ITUP1238Model:
+		switch band {
+		case Band24:
+			m.N24 = 10 * exponent
+		case Band5:
+			m.N5 = 10 * exponent
+		default:
+			m.N6 = 10 * exponent
+		}
+		return m, true
+	}
+	return model, false
+}
+
+// FloorModel returns the model with the path-loss exponents fitted to the survey of the floor, see WithExponent
+func FloorModel(model PropagationModel, f *db.Floor) PropagationModel {
+	exponents := map[Band]*float64{Band24: f.PathLossExponent24, Band5: f.PathLossExponent5, Band6: f.PathLossExponent6}
+	for band, exponent := range exponents {
+		if exponent != nil {
+			model, _ = WithExponent(model, band, *exponent)
+		}
+	}
+	return model
+}
+
+// FitSurvey fits, for every band, a correction offset c of every emitter and a change Δ of the path-loss exponent
+// of the model so that predicted + c - 10 Δ log10(distance) matches the measured RSSI in the least squares sense.
+// The fit is closed-form: Δ is the regression slope of the residuals centred per emitter, the offsets are
+// the mean residuals of every emitter after the slope is applied. Δ stays zero when distances do not vary.
+func FitSurvey(samples []SurveySample, model PropagationModel) (fits []SurveyFit) {
+	for _, band := range []Band{Band24, Band5, Band6} {
+		var bandSamples []SurveySample
+		for _, s := range samples {
+			if s.Band == band {
+				bandSamples = append(bandSamples, s)
+			}
+		}
+		if len(bandSamples) == 0 {
+			continue
+		}
+		fits = append(fits, fitBand(band, bandSamples, model))
+	}
+	return
+}
+
+// fitBand fits the samples of one band
+func fitBand(band Band, samples []SurveySample, model PropagationModel) SurveyFit {
+	// Residual r and regressor g = -10 log10(d) of every sample, the distance is clamped as in the models
+	r := make([]float64, len(samples))
+	g := make([]float64, len(samples))
+	type mean struct{ r, g, n float64 }
+	means := make(map[uuid.UUID]*mean)
+	for i, s := range samples {
+		r[i] = s.Measured - s.Predicted
+		g[i] = -10 * Log10(Max(s.Distance, 1))
+		m, ok := means[s.EmitterID]
+		if !ok {
+			m = &mean{}
+			means[s.EmitterID] = m
+		}
+		m.r += r[i]
+		m.g += g[i]
+		m.n++
+	}
+	for _, m := range means {
+		m.r /= m.n
+		m.g /= m.n
+	}
+
+	var rg, gg float64
+	for i, s := range samples {
+		m := means[s.EmitterID]
+		rg += (r[i] - m.r) * (g[i] - m.g)
+		gg += (g[i] - m.g) * (g[i] - m.g)
+	}
+	var delta float64
+	if gg > 1e-9 {
+		delta = rg / gg
+	}
+
+	fit := SurveyFit{
+		Band:          band,
+		Offsets:       make(map[uuid.UUID]float64, len(means)),
+		ExponentDelta: Round(delta*1000) / 1000,
+		Before:        newResiduals(r),
+	}
+	for id, m := range means {
+		fit.Offsets[id] = Round((m.r-delta*m.g)*100) / 100
+	}
+	after := make([]float64, len(samples))
+	for i, s := range samples {
+		after[i] = r[i] - fit.Offsets[s.EmitterID] - fit.ExponentDelta*g[i]
+	}
+	fit.After = newResiduals(after)
+	if exponent, ok := modelExponent(model, band); ok {
+		exponent = Round((exponent+fit.ExponentDelta)*1000) / 1000
+		fit.Exponent = &exponent
+	}
+	return fit
+}
+
+// newResiduals summarizes the residuals, statistics are rounded to 0.01 dB
+func newResiduals(residuals []float64) (res Residuals) {
+	res.Count = len(residuals)
+	if res.Count == 0 {
+		return
+	}
+	var sum, squares float64
+	for _, r := range residuals {
+		sum += r
+		squares += r * r
+		res.MaxAbs = Max(res.MaxAbs, Abs(r))
+	}
+	res.Mean = Round(sum/float64(res.Count)*100) / 100
+	res.RMSE = Round(Sqrt(squares/float64(res.Count))*100) / 100
+	res.MaxAbs = Round(res.MaxAbs*100) / 100
+	return
+}
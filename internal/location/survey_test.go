@@ -0,0 +1,81 @@
+package location
+
+import (
+	"location-backend/internal/db"
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFitSurveyRecoversOffsetsAndExponent(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	offsets := map[uuid.UUID]float64{a: -8, b: 3.5}
+	const delta = 0.4
+
+	var samples []SurveySample
+	for i, d := range []float64{1.5, 2, 3, 5, 8, 12, 20} {
+		for _, id := range []uuid.UUID{a, b} {
+			predicted := -40 - float64(i)*3
+			// Measurements follow the prediction with a steeper exponent and a constant offset per emitter
+			measured := predicted + offsets[id] - 10*delta*math.Log10(d)
+			samples = append(samples, SurveySample{EmitterID: id, Band: Band5, Measured: measured, Predicted: predicted, Distance: d})
+		}
+	}
+
+	fits := FitSurvey(samples, DefaultPropagationModel)
+	if len(fits) != 1 || fits[0].Band != Band5 {
+		t.Fatalf("expected one 5 GHz fit, got %+v", fits)
+	}
+	fit := fits[0]
+	if math.Abs(fit.ExponentDelta-delta) > 1e-3 {
+		t.Errorf("exponent delta = %v, want %v", fit.ExponentDelta, delta)
+	}
+	for id, want := range offsets {
+		if math.Abs(fit.Offsets[id]-want) > 0.01 {
+			t.Errorf("offset of %v = %v, want %v", id, fit.Offsets[id], want)
+		}
+	}
+	if fit.Exponent == nil || math.Abs(*fit.Exponent-(ATTENUATION_FACTOR5+delta)) > 1e-3 {
+		t.Errorf("exponent = %v, want %v", fit.Exponent, ATTENUATION_FACTOR5+delta)
+	}
+	if fit.Before.RMSE < 1 || fit.After.RMSE > 0.05 {
+		t.Errorf("residuals were not reduced: before %+v, after %+v", fit.Before, fit.After)
+	}
+}
+
+func TestFitSurveyConstantDistanceKeepsExponent(t *testing.T) {
+	id := uuid.New()
+	samples := []SurveySample{
+		{EmitterID: id, Band: Band24, Measured: -50, Predicted: -55, Distance: 4},
+		{EmitterID: id, Band: Band24, Measured: -52, Predicted: -55, Distance: 4},
+	}
+	fit := FitSurvey(samples, FreeSpaceModel{})[0]
+	if fit.ExponentDelta != 0 {
+		t.Errorf("exponent delta = %v, want 0", fit.ExponentDelta)
+	}
+	if fit.Offsets[id] != 4 {
+		t.Errorf("offset = %v, want 4", fit.Offsets[id])
+	}
+	if fit.After.RMSE != 1 {
+		t.Errorf("after RMSE = %v, want 1", fit.After.RMSE)
+	}
+}
+
+func TestFloorModel(t *testing.T) {
+	f := &db.Floor{PathLossExponent5: ptr(3.5)}
+	model := FloorModel(DefaultPropagationModel, f)
+	// Ten meters add 10 * exponent dB over the 1 m reference
+	if gain := model.PathLoss(Band5, 5180, 10) - model.PathLoss(Band5, 5180, 1); math.Abs(gain-35) > 1e-9 {
+		t.Errorf("5 GHz loss grows by %v dB over a decade, want 35 dB", gain)
+	}
+	if model.PathLoss(Band24, 2400, 10) != DefaultPropagationModel.PathLoss(Band24, 2400, 10) {
+		t.Errorf("2.4 GHz loss changes without a fitted exponent")
+	}
+	if exponent, _ := modelExponent(FloorModel(ITUP1238Model{N24: 30, N5: 31, N6: 31}, f), Band5); exponent != 3.5 {
+		t.Errorf("ITU-R P.1238 5 GHz exponent is %v, want 3.5", exponent)
+	}
+	if _, ok := WithExponent(FreeSpaceModel{}, Band5, 3.5); ok {
+		t.Errorf("free space exponent is replaced")
+	}
+}
@@ -0,0 +1,88 @@
+package location
+
+import (
+	. "math"
+	"slices"
+)
+
+// wallIndex is a uniform grid over the walls of a floor. Every grid cell lists, in ascending order,
+// the walls whose bounding box touches it, so a ray only has to be tested against the walls of the grid cells it crosses.
+// Coordinates are in cells of the matrix as the walls themselves.
+type wallIndex struct {
+	walls   []Wall
+	originX float64
+	originY float64
+	size    float64 // Side of a grid cell
+	cols    int
+	rows    int
+	cells   [][]int // Wall indexes of every grid cell, row by row, empty without walls
+}
+
+// newWallIndex builds the index once per floor. The grid has about one cell per wall.
+func newWallIndex(walls []Wall) *wallIndex {
+	index := &wallIndex{walls: walls}
+	if len(walls) == 0 {
+		return index
+	}
+
+	minX, minY := Inf(1), Inf(1)
+	maxX, maxY := Inf(-1), Inf(-1)
+	for _, wall := range walls {
+		minX, maxX = Min(minX, Min(wall.X1, wall.X2)), Max(maxX, Max(wall.X1, wall.X2))
+		minY, maxY = Min(minY, Min(wall.Y1, wall.Y2)), Max(maxY, Max(wall.Y1, wall.Y2))
+	}
+	side := Ceil(Sqrt(float64(len(walls))))
+	index.size = Max(Max(maxX-minX, maxY-minY)/side, 1)
+	index.originX, index.originY = minX, minY
+	index.cols = int((maxX-minX)/index.size) + 1
+	index.rows = int((maxY-minY)/index.size) + 1
+	index.cells = make([][]int, index.cols*index.rows)
+
+	for i, wall := range walls {
+		fromCol, toCol := index.span(index.originX, index.cols, Min(wall.X1, wall.X2), Max(wall.X1, wall.X2))
+		fromRow, toRow := index.span(index.originY, index.rows, Min(wall.Y1, wall.Y2), Max(wall.Y1, wall.Y2))
+		for row := fromRow; row <= toRow; row++ {
+			for col := fromCol; col <= toCol; col++ {
+				index.cells[row*index.cols+col] = append(index.cells[row*index.cols+col], i)
+			}
+		}
+	}
+	return index
+}
+
+// span returns the grid cells, clipped to the grid, that cover the interval [from; to] of one axis.
+// The interval is widened by WALL_INDEX_EPSILON of a grid cell so rounding never drops a cell on a boundary.
+func (index *wallIndex) span(origin float64, count int, from float64, to float64) (int, int) {
+	eps := WALL_INDEX_EPSILON * index.size
+	first := int(Max(Floor((from-eps-origin)/index.size), 0))
+	last := int(Min(Floor((to+eps-origin)/index.size), float64(count-1)))
+	return first, last
+}
+
+// candidates appends to buf, in ascending order and without repetition, the indexes of the walls
+// that the segment (x1; y1)-(x2; y2) may cross. Every wall the segment crosses is among them.
+func (index *wallIndex) candidates(x1, y1, x2, y2 float64, buf []int) []int {
+	buf = buf[:0]
+	if len(index.cells) == 0 {
+		return buf
+	}
+
+	fromRow, toRow := index.span(index.originY, index.rows, Min(y1, y2), Max(y1, y2))
+	for row := fromRow; row <= toRow; row++ {
+		// Part of the segment inside the row, a horizontal segment lies in its rows entirely
+		fromX, toX := Min(x1, x2), Max(x1, x2)
+		if y1 != y2 {
+			bottom := index.originY + float64(row)*index.size
+			top := bottom + index.size
+			xBottom := x1 + (x2-x1)*(Min(Max(bottom, Min(y1, y2)), Max(y1, y2))-y1)/(y2-y1)
+			xTop := x1 + (x2-x1)*(Min(Max(top, Min(y1, y2)), Max(y1, y2))-y1)/(y2-y1)
+			fromX, toX = Min(xBottom, xTop), Max(xBottom, xTop)
+		}
+		fromCol, toCol := index.span(index.originX, index.cols, fromX, toX)
+		for col := fromCol; col <= toCol; col++ {
+			buf = append(buf, index.cells[row*index.cols+col]...)
+		}
+	}
+	slices.Sort(buf)
+	return slices.Compact(buf)
+}
@@ -0,0 +1,165 @@
+package location
+
+import (
+	"location-backend/internal/db"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/google/uuid"
+)
+
+// wallScenario is a random floor with rays between its cells and sensors
+type wallScenario struct {
+	Walls   []Wall
+	Clients [][2]int
+	Sensors []db.Sensor
+	Cutoff  float64
+}
+
+// coordinate returns a random coordinate, often an integer or a half as walls and sensors drawn on a grid are
+func coordinate(rnd *rand.Rand, extent float64) float64 {
+	switch rnd.Intn(3) {
+	case 0:
+		return float64(rnd.Intn(int(extent)))
+	case 1:
+		return float64(rnd.Intn(int(extent)*2)) / 2
+	}
+	return rnd.Float64() * extent
+}
+
+func (wallScenario) Generate(rnd *rand.Rand, size int) reflect.Value {
+	extent := float64(10 + rnd.Intn(90))
+	s := wallScenario{Cutoff: RSII_CUTOFF}
+	if rnd.Intn(2) == 0 {
+		// Without the cutoff every crossed wall counts
+		s.Cutoff = math.Inf(-1)
+	}
+	for i := rnd.Intn(size + 1); i >= 0; i-- {
+		x1, y1 := coordinate(rnd, extent), coordinate(rnd, extent)
+		x2, y2 := coordinate(rnd, extent), coordinate(rnd, extent)
+		switch rnd.Intn(5) {
+		case 0:
+			x2 = x1 // Vertical
+		case 1:
+			y2 = y1 // Horizontal
+		case 2:
+			x2, y2 = x1, y1 // Degenerate
+		}
+		s.Walls = append(s.Walls, Wall{
+			ID:            uuid.New(),
+			X1:            x1,
+			Y1:            y1,
+			X2:            x2,
+			Y2:            y2,
+			Thickness:     0.05 + rnd.Float64()*0.5,
+			Attenuation24: rnd.Float64() * 20,
+			Attenuation5:  rnd.Float64() * 25,
+			Attenuation6:  rnd.Float64() * 30,
+		})
+	}
+	for i := 0; i < 20; i++ {
+		// Clients and sensors may be outside the walls
+		s.Clients = append(s.Clients, [2]int{rnd.Intn(int(extent)+20) - 10, rnd.Intn(int(extent)+20) - 10})
+	}
+	for i := 0; i < 5; i++ {
+		s.Sensors = append(s.Sensors, db.Sensor{
+			X: coordinate(rnd, extent+20) - 10,
+			Y: coordinate(rnd, extent+20) - 10,
+			Z: 2.5,
+		})
+	}
+	return reflect.ValueOf(s)
+}
+
+func TestWallIndexMatchesBruteForce(t *testing.T) {
+	client := Client{zM: CLIENT_HEIGHT_METER}
+	property := func(s wallScenario) bool {
+		index := newWallIndex(s.Walls)
+		var buf []int
+		for _, c := range s.Clients {
+			for _, sensor := range s.Sensors {
+				buf = index.candidates(float64(c[0]), float64(c[1]), sensor.X, sensor.Y, buf)
+				w24, w5, w6 := _getWallsAttenuation(c[0], c[1], s.Walls, sensor, client, CELL_SIZE_METER, s.Cutoff)
+				i24, i5, i6 := _getIndexedWallsAttenuation(c[0], c[1], s.Walls, buf, sensor, client, CELL_SIZE_METER, s.Cutoff)
+				if !same(w24, i24) || !same(w5, i5) || !same(w6, i6) {
+					t.Logf("ray (%d; %d)-(%v; %v): brute force %v %v %v, index %v %v %v", c[0], c[1], sensor.X, sensor.Y, w24, w5, w6, i24, i5, i6)
+					return false
+				}
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 300}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWallIndexCandidatesContainCrossedWalls(t *testing.T) {
+	property := func(s wallScenario) bool {
+		index := newWallIndex(s.Walls)
+		for _, c := range s.Clients {
+			for _, sensor := range s.Sensors {
+				candidates := index.candidates(float64(c[0]), float64(c[1]), sensor.X, sensor.Y, nil)
+				found := make(map[int]bool, len(candidates))
+				for n, i := range candidates {
+					if n > 0 && candidates[n-1] >= i {
+						t.Logf("candidates are not strictly ascending: %v", candidates)
+						return false
+					}
+					found[i] = true
+				}
+				for i, wall := range s.Walls {
+					crossed := _areSegmentsIntersect2D(XYZcoordinate{x: float64(c[0]), y: float64(c[1])}, XYZcoordinate{x: sensor.X, y: sensor.Y},
+						XYZcoordinate{x: wall.X1, y: wall.Y1}, XYZcoordinate{x: wall.X2, y: wall.Y2})
+					if crossed && !found[i] {
+						t.Logf("wall %d %+v crossed by (%d; %d)-(%v; %v) is not a candidate", i, wall, c[0], c[1], sensor.X, sensor.Y)
+						return false
+					}
+				}
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 300}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGenerateMatrixRowWithWallIndexMatchesBruteForce(t *testing.T) {
+	inputData := syntheticInput(40, 30, 3, 60)
+	g := newMatrixGenerator(inputData)
+	cal := inputData.Calibration()
+	for row := range GenerateMatrixRow(inputData) {
+		i := sensorIndex(inputData.sensors, row.sensorId)
+		sensor := inputData.sensors[i]
+		w24, _, _ := _getWallsAttenuation(row.x, row.y, inputData.walls, sensor, g.client, inputData.cell_size_meters, cal.RSSICutoff)
+		free24, _, _ := _getFreeSpaceRSSI(row.x, row.y, g.client, sensor, g.antennas[i], g.emissions[i], g.model,
+			_getDistance(row.x, row.y, g.client, sensor, inputData.cell_size_meters), inputData.cell_size_meters)
+		want := free24 + w24 + cal.CorrectionCoefficient24
+		if want < cal.RSSICutoff {
+			want = cal.RSSIInvisible
+		} else {
+			want = math.Round(want*10) / 10
+		}
+		if !same(row.rssi24, want) {
+			t.Fatalf("cell (%d; %d) of sensor %v: rssi24 %v, brute force %v", row.x, row.y, row.sensorId, row.rssi24, want)
+		}
+	}
+}
+
+// same reports whether the losses are equal, NaN of a degenerate wall equals NaN
+func same(a, b float64) bool {
+	return a == b || math.IsNaN(a) && math.IsNaN(b)
+}
+
+func sensorIndex(sensors []db.Sensor, id uuid.UUID) int {
+	for i, s := range sensors {
+		if s.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,42 @@
+package render
+
+import (
+	"encoding/csv"
+	"io"
+	"location-backend/internal/location"
+	"strconv"
+)
+
+// WriteCoverageCSV writes the strongest and the second strongest access point of every cell of the reports as CSV,
+// a row per cell and band. Coordinates are in meters, fields of an access point not heard are empty.
+func WriteCoverageCSV(w io.Writer, reports []*location.CoverageReport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"band", "x", "y", "first_ap", "first_rssi", "second_ap", "second_rssi"}); err != nil {
+		return err
+	}
+	for _, r := range reports {
+		band := strconv.Itoa(int(r.Band))
+		if r.Band == location.Band24 {
+			band = "2.4"
+		}
+		for _, cell := range r.Cells {
+			record := []string{band, formatFloat(cell.X), formatFloat(cell.Y)}
+			for _, s := range []*location.APSignal{cell.First, cell.Second} {
+				if s == nil {
+					record = append(record, "", "")
+					continue
+				}
+				record = append(record, s.ID.String(), formatFloat(s.RSSI))
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,203 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"github.com/HugoSmits86/nativewebp"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"location-backend/internal/location"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ColorStop maps an RSSI value in dBm to a color
+type ColorStop struct {
+	RSSI  float64
+	Color color.NRGBA
+}
+
+// Ramp is a dBm-to-color gradient sorted by RSSI ascending.
+// Cells weaker than the first stop are left transparent.
+type Ramp []ColorStop
+
+// DefaultRamp goes from blue at -90 dBm through green and yellow to red at -45 dBm
+var DefaultRamp = Ramp{
+	{RSSI: -90, Color: color.NRGBA{R: 0x00, G: 0x00, B: 0xff, A: 0xff}},
+	{RSSI: -75, Color: color.NRGBA{R: 0x00, G: 0xff, B: 0x00, A: 0xff}},
+	{RSSI: -60, Color: color.NRGBA{R: 0xff, G: 0xff, B: 0x00, A: 0xff}},
+	{RSSI: -45, Color: color.NRGBA{R: 0xff, G: 0x00, B: 0x00, A: 0xff}},
+}
+
+// SINRRamp goes from blue at 0 dB through green and yellow to red at 35 dB
+var SINRRamp = Ramp{
+	{RSSI: 0, Color: color.NRGBA{R: 0x00, G: 0x00, B: 0xff, A: 0xff}},
+	{RSSI: 10, Color: color.NRGBA{R: 0x00, G: 0xff, B: 0x00, A: 0xff}},
+	{RSSI: 20, Color: color.NRGBA{R: 0xff, G: 0xff, B: 0x00, A: 0xff}},
+	{RSSI: 35, Color: color.NRGBA{R: 0xff, G: 0x00, B: 0x00, A: 0xff}},
+}
+
+// MCSRamp goes from blue at MCS 0 through green and yellow to red at MCS 11
+var MCSRamp = Ramp{
+	{RSSI: 0, Color: color.NRGBA{R: 0x00, G: 0x00, B: 0xff, A: 0xff}},
+	{RSSI: 4, Color: color.NRGBA{R: 0x00, G: 0xff, B: 0x00, A: 0xff}},
+	{RSSI: 7, Color: color.NRGBA{R: 0xff, G: 0xff, B: 0x00, A: 0xff}},
+	{RSSI: 11, Color: color.NRGBA{R: 0xff, G: 0x00, B: 0x00, A: 0xff}},
+}
+
+// RateRamp goes from blue at 8 Mbps through green and yellow to red at 600 Mbps
+var RateRamp = Ramp{
+	{RSSI: 8, Color: color.NRGBA{R: 0x00, G: 0x00, B: 0xff, A: 0xff}},
+	{RSSI: 50, Color: color.NRGBA{R: 0x00, G: 0xff, B: 0x00, A: 0xff}},
+	{RSSI: 150, Color: color.NRGBA{R: 0xff, G: 0xff, B: 0x00, A: 0xff}},
+	{RSSI: 600, Color: color.NRGBA{R: 0xff, G: 0x00, B: 0x00, A: 0xff}},
+}
+
+// LayerRamp returns the default ramp of a heatmap layer, DefaultRamp for the layers in dBm
+func LayerRamp(layer string) Ramp {
+	switch layer {
+	case location.LayerSINR:
+		return SINRRamp
+	case location.LayerMCS:
+		return MCSRamp
+	case location.LayerRate:
+		return RateRamp
+	}
+	return DefaultRamp
+}
+
+// ParseRamp parses a ramp of comma separated "dBm:RRGGBB" stops, e.g. "-90:0000ff,-67:00ff00,-45:ff0000".
+// An optional alpha component may follow the color as "RRGGBBAA".
+func ParseRamp(s string) (ramp Ramp, err error) {
+	for _, stop := range strings.Split(s, ",") {
+		rssiStr, hex, ok := strings.Cut(strings.TrimSpace(stop), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid color stop %q", stop)
+		}
+		rssi, err := strconv.ParseFloat(rssiStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid color stop rssi %q", rssiStr)
+		}
+		c, err := parseHexColor(hex)
+		if err != nil {
+			return nil, err
+		}
+		ramp = append(ramp, ColorStop{RSSI: rssi, Color: c})
+	}
+	if len(ramp) < 2 {
+		return nil, errors.New("ramp must have at least two color stops")
+	}
+	sort.Slice(ramp, func(i, j int) bool { return ramp[i].RSSI < ramp[j].RSSI })
+	return
+}
+
+func parseHexColor(s string) (c color.NRGBA, err error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 && len(s) != 8 {
+		return c, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return c, fmt.Errorf("invalid color %q", s)
+	}
+	if len(s) == 6 {
+		v = v<<8 | 0xff
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// At returns the color of the RSSI, interpolated linearly between the neighbouring stops
+func (r Ramp) At(rssi float64) color.NRGBA {
+	if len(r) == 0 || math.IsNaN(rssi) || rssi < r[0].RSSI {
+		return color.NRGBA{}
+	}
+	for i := 1; i < len(r); i++ {
+		if rssi <= r[i].RSSI {
+			t := (rssi - r[i-1].RSSI) / (r[i].RSSI - r[i-1].RSSI)
+			return lerp(r[i-1].Color, r[i].Color, t)
+		}
+	}
+	return r[len(r)-1].Color
+}
+
+func lerp(a, b color.NRGBA, t float64) color.NRGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + (float64(y)-float64(x))*t))
+	}
+	return color.NRGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: mix(a.A, b.A)}
+}
+
+// Overlay draws the heatmap over the floor plan image.
+// pxPerCell is the number of image pixels per heatmap cell, opacity in [0; 1] scales the alpha of the ramp colors.
+// If base is nil the heatmap is drawn on a transparent canvas of the heatmap size.
+func Overlay(base image.Image, hm *location.Heatmap, pxPerCell float64, ramp Ramp, opacity float64) *image.NRGBA {
+	var bounds image.Rectangle
+	if base != nil {
+		bounds = base.Bounds()
+	} else {
+		bounds = image.Rect(0, 0, int(math.Ceil(float64(hm.MinX+hm.Width)*pxPerCell)), int(math.Ceil(float64(hm.MinY+hm.Height)*pxPerCell)))
+	}
+	out := image.NewNRGBA(bounds)
+	if base != nil {
+		draw.Draw(out, bounds, base, bounds.Min, draw.Src)
+	}
+	opacity = math.Max(0, math.Min(1, opacity))
+
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			c := ramp.At(hm.Sample(float64(px-bounds.Min.X)/pxPerCell, float64(py-bounds.Min.Y)/pxPerCell))
+			if c.A == 0 {
+				continue
+			}
+			blend(out, px, py, c, opacity)
+		}
+	}
+	return out
+}
+
+// blend alpha-composites the color over the pixel of the image
+func blend(img *image.NRGBA, x, y int, c color.NRGBA, opacity float64) {
+	i := img.PixOffset(x, y)
+	p := img.Pix[i : i+4 : i+4]
+	srcA := float64(c.A) / 0xff * opacity
+	dstA := float64(p[3]) / 0xff
+	outA := srcA + dstA*(1-srcA)
+	if outA == 0 {
+		return
+	}
+	mix := func(src, dst uint8) uint8 {
+		return uint8(math.Round((float64(src)*srcA + float64(dst)*dstA*(1-srcA)) / outA))
+	}
+	p[0], p[1], p[2] = mix(c.R, p[0]), mix(c.G, p[1]), mix(c.B, p[2])
+	p[3] = uint8(math.Round(outA * 0xff))
+}
+
+// ContentType returns the MIME type of the image format
+func ContentType(format string) string {
+	switch format {
+	case "jpeg", "jpg":
+		return "image/jpeg"
+	case "webp":
+		return "image/webp"
+	}
+	return "image/png"
+}
+
+// Encode writes the image in png, jpeg or webp format
+func Encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case "png", "":
+		return png.Encode(w, img)
+	case "jpeg", "jpg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+	case "webp":
+		return nativewebp.Encode(w, img, nil)
+	}
+	return fmt.Errorf("unsupported image format %q", format)
+}
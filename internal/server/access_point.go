@@ -15,6 +15,12 @@ func (s *Fiber) CreateAccessPoint(c *fiber.Ctx) (err error) {
 		log.Error().Err(err).Msg("Failed to parse request body")
 		return err
 	}
+	if !validTilt(ap.VertRotationOffset) {
+		return c.Status(fiber.StatusBadRequest).SendString("vertRotationOffset must be from -90 to 90 degrees")
+	}
+	if err = s.requireSameSite(c, db.ResourceAccessPointType, ap.AccessPointTypeID); err != nil {
+		return
+	}
 	apID, err := s.db.CreateAccessPoint(ap)
 
 	apt, err := s.db.GetAccessPointTypeDetailed(ap.AccessPointTypeID)
@@ -37,6 +43,7 @@ func (s *Fiber) CreateAccessPoint(c *fiber.Ctx) (err error) {
 	if err != nil {
 		return err
 	}
+	s.publishAccessPoint(apID, ActionCreated)
 	return c.JSON(fiber.Map{
 		"id": apID,
 	})
@@ -123,11 +130,18 @@ func (s *Fiber) SoftDeleteAccessPoint(c *fiber.Ctx) (err error) {
 		return
 	}
 	if !isDeleted {
+		// Deleted access points are not retrieved, the access point is read for its floor before the delete
+		ap, err := s.db.GetAccessPoint(accessPointID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to get access point")
+			return err
+		}
 		err = s.db.SoftDeleteAccessPoint(accessPointID)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to soft delete an access point")
-			return
+			return err
 		}
+		s.publishFloor(ap.FloorID, EventAccessPoint, ActionDeleted, ap.ID, ap)
 	} else {
 		return c.Status(fiber.StatusBadRequest).SendString("Access point has already been soft deleted")
 	}
@@ -152,6 +166,7 @@ func (s *Fiber) RestoreAccessPoint(c *fiber.Ctx) (err error) {
 			log.Error().Err(err).Msg("Failed to restore an access point")
 			return
 		}
+		s.publishAccessPoint(accessPointID, ActionRestored)
 	} else {
 		return c.Status(fiber.StatusBadRequest).SendString("Access point has not been soft deleted")
 	}
@@ -165,11 +180,15 @@ func (s *Fiber) PatchUpdateAccessPoint(c *fiber.Ctx) error {
 		log.Error().Err(err).Msg("Failed to parse request body")
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
 	}
+	if !validTilt(ap.VertRotationOffset) {
+		return c.Status(fiber.StatusBadRequest).SendString("vertRotationOffset must be from -90 to 90 degrees")
+	}
 
 	if err := s.db.PatchUpdateAccessPoint(&ap); err != nil {
 		log.Error().Err(err).Msg("Failed to update access point")
 		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update access point")
 	}
+	s.publishAccessPoint(ap.ID, ActionUpdated)
 
 	return c.SendStatus(fiber.StatusOK)
 }
@@ -190,3 +209,8 @@ func (s *Fiber) PatchUpdateAccessPoint(c *fiber.Ctx) error {
 //		"id": rsID,
 //	})
 //}
+
+// validTilt reports whether the downtilt of an antenna, if given, points between straight up and straight down
+func validTilt(tilt *int) bool {
+	return tilt == nil || (*tilt >= -90 && *tilt <= 90)
+}
@@ -14,6 +14,9 @@ func (s *Fiber) CreateAccessPointType(c *fiber.Ctx) (err error) {
 	if err != nil {
 		return err
 	}
+	if err = validateDiagram(apt.Diagram); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
 
 	accessPointTypeID, err := s.db.CreateAccessPointType(apt)
 	if err != nil {
@@ -105,3 +108,43 @@ func (s *Fiber) RestoreAccessPointType(c *fiber.Ctx) (err error) {
 	}
 	return c.SendStatus(fiber.StatusOK)
 }
+
+// PatchUpdateAccessPointType patch updates an access point type based on provided fields
+func (s *Fiber) PatchUpdateAccessPointType(c *fiber.Ctx) error {
+	var input db.AccessPointType
+	if err := c.BodyParser(&input); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
+	}
+	if err := validateDiagram(input.Diagram); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	if err := s.db.PatchUpdateAccessPointType(&input); err != nil {
+		log.Error().Err(err).Msg("Failed to update access point type")
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update access point type")
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// UploadAccessPointTypeDiagram replaces the radiation diagram of an access point type with a pattern file,
+// see diagramFromFile
+func (s *Fiber) UploadAccessPointTypeDiagram(c *fiber.Ctx) (err error) {
+	accessPointTypeID, err := uuid.Parse(c.FormValue("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse access point type uuid")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid access point type UUID")
+	}
+	diagram, err := diagramFromFile(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	if err = s.db.PatchUpdateAccessPointType(&db.AccessPointType{ID: accessPointTypeID, Diagram: diagram}); err != nil {
+		log.Error().Err(err).Msg("Failed to update access point type diagram")
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update access point type")
+	}
+	return c.JSON(fiber.Map{
+		"data": diagram,
+	})
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/db"
+)
+
+// CreateCalibrationProfile creates the calibration profile of a site, a site has at most one live profile
+func (s *Fiber) CreateCalibrationProfile(c *fiber.Ctx) (err error) {
+	cp := new(db.CalibrationProfile)
+	err = c.BodyParser(cp)
+	if err != nil {
+		return err
+	}
+	if !validCalibrationProfile(cp) {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid calibration profile")
+	}
+
+	profileID, err := s.db.CreateCalibrationProfile(cp)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Failed to create calibration profile, the site may already have one")
+	}
+	return c.JSON(fiber.Map{
+		"id": profileID,
+	})
+}
+
+// GetCalibrationProfile retrieves a calibration profile
+func (s *Fiber) GetCalibrationProfile(c *fiber.Ctx) (err error) {
+	profileID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse calibration profile uuid")
+		return
+	}
+	cp, err := s.db.GetCalibrationProfile(profileID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get calibration profile")
+		return
+	}
+	return c.JSON(fiber.Map{
+		"data": cp,
+	})
+}
+
+// GetCalibrationProfiles retrieves the calibration profiles of a site
+func (s *Fiber) GetCalibrationProfiles(c *fiber.Ctx) (err error) {
+	siteUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse site uuid")
+		return
+	}
+	cps, err := s.db.GetCalibrationProfiles(siteUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get calibration profiles")
+		return
+	}
+	return c.JSON(fiber.Map{
+		"data": cps,
+	})
+}
+
+// SoftDeleteCalibrationProfile soft delete a calibration profile
+func (s *Fiber) SoftDeleteCalibrationProfile(c *fiber.Ctx) (err error) {
+	profileID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse calibration profile uuid")
+		return
+	}
+	isDeleted, err := s.db.IsCalibrationProfileSoftDeleted(profileID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get soft deleted calibration profile")
+		return
+	}
+	if !isDeleted {
+		err = s.db.SoftDeleteCalibrationProfile(profileID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to soft delete a calibration profile")
+			return
+		}
+	} else {
+		return c.Status(fiber.StatusBadRequest).SendString("Calibration profile has already been soft deleted")
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// RestoreCalibrationProfile restore a calibration profile
+func (s *Fiber) RestoreCalibrationProfile(c *fiber.Ctx) (err error) {
+	profileID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse calibration profile uuid")
+		return
+	}
+	isDeleted, err := s.db.IsCalibrationProfileSoftDeleted(profileID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get soft deleted calibration profile")
+		return
+	}
+	if isDeleted {
+		err = s.db.RestoreCalibrationProfile(profileID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to restore a calibration profile")
+			return c.Status(fiber.StatusBadRequest).SendString("Failed to restore calibration profile, the site may already have another one")
+		}
+	} else {
+		return c.Status(fiber.StatusBadRequest).SendString("Calibration profile has not been soft deleted")
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// PatchUpdateCalibrationProfile patch updates a calibration profile based on provided fields, its version is incremented
+func (s *Fiber) PatchUpdateCalibrationProfile(c *fiber.Ctx) error {
+	var input db.CalibrationProfile
+	if err := c.BodyParser(&input); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
+	}
+	// The patch is validated merged with the stored profile, a maximum accuracy below the stored minimum is invalid too
+	current, err := s.db.GetCalibrationProfile(input.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).SendString("Calibration profile not found")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update calibration profile")
+	}
+	if !validCalibrationProfile(mergeCalibrationProfile(current, &input)) {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid calibration profile")
+	}
+	log.Debug().Msgf("Updating calibration profile: %v", input)
+	err = s.db.PatchUpdateCalibrationProfile(&input)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).SendString("Calibration profile not found")
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to update calibration profile")
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update calibration profile")
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// mergeCalibrationProfile returns the profile with the provided fields of the patch applied
+func mergeCalibrationProfile(cp, patch *db.CalibrationProfile) *db.CalibrationProfile {
+	merged := *cp
+	for _, field := range []struct{ dst, src **float64 }{
+		{&merged.RSSICutoff, &patch.RSSICutoff},
+		{&merged.RSSIInvisible, &patch.RSSIInvisible},
+		{&merged.CorrectionCoefficient24, &patch.CorrectionCoefficient24},
+		{&merged.CorrectionCoefficient5, &patch.CorrectionCoefficient5},
+		{&merged.CorrectionCoefficient6, &patch.CorrectionCoefficient6},
+		{&merged.MinAccuracy, &patch.MinAccuracy},
+		{&merged.MaxAccuracy, &patch.MaxAccuracy},
+		{&merged.OneSensorResultLength, &patch.OneSensorResultLength},
+	} {
+		if *field.src != nil {
+			*field.dst = *field.src
+		}
+	}
+	if patch.CalculateWalls != nil {
+		merged.CalculateWalls = patch.CalculateWalls
+	}
+	if patch.ResultLength != nil {
+		merged.ResultLength = patch.ResultLength
+	}
+	if patch.InfoAgingTime != nil {
+		merged.InfoAgingTime = patch.InfoAgingTime
+	}
+	return &merged
+}
+
+// validCalibrationProfile checks the provided fields, accuracies, result lengths and the aging time must be positive
+func validCalibrationProfile(cp *db.CalibrationProfile) bool {
+	if (cp.MinAccuracy != nil && *cp.MinAccuracy <= 0) || (cp.MaxAccuracy != nil && *cp.MaxAccuracy <= 0) {
+		return false
+	}
+	if cp.MinAccuracy != nil && cp.MaxAccuracy != nil && *cp.MaxAccuracy < *cp.MinAccuracy {
+		return false
+	}
+	if cp.ResultLength != nil && *cp.ResultLength <= 0 {
+		return false
+	}
+	if cp.OneSensorResultLength != nil && *cp.OneSensorResultLength <= 0 {
+		return false
+	}
+	return cp.InfoAgingTime == nil || *cp.InfoAgingTime > 0
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/centrifugo"
+	"location-backend/internal/config"
+	"location-backend/internal/db"
+	"location-backend/internal/location"
+	"strings"
+	"time"
+)
+
+// FloorEvent is a message published to a floor channel
+type FloorEvent struct {
+	Type   string    `json:"type"`   // position, accessPoint, wall or obstacle
+	Action string    `json:"action"` // updated, created, deleted or restored
+	ID     any       `json:"id"`     // Client MAC for positions, entity uuid otherwise
+	Data   any       `json:"data,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+const (
+	EventPosition    = "position"
+	EventAccessPoint = "accessPoint"
+	EventWall        = "wall"
+	EventObstacle    = "obstacle"
+
+	ActionCreated  = "created"
+	ActionUpdated  = "updated"
+	ActionDeleted  = "deleted"
+	ActionRestored = "restored"
+)
+
+// publishFloor queues the event to the floor channel
+func (s *Fiber) publishFloor(floorID uuid.UUID, eventType, action string, id, data any) {
+	s.centrifugo.PublishAsync(centrifugo.FloorChannel(floorID), FloorEvent{
+		Type:   eventType,
+		Action: action,
+		ID:     id,
+		Data:   data,
+		Time:   time.Now(),
+	})
+}
+
+// publishAccessPoint publishes the current state of the access point to its floor channel
+func (s *Fiber) publishAccessPoint(accessPointID uuid.UUID, action string) {
+	if !s.centrifugo.Enabled() {
+		return
+	}
+	ap, err := s.db.GetAccessPoint(accessPointID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get access point to publish")
+		return
+	}
+	s.publishFloor(ap.FloorID, EventAccessPoint, action, ap.ID, ap)
+}
+
+// publishWall publishes the current state of the wall to its floor channel
+func (s *Fiber) publishWall(wallID uuid.UUID, action string) {
+	if !s.centrifugo.Enabled() {
+		return
+	}
+	w, err := s.db.GetWall(wallID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get wall to publish")
+		return
+	}
+	s.publishFloor(w.FloorID, EventWall, action, w.ID, w)
+}
+
+// publishObstacle publishes the current state of the obstacle to its floor channel
+func (s *Fiber) publishObstacle(obstacleID uuid.UUID, action string) {
+	if !s.centrifugo.Enabled() {
+		return
+	}
+	o, err := s.db.GetObstacle(obstacleID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get obstacle to publish")
+		return
+	}
+	s.publishFloor(o.FloorID, EventObstacle, action, o.ID, o)
+}
+
+// queuePositions queues the clients to publish their positions, clients observed again before the publisher
+// gets to them are published once
+func (s *Fiber) queuePositions(clientMacs map[string]bool) {
+	s.positionsMu.Lock()
+	for mac := range clientMacs {
+		s.positionsPending[mac] = true
+	}
+	s.positionsMu.Unlock()
+	select {
+	case s.positionsReady <- struct{}{}:
+	default:
+	}
+}
+
+// runPositionPublisher publishes the positions of the queued clients, one batch at a time
+func (s *Fiber) runPositionPublisher() {
+	for range s.positionsReady {
+		s.positionsMu.Lock()
+		macs := make([]string, 0, len(s.positionsPending))
+		for mac := range s.positionsPending {
+			macs = append(macs, mac)
+		}
+		clear(s.positionsPending)
+		s.positionsMu.Unlock()
+		s.publishPositions(macs)
+	}
+}
+
+// publishPositions locates the clients and publishes their positions to the floor channels
+func (s *Fiber) publishPositions(clientMacs []string) {
+	if !s.centrifugo.Enabled() {
+		return
+	}
+	now := time.Now()
+	for _, mac := range clientMacs {
+		obs := s.tracker.Recent(mac, now)
+		floorUUID, ok := location.StrongestFloor(obs)
+		if !ok {
+			continue
+		}
+		f, fp, _, err := s.getFingerprints(floorUUID)
+		if errors.Is(err, errFingerprintsPending) {
+			continue
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to get positioning matrix")
+			continue
+		}
+		pos, err := fp.Locate(obs)
+		if err != nil {
+			continue
+		}
+		toFloorPixels(pos, *f.Scale)
+		s.publishFloor(floorUUID, EventPosition, ActionUpdated, location.NormalizeMac(mac), pos)
+	}
+}
+
+// userSubject returns the user id of the request JWT as the Centrifugo user
+func userSubject(c *fiber.Ctx) string {
+	userUUID, err := currentUserID(c)
+	if err != nil {
+		return ""
+	}
+	return userUUID.String()
+}
+
+// GetCentrifugoToken issues a Centrifugo connection token for the current user
+func (s *Fiber) GetCentrifugoToken(c *fiber.Ctx) (err error) {
+	t, err := centrifugo.ConnectionToken(config.App.JWTSecret, userSubject(c), centrifugo.TokenTTL)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to sign connection token")
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	return c.JSON(fiber.Map{"token": t})
+}
+
+// GetCentrifugoSubscriptionToken issues a token to subscribe the current user to the floor channel given by the channel query param
+func (s *Fiber) GetCentrifugoSubscriptionToken(c *fiber.Ctx) (err error) {
+	channel := c.Query("channel")
+	floorID, found := strings.CutPrefix(channel, "floor:")
+	if !found {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid channel")
+	}
+	floorUUID, err := uuid.Parse(floorID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid channel")
+	}
+	if err = s.requireSiteRole(c, db.ResourceFloor, floorUUID, db.RoleViewer); err != nil {
+		return
+	}
+	t, err := centrifugo.SubscriptionToken(config.App.JWTSecret, userSubject(c), channel, centrifugo.TokenTTL)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to sign subscription token")
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	return c.JSON(fiber.Map{"token": t})
+}
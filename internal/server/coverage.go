@@ -0,0 +1,84 @@
+package server
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/location"
+	"location-backend/internal/render"
+	"strconv"
+	"strings"
+)
+
+// GetFloorCoverage reports the share of the floor area covered above the RSSI thresholds, the coverage holes
+// and the two strongest access points of every cell, per band. Query params: id, band (all bands when empty),
+// thresholds (comma separated dBm), minHoleArea (square meters), cellSize, cells (include the cells in JSON)
+// and format (json or csv, the CSV lists the cells). A floor without a coverage matrix is answered Accepted
+// with the ids of the queued matrix jobs, see sendMatrixPending.
+func (s *Fiber) GetFloorCoverage(c *fiber.Ctx) (err error) {
+	floorUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse floor uuid")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid floor UUID")
+	}
+	bands := []location.Band{location.Band24, location.Band5, location.Band6}
+	if bandStr := c.Query("band"); bandStr != "" {
+		band, err := location.ParseBand(bandStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid band")
+		}
+		bands = []location.Band{band}
+	}
+	thresholds := location.COVERAGE_THRESHOLDS
+	if thresholdsStr := c.Query("thresholds"); thresholdsStr != "" {
+		thresholds = nil
+		for _, t := range strings.Split(thresholdsStr, ",") {
+			threshold, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).SendString("Invalid thresholds")
+			}
+			thresholds = append(thresholds, threshold)
+		}
+	}
+	minHoleArea := c.QueryFloat("minHoleArea", location.COVERAGE_MIN_HOLE_AREA)
+	if minHoleArea < 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("minHoleArea must not be negative")
+	}
+	format := strings.ToLower(c.Query("format", "json"))
+	if format != "json" && format != "csv" {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid format")
+	}
+	cellSize := c.QueryFloat("cellSize", location.CELL_SIZE_METER)
+
+	f, inputData, m, rows, err := s.getFloorCoverageMatrix(floorUUID, cellSize)
+	if err != nil {
+		return sendMatrixPending(c, err)
+	}
+	invisible := inputData.Calibration().RSSIInvisible
+	reports := make([]*location.CoverageReport, 0, len(bands))
+	for _, band := range bands {
+		reports = append(reports, location.NewCoverageReport(m, rows, band, thresholds, minHoleArea, invisible))
+	}
+
+	if format == "csv" {
+		c.Set("X-Coverage-Current", strconv.FormatBool(!m.Dirty))
+		c.Set(fiber.HeaderContentType, "text/csv")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="coverage-`+f.ID.String()+`.csv"`)
+		if err = render.WriteCoverageCSV(c.Response().BodyWriter(), reports); err != nil {
+			log.Error().Err(err).Msg("Failed to write coverage CSV")
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		return
+	}
+	if !c.QueryBool("cells") {
+		for _, r := range reports {
+			r.Cells = nil
+		}
+	}
+	return c.JSON(fiber.Map{
+		"data":             reports,
+		"current":          !m.Dirty,
+		"geometryRevision": f.GeometryRevision,
+		"matrixRevision":   m.GeometryRevision,
+	})
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
@@ -25,6 +26,12 @@ func (s *Fiber) CreateFloor(c *fiber.Ctx) (err error) {
 	if err != nil {
 		return err
 	}
+	if err = validatePropagationModel(f.PropagationModel); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	if err = validateFloorLevel(f); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
 
 	floorID, err := s.db.CreateFloor(f)
 	if err != nil {
@@ -162,6 +169,35 @@ func (s *Fiber) PatchUpdateFloor(c *fiber.Ctx) error {
 		f.Scale = nil
 	}
 
+	// An empty model makes the floor use the model of its site
+	if model, ok := form.Value["propagationModel"]; ok {
+		f.PropagationModel = &model[0]
+		if err = validatePropagationModel(f.PropagationModel); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+	}
+
+	// Level of the floor in meters and attenuation of its slab in dB
+	levelFields := map[string]**float64{
+		"elevation":         &f.Elevation,
+		"height":            &f.Height,
+		"slabAttenuation24": &f.SlabAttenuation24,
+		"slabAttenuation5":  &f.SlabAttenuation5,
+		"slabAttenuation6":  &f.SlabAttenuation6,
+	}
+	for field, value := range levelFields {
+		if v, ok := form.Value[field]; ok && v[0] != "" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v[0]), 64)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).SendString("Invalid " + field)
+			}
+			*value = &parsed
+		}
+	}
+	if err = validateFloorLevel(f); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
 	files := form.File["image"]
 	if len(files) > 0 {
 		file, err := files[0].Open()
@@ -259,3 +295,16 @@ func saveImage(filePath string, img image.Image) error {
 
 	return nil
 }
+
+// validateFloorLevel checks the level and slab of a floor given in a request, unset values are valid
+func validateFloorLevel(f *db.Floor) error {
+	if f.Height != nil && *f.Height <= 0 {
+		return errors.New("height must be positive")
+	}
+	for _, a := range []*float64{f.SlabAttenuation24, f.SlabAttenuation5, f.SlabAttenuation6} {
+		if a != nil && *a < 0 {
+			return errors.New("slab attenuation must not be negative")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"image"
+	"location-backend/internal/config"
+	"location-backend/internal/db"
+	"location-backend/internal/location"
+	"location-backend/internal/render"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// GetFloorHeatmap computes the predicted coverage of a floor for one band.
+// Current is false when the heatmap comes from a stale matrix that is being recomputed, a floor without a matrix
+// is answered Accepted with the ids of the queued matrix jobs, see sendMatrixPending.
+func (s *Fiber) GetFloorHeatmap(c *fiber.Ctx) (err error) {
+	f, m, hm, err := s.floorHeatmap(c)
+	if err != nil {
+		return sendMatrixPending(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"data":             hm,
+		"current":          !m.Dirty,
+		"geometryRevision": f.GeometryRevision,
+		"matrixRevision":   m.GeometryRevision,
+	})
+}
+
+// GetFloorHeatmapImage renders the predicted coverage of a floor over its plan image,
+// the X-Heatmap-Current header tells whether the heatmap is current, see GetFloorHeatmap
+func (s *Fiber) GetFloorHeatmapImage(c *fiber.Ctx) (err error) {
+	format := strings.ToLower(c.Query("format", "png"))
+	if format != "png" && format != "jpeg" && format != "jpg" && format != "webp" {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid format")
+	}
+	layer := c.Query("layer", location.LayerRSSI)
+	ramp := render.LayerRamp(layer)
+	rampStr := c.Query("ramp")
+	if rampStr == "" && (layer == location.LayerRSSI || layer == location.LayerInterference) {
+		rampStr = config.App.HeatmapRamp
+	}
+	if rampStr != "" {
+		ramp, err = render.ParseRamp(rampStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+	}
+	opacity := c.QueryFloat("opacity", 0.6)
+
+	f, m, hm, err := s.floorHeatmap(c)
+	if err != nil {
+		return sendMatrixPending(c, err)
+	}
+	c.Set("X-Heatmap-Current", strconv.FormatBool(!m.Dirty))
+	c.Set("X-Geometry-Revision", strconv.FormatInt(f.GeometryRevision, 10))
+	base, err := loadFloorImage(f)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load floor image")
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	img := render.Overlay(base, hm, *f.Scale*hm.CellSize, ramp, opacity)
+
+	c.Set(fiber.HeaderContentType, render.ContentType(format))
+	if err = render.Encode(c.Response().BodyWriter(), img, format); err != nil {
+		log.Error().Err(err).Msg("Failed to encode heatmap image")
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	return
+}
+
+// floorHeatmap computes the heatmap of the floor requested by id, band, layer and cellSize query params.
+// Layers other than the RSSI come from the interference map of the floor, see location.InterferenceMap.
+func (s *Fiber) floorHeatmap(c *fiber.Ctx) (f *db.Floor, m *db.Matrix, hm *location.Heatmap, err error) {
+	floorUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse floor uuid")
+		return nil, nil, nil, fiber.NewError(fiber.StatusBadRequest, "Invalid floor UUID")
+	}
+	band, err := location.ParseBand(c.Query("band", "5"))
+	if err != nil {
+		return nil, nil, nil, fiber.NewError(fiber.StatusBadRequest, "Invalid band")
+	}
+	cellSize := c.QueryFloat("cellSize", location.CELL_SIZE_METER)
+	layer := c.Query("layer", location.LayerRSSI)
+	if layer == location.LayerRSSI {
+		return s.computeFloorHeatmap(floorUUID, band, cellSize)
+	}
+	if err = location.ValidateLayer(layer); err != nil {
+		return nil, nil, nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	f, m, im, err := s.computeFloorInterference(floorUUID, band, cellSize)
+	if err != nil {
+		return
+	}
+	hm, err = im.Layer(layer)
+	return
+}
+
+// computeFloorHeatmap computes the heatmap of the floor from its coverage matrix, a stale matrix is used
+// while it is being recomputed, see ensureMatrix
+func (s *Fiber) computeFloorHeatmap(floorUUID uuid.UUID, band location.Band, cellSize float64) (f *db.Floor, m *db.Matrix, hm *location.Heatmap, err error) {
+	f, _, m, rows, err := s.getFloorCoverageMatrix(floorUUID, cellSize)
+	if err != nil {
+		return
+	}
+	hm = location.HeatmapFromRows(m, rows, band)
+	return
+}
+
+// getFloorCoverageMatrix returns the floor with the input and the rows of its coverage matrix, a stale matrix is used
+// while it is being recomputed, see ensureMatrix
+func (s *Fiber) getFloorCoverageMatrix(floorUUID uuid.UUID, cellSize float64) (f *db.Floor, inputData location.InputData, m *db.Matrix, rows []*db.MatrixRow, err error) {
+	f, err = s.getFloorGeometry(floorUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get floor")
+		return
+	}
+	inputData, err = s.floorInputData(f, db.MatrixKindCoverage, cellSize)
+	if err != nil {
+		return
+	}
+	m, rows, err = s.getFloorMatrix(f.ID, db.MatrixKindCoverage, inputData, false)
+	if err != nil && !errors.As(err, new(*matrixPendingError)) {
+		log.Error().Err(err).Msg("Failed to get coverage matrix")
+	}
+	return
+}
+
+// floorInputData builds the generator input of the matrix kind for the floor with its propagation model
+// and calibration. Invalid floor geometry is reported as a bad request.
+func (s *Fiber) floorInputData(f *db.Floor, kind string, cellSize float64) (inputData location.InputData, err error) {
+	width, height := floorImageSize(f)
+	if kind == db.MatrixKindSensors {
+		inputData, err = location.NewFloorSensorsInputData(f, cellSize, width, height)
+	} else {
+		if f.AdjacentFloors, err = s.getAdjacentFloors(f); err != nil {
+			return
+		}
+		inputData, err = location.NewFloorInputData(f, cellSize, width, height)
+	}
+	if err != nil {
+		return inputData, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	model, err := s.floorPropagationModel(f.ID)
+	if err != nil {
+		return
+	}
+	cal, err := s.floorCalibration(f.ID)
+	if err != nil {
+		return
+	}
+	return inputData.WithModel(location.FloorModel(model, f)).WithCalibration(cal).WithWorkers(config.App.MatrixWorkers), nil
+}
+
+// matrixPendingError is returned while missing matrices of a floor are generated by queued matrix jobs,
+// see sendMatrixPending
+type matrixPendingError struct {
+	JobIDs []uuid.UUID
+}
+
+func (e *matrixPendingError) Error() string {
+	return "matrix is being generated, retry later"
+}
+
+// sendMatrixPending answers Accepted with the ids of the jobs to poll when err is a *matrixPendingError,
+// other errors are returned as is
+func sendMatrixPending(c *fiber.Ctx, err error) error {
+	var pending *matrixPendingError
+	if errors.As(err, &pending) {
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"jobIds":  pending.JobIDs,
+			"current": false,
+		})
+	}
+	return err
+}
+
+// ensureMatrix returns the stored matrix of the kind of the floor, requests never generate matrices.
+// A missing matrix is queued as a matrix job and a *matrixPendingError is returned. A matrix built for another
+// grid or calibration or a dirty one is queued too and returned marked dirty until the job replaces it,
+// unless requireCurrent is set and the error is returned as well. Jobs generate matrices with generateFloorMatrix.
+func (s *Fiber) ensureMatrix(floorUUID uuid.UUID, kind string, inputData location.InputData, requireCurrent bool) (m *db.Matrix, err error) {
+	m, err = s.db.GetMatrix(floorUUID, kind, inputData.CellSize())
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return
+	}
+	stored := err == nil
+	if stored && !m.Dirty && location.MatrixMatches(m, inputData) {
+		return m, nil
+	}
+	// A dirty matrix is queued by the invalidation when MATRIX_RECOMPUTE_DELAY is set
+	if stored && m.Dirty && !requireCurrent && config.App.MatrixRecomputeDelay > 0 {
+		return m, nil
+	}
+	jobID, err := s.queueMatrix(floorUUID, kind, inputData.CellSize())
+	if err != nil {
+		return nil, err
+	}
+	if !stored || requireCurrent {
+		return nil, &matrixPendingError{JobIDs: []uuid.UUID{jobID}}
+	}
+	m.Dirty = true
+	return m, nil
+}
+
+// queueMatrix queues the generation of the matrix of the kind and cell size of the floor, a job already
+// queued for it is reused and run at once, see db.EnqueueDebouncedJob
+func (s *Fiber) queueMatrix(floorUUID uuid.UUID, kind string, cellSize float64) (jobID uuid.UUID, err error) {
+	params, _ := json.Marshal(matrixJobParams{Kind: kind, CellSize: cellSize, OnlyDirty: true})
+	key := db.MatrixRecomputeKey(floorUUID, kind, cellSize)
+	jobID, err = s.db.EnqueueDebouncedJob(&db.Job{Kind: db.JobKindMatrix, Params: params, FloorID: floorUUID, DedupKey: &key}, 0)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to queue %s matrix", kind)
+	}
+	return
+}
+
+// getFloorMatrix returns the stored matrix of the kind of the floor with its rows, see ensureMatrix
+func (s *Fiber) getFloorMatrix(floorUUID uuid.UUID, kind string, inputData location.InputData, requireCurrent bool) (m *db.Matrix, rows []*db.MatrixRow, err error) {
+	m, err = s.ensureMatrix(floorUUID, kind, inputData, requireCurrent)
+	if err != nil {
+		return
+	}
+	rows, err = s.db.GetMatrixRows(m.ID)
+	return
+}
+
+// getFloorGeometry retrieves a floor with its access points, sensors, walls and obstacles
+func (s *Fiber) getFloorGeometry(floorUUID uuid.UUID) (f *db.Floor, err error) {
+	f, err = s.db.GetFloor(floorUUID)
+	if err != nil {
+		return
+	}
+	f.AccessPoints, err = s.db.GetAccessPointsDetailed(floorUUID)
+	if err != nil {
+		return
+	}
+	f.Sensors, err = s.db.GetSensors(floorUUID)
+	if err != nil {
+		return
+	}
+	f.Walls, err = s.db.GetWallsDetailed(floorUUID)
+	if err != nil {
+		return
+	}
+	f.Obstacles, err = s.db.GetObstacles(floorUUID)
+	return
+}
+
+// getAdjacentFloors retrieves the floors above and below the floor with their access points, see location.AdjacentFloors
+func (s *Fiber) getAdjacentFloors(f *db.Floor) (adjacent []*db.Floor, err error) {
+	floors, err := s.db.GetFloors(f.BuildingID)
+	if err != nil {
+		return
+	}
+	adjacent = location.AdjacentFloors(f, floors)
+	for _, a := range adjacent {
+		a.AccessPoints, err = s.db.GetAccessPointsDetailed(a.ID)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// floorPropagationModel returns the propagation model of the floor, or of its site if the floor has none
+func (s *Fiber) floorPropagationModel(floorUUID uuid.UUID) (model location.PropagationModel, err error) {
+	name, err := s.db.GetFloorPropagationModel(floorUUID)
+	if err != nil {
+		return
+	}
+	model, err = location.NewPropagationModel(name)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Floor %v falls back to the default propagation model", floorUUID)
+		return location.DefaultPropagationModel, nil
+	}
+	return
+}
+
+// floorCalibration returns the calibration of the site of the floor, the defaults if the site has no profile
+func (s *Fiber) floorCalibration(floorUUID uuid.UUID) (cal location.Calibration, err error) {
+	cp, err := s.db.GetFloorCalibrationProfile(floorUUID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return location.DefaultCalibration, nil
+	}
+	if err != nil {
+		return
+	}
+	return location.NewCalibration(cp), nil
+}
+
+// validatePropagationModel checks the name of a propagation model, nil and empty names are valid
+func validatePropagationModel(name *string) error {
+	if name == nil {
+		return nil
+	}
+	_, err := location.NewPropagationModel(*name)
+	return err
+}
+
+// floorImageSize returns the size in pixels of the floor plan image or zeros if the floor has no image
+func floorImageSize(f *db.Floor) (width, height int) {
+	if f.Image == nil {
+		return
+	}
+	file, err := os.Open(filepath.Join("static", *f.Image))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to open floor image")
+		return
+	}
+	defer file.Close()
+
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to decode floor image config")
+		return
+	}
+	return cfg.Width, cfg.Height
+}
+
+// loadFloorImage decodes the floor plan image or returns nil if the floor has no image
+func loadFloorImage(f *db.Floor) (img image.Image, err error) {
+	if f.Image == nil {
+		return
+	}
+	file, err := os.Open(filepath.Join("static", *f.Image))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	img, _, err = image.Decode(file)
+	return
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/db"
+	"location-backend/internal/location"
+)
+
+// GetFloorInterference lists the pairs of access points of a floor interfering the most on overlapping
+// or adjacent channels of a band, see location.RadioPair. Query params: id, band, cellSize and limit.
+// A floor without a coverage matrix is answered Accepted with the ids of the queued matrix jobs.
+func (s *Fiber) GetFloorInterference(c *fiber.Ctx) (err error) {
+	floorUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse floor uuid")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid floor UUID")
+	}
+	band, err := location.ParseBand(c.Query("band", "5"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid band")
+	}
+	limit := c.QueryInt("limit", location.INTERFERENCE_PAIRS)
+	if limit <= 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("limit must be positive")
+	}
+	cellSize := c.QueryFloat("cellSize", location.CELL_SIZE_METER)
+
+	f, m, im, err := s.computeFloorInterference(floorUUID, band, cellSize)
+	if err != nil {
+		return sendMatrixPending(c, err)
+	}
+	pairs := im.Pairs[:min(limit, len(im.Pairs))]
+	return c.JSON(fiber.Map{
+		"data": fiber.Map{
+			"band":  band,
+			"pairs": pairs,
+		},
+		"current":          !m.Dirty,
+		"geometryRevision": f.GeometryRevision,
+		"matrixRevision":   m.GeometryRevision,
+	})
+}
+
+// computeFloorInterference computes the interference map of the floor in the band from its coverage matrix,
+// see getFloorCoverageMatrix
+func (s *Fiber) computeFloorInterference(floorUUID uuid.UUID, band location.Band, cellSize float64) (f *db.Floor, m *db.Matrix, im *location.InterferenceMap, err error) {
+	f, inputData, m, rows, err := s.getFloorCoverageMatrix(floorUUID, cellSize)
+	if err != nil {
+		return
+	}
+	im = location.NewInterferenceMap(m, rows, band, location.FloorChannels(f), inputData.Calibration().RSSIInvisible)
+	return
+}
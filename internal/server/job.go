@@ -0,0 +1,314 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/db"
+	"location-backend/internal/location"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	jobPollInterval      = 2 * time.Second  // Pause of an idle worker between claims
+	jobHeartbeatInterval = 2 * time.Second  // Progress updates and cancellation checks of a running job
+	jobStaleTimeout      = 30 * time.Second // Running jobs without a heartbeat for this time are requeued
+)
+
+// JobInput is a floor computation to queue, Params depend on the kind:
+// matrix {"kind": "coverage"|"sensors", "cellSize": 0.5, "onlyDirty": false}, heatmap {"band": "5", "cellSize": 0.5}, calibration {"apply": false}
+type JobInput struct {
+	FloorID uuid.UUID       `json:"floorId"`
+	Kind    string          `json:"kind"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type matrixJobParams struct {
+	Kind      string  `json:"kind"`
+	CellSize  float64 `json:"cellSize"`
+	OnlyDirty bool    `json:"onlyDirty"` // Keep a stored matrix that is current
+}
+
+type heatmapJobParams struct {
+	Band     string  `json:"band"`
+	CellSize float64 `json:"cellSize"`
+}
+
+type calibrationJobParams struct {
+	Apply bool `json:"apply"`
+}
+
+// parseJobParams decodes and validates the params of the job kind, missing params get their defaults
+func parseJobParams(kind string, raw json.RawMessage) (params any, err error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		raw = []byte("{}")
+	}
+	switch kind {
+	case db.JobKindMatrix:
+		p := matrixJobParams{Kind: db.MatrixKindCoverage, CellSize: location.CELL_SIZE_METER}
+		if err = json.Unmarshal(raw, &p); err != nil {
+			return
+		}
+		if p.Kind != db.MatrixKindCoverage && p.Kind != db.MatrixKindSensors {
+			return nil, fmt.Errorf("unknown matrix kind %q", p.Kind)
+		}
+		if p.CellSize <= 0 {
+			return nil, errors.New("cell size must be positive")
+		}
+		return p, nil
+	case db.JobKindHeatmap:
+		p := heatmapJobParams{Band: "5", CellSize: location.CELL_SIZE_METER}
+		if err = json.Unmarshal(raw, &p); err != nil {
+			return
+		}
+		if _, err = location.ParseBand(p.Band); err != nil {
+			return
+		}
+		if p.CellSize <= 0 {
+			return nil, errors.New("cell size must be positive")
+		}
+		return p, nil
+	case db.JobKindCalibration:
+		p := calibrationJobParams{}
+		if err = json.Unmarshal(raw, &p); err != nil {
+			return
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown job kind %q", kind)
+}
+
+// CreateJob queues a floor computation, the response has the id of the job to poll
+func (s *Fiber) CreateJob(c *fiber.Ctx) (err error) {
+	var input JobInput
+	if err = c.BodyParser(&input); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
+	}
+	params, err := parseJobParams(input.Kind, input.Params)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	j := &db.Job{Kind: input.Kind, FloorID: input.FloorID}
+	// Params are stored with their defaults
+	if j.Params, err = json.Marshal(params); err != nil {
+		return
+	}
+	if userUUID, err := currentUserID(c); err == nil {
+		j.UserID = &userUUID
+	}
+
+	id, err := s.db.CreateJob(j)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create job")
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to create job")
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"id": id,
+	})
+}
+
+// GetJob retrieves a job with its status, progress and result
+func (s *Fiber) GetJob(c *fiber.Ctx) (err error) {
+	jobUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse job uuid")
+		return
+	}
+	j, err := s.db.GetJob(jobUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get job")
+		return
+	}
+	return c.JSON(fiber.Map{
+		"data": j,
+	})
+}
+
+// GetJobs retrieves the jobs of a floor
+func (s *Fiber) GetJobs(c *fiber.Ctx) (err error) {
+	floorUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse floor uuid")
+		return
+	}
+	js, err := s.db.GetJobs(floorUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get jobs")
+		return
+	}
+	return c.JSON(fiber.Map{
+		"data": js,
+	})
+}
+
+// CancelJob cancels a queued or running job, a running job stops within jobHeartbeatInterval
+func (s *Fiber) CancelJob(c *fiber.Ctx) (err error) {
+	jobUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse job uuid")
+		return
+	}
+	status, err := s.db.CancelJob(jobUUID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.Status(fiber.StatusBadRequest).SendString("Job has already finished")
+		}
+		return
+	}
+	return c.JSON(fiber.Map{
+		"status": status,
+	})
+}
+
+// StartJobWorkers starts the workers executing queued jobs until the context is done, see WaitJobWorkers.
+// Workers of all instances share the queue.
+func (s *Fiber) StartJobWorkers(ctx context.Context, workers int) {
+	host, _ := os.Hostname()
+	for i := 0; i < workers; i++ {
+		s.jobWorkers.Add(1)
+		go func(name string) {
+			defer s.jobWorkers.Done()
+			s.jobWorker(ctx, name)
+		}(fmt.Sprintf("%s:%d:%d", host, os.Getpid(), i))
+	}
+	log.Info().Msgf("Started %d job workers", workers)
+}
+
+// WaitJobWorkers waits for the workers to stop after their context is done, the results of the jobs
+// computed by then are stored
+func (s *Fiber) WaitJobWorkers() {
+	s.jobWorkers.Wait()
+}
+
+// jobWorker claims and runs jobs one by one, it requeues the jobs of dead workers on the way
+func (s *Fiber) jobWorker(ctx context.Context, name string) {
+	for ctx.Err() == nil {
+		_, _ = s.db.RequeueStaleJobs(jobStaleTimeout)
+		j, err := s.db.ClaimJob(name)
+		if err == nil {
+			s.runJob(ctx, j)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
+
+// runJob executes the claimed job and records its outcome. A heartbeat stores the progress
+// and cancels the job when its cancellation is requested.
+func (s *Fiber) runJob(ctx context.Context, j *db.Job) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var progress atomic.Int32
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(jobHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if cancelRequested, err := s.db.UpdateJobProgress(j.ID, int(progress.Load())); err == nil && cancelRequested {
+					cancel()
+				}
+			}
+		}
+	}()
+
+	result, err := s.executeJob(jobCtx, j, func(percent int) { progress.Store(int32(percent)) })
+	// A computed result is stored even when the instance is stopping or the cancellation came too late
+	if err != nil {
+		if ctx.Err() != nil {
+			// The instance is stopping, the job is requeued when its heartbeat becomes stale
+			return
+		}
+		if jobCtx.Err() != nil {
+			_ = s.db.FinishJob(j.ID, db.JobCancelled, nil, nil)
+			return
+		}
+		log.Error().Err(err).Msgf("Job %v failed", j.ID)
+		msg := err.Error()
+		_ = s.db.FinishJob(j.ID, db.JobFailed, nil, &msg)
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		msg := err.Error()
+		_ = s.db.FinishJob(j.ID, db.JobFailed, nil, &msg)
+		return
+	}
+	_ = s.db.FinishJob(j.ID, db.JobDone, data, nil)
+}
+
+// executeJob computes the job, a panic fails the job instead of the instance.
+// A matrix job generates the matrix again unless onlyDirty is set and the stored one is current,
+// heatmap and calibration jobs generate the missing or stale matrices they read first.
+func (s *Fiber) executeJob(ctx context.Context, j *db.Job, progress location.ProgressFunc) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	params, err := parseJobParams(j.Kind, j.Params)
+	if err != nil {
+		return
+	}
+	switch p := params.(type) {
+	case matrixJobParams:
+		return s.generateFloorMatrix(ctx, j.FloorID, p.Kind, p.CellSize, p.OnlyDirty, progress)
+	case heatmapJobParams:
+		if _, err = s.generateFloorMatrix(ctx, j.FloorID, db.MatrixKindCoverage, p.CellSize, true, progress); err != nil {
+			return nil, err
+		}
+		band, _ := location.ParseBand(p.Band)
+		_, _, hm, err := s.computeFloorHeatmap(j.FloorID, band, p.CellSize)
+		return hm, err
+	case calibrationJobParams:
+		kinds := []string{db.MatrixKindSensors, db.MatrixKindCoverage}
+		for i, kind := range kinds {
+			// Every matrix is an equal share of the progress
+			var kindProgress location.ProgressFunc
+			if progress != nil {
+				kindProgress = func(percent int) { progress((i*100 + percent) / len(kinds)) }
+			}
+			if _, err = s.generateFloorMatrix(ctx, j.FloorID, kind, location.CELL_SIZE_METER, true, kindProgress); err != nil {
+				return nil, err
+			}
+		}
+		return s.calibrateSurvey(j.FloorID, p.Apply)
+	}
+	return nil, fmt.Errorf("unknown job kind %q", j.Kind)
+}
+
+// generateFloorMatrix generates and stores the matrix of the kind and cell size of the floor from its current geometry,
+// with onlyDirty a stored matrix that is current is returned instead. Only jobs generate matrices, see ensureMatrix.
+func (s *Fiber) generateFloorMatrix(ctx context.Context, floorUUID uuid.UUID, kind string, cellSize float64, onlyDirty bool, progress location.ProgressFunc) (m *db.Matrix, err error) {
+	f, err := s.getFloorGeometry(floorUUID)
+	if err != nil {
+		return
+	}
+	inputData, err := s.floorInputData(f, kind, cellSize)
+	if err != nil {
+		return
+	}
+	if onlyDirty {
+		if m, err := s.db.GetMatrix(f.ID, kind, cellSize); err == nil && !m.Dirty && location.MatrixMatches(m, inputData) {
+			return m, nil
+		}
+	}
+	return location.StoreMatrixProgress(ctx, s.db, f.ID, kind, inputData, progress)
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/db"
+)
+
+// CreateObstacle creates an obstacle
+func (s *Fiber) CreateObstacle(c *fiber.Ctx) (err error) {
+	o := new(db.Obstacle)
+	err = c.BodyParser(o)
+	if err != nil {
+		return err
+	}
+	if o.Name == nil || *o.Name == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("name is required")
+	}
+	if o.Points == nil || o.Attenuation24 == nil || o.Attenuation5 == nil || o.Attenuation6 == nil {
+		return c.Status(fiber.StatusBadRequest).SendString("points and attenuations are required")
+	}
+	if err = validateObstacle(o); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	obstacleID, err := s.db.CreateObstacle(o)
+	if err != nil {
+		return err
+	}
+	s.publishObstacle(obstacleID, ActionCreated)
+	return c.JSON(fiber.Map{
+		"id": obstacleID,
+	})
+}
+
+// GetObstacle retrieves an obstacle
+func (s *Fiber) GetObstacle(c *fiber.Ctx) (err error) {
+	obstacleID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse obstacle uuid")
+		return
+	}
+	o, err := s.db.GetObstacle(obstacleID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get obstacle")
+		return
+	}
+	return c.JSON(fiber.Map{
+		"data": o,
+	})
+}
+
+// GetObstacles retrieves the obstacles of a floor
+func (s *Fiber) GetObstacles(c *fiber.Ctx) (err error) {
+	floorUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse floor uuid")
+		return
+	}
+	obs, err := s.db.GetObstacles(floorUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get obstacles")
+		return
+	}
+	return c.JSON(fiber.Map{
+		"data": obs,
+	})
+}
+
+// SoftDeleteObstacle soft delete an obstacle
+func (s *Fiber) SoftDeleteObstacle(c *fiber.Ctx) (err error) {
+	obstacleID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse obstacle uuid")
+		return
+	}
+	isDeleted, err := s.db.IsObstacleSoftDeleted(obstacleID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get soft deleted obstacle")
+		return
+	}
+	if isDeleted {
+		return c.Status(fiber.StatusBadRequest).SendString("Obstacle has already been soft deleted")
+	}
+	// Deleted obstacles are not retrieved, the obstacle is read for its floor before the delete
+	o, err := s.db.GetObstacle(obstacleID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get obstacle")
+		return
+	}
+	err = s.db.SoftDeleteObstacle(obstacleID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to soft delete an obstacle")
+		return
+	}
+	s.publishFloor(o.FloorID, EventObstacle, ActionDeleted, o.ID, o)
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// RestoreObstacle restore an obstacle
+func (s *Fiber) RestoreObstacle(c *fiber.Ctx) (err error) {
+	obstacleID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse obstacle uuid")
+		return
+	}
+	isDeleted, err := s.db.IsObstacleSoftDeleted(obstacleID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get soft deleted obstacle")
+		return
+	}
+	if !isDeleted {
+		return c.Status(fiber.StatusBadRequest).SendString("Obstacle has not been soft deleted")
+	}
+	err = s.db.RestoreObstacle(obstacleID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to restore an obstacle")
+		return
+	}
+	s.publishObstacle(obstacleID, ActionRestored)
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// PatchUpdateObstacle patch updates an obstacle based on provided fields
+func (s *Fiber) PatchUpdateObstacle(c *fiber.Ctx) error {
+	var input db.Obstacle
+	if err := c.BodyParser(&input); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
+	}
+	if err := validateObstacle(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	input.ResetZTop = isExplicitNull(c.Body(), "zTop")
+	if input.ZBottom != nil || input.ZTop != nil || input.ResetZTop {
+		// The heights are validated merged with the stored ones
+		current, err := s.db.GetObstacle(input.ID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString("Obstacle not found")
+		}
+		if err = validateHeights(mergeHeights(current.ZBottom, current.ZTop, input.ZBottom, input.ZTop, input.ResetZTop)); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+	}
+
+	if err := s.db.PatchUpdateObstacle(&input); err != nil {
+		log.Error().Err(err).Msg("Failed to update obstacle")
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update obstacle")
+	}
+	s.publishObstacle(input.ID, ActionUpdated)
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// validateObstacle checks the fields of an obstacle given in a request, unset fields are valid
+func validateObstacle(o *db.Obstacle) error {
+	if o.Points != nil && len(o.Points) < 3 {
+		return errors.New("an obstacle polygon needs at least 3 points")
+	}
+	for _, a := range []*float64{o.Attenuation24, o.Attenuation5, o.Attenuation6} {
+		if a != nil && *a < 0 {
+			return errors.New("attenuation must not be negative")
+		}
+	}
+	return validateHeights(o.ZBottom, o.ZTop)
+}
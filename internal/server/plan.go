@@ -0,0 +1,157 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/db"
+	"location-backend/internal/location"
+	"slices"
+)
+
+// channelPlanRequest is the body of the plan requests, id is the floor or the building, see location.PlanOptions
+type channelPlanRequest struct {
+	ID       uuid.UUID `json:"id"`
+	CellSize *float64  `json:"cellSize"`
+	location.PlanOptions
+}
+
+// channelPlanApplyRequest is the body of the apply requests with the changes of a plan, possibly edited
+type channelPlanApplyRequest struct {
+	ID      uuid.UUID              `json:"id"`
+	Changes []location.RadioChange `json:"changes"`
+}
+
+// PlanFloorChannels proposes the channel and power of every radio of a floor minimising the interference,
+// see location.NewChannelPlan. Access points of the adjacent floors keep their channels.
+// The plan is not applied, see ApplyFloorChannelPlan. Floors without a coverage matrix are answered Accepted
+// with the ids of the queued matrix jobs, see sendMatrixPending.
+func (s *Fiber) PlanFloorChannels(c *fiber.Ctx) (err error) {
+	return s.planChannels(c, func(id uuid.UUID) ([]uuid.UUID, error) { return []uuid.UUID{id}, nil })
+}
+
+// PlanBuildingChannels proposes the channel and power of every radio of the floors of a building,
+// see PlanFloorChannels
+func (s *Fiber) PlanBuildingChannels(c *fiber.Ctx) (err error) {
+	return s.planChannels(c, s.buildingFloorIDs)
+}
+
+// ApplyFloorChannelPlan sets the planned channels and powers of the radios of a floor in one transaction,
+// nothing is changed if a radio is not on the floor
+func (s *Fiber) ApplyFloorChannelPlan(c *fiber.Ctx) (err error) {
+	return s.applyChannelPlan(c, func(id uuid.UUID) ([]uuid.UUID, error) { return []uuid.UUID{id}, nil })
+}
+
+// ApplyBuildingChannelPlan sets the planned channels and powers of the radios of a building in one transaction,
+// see ApplyFloorChannelPlan
+func (s *Fiber) ApplyBuildingChannelPlan(c *fiber.Ctx) (err error) {
+	return s.applyChannelPlan(c, s.buildingFloorIDs)
+}
+
+// buildingFloorIDs returns the floors of the building
+func (s *Fiber) buildingFloorIDs(buildingUUID uuid.UUID) (floorIDs []uuid.UUID, err error) {
+	floors, err := s.db.GetFloors(buildingUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get floors")
+		return
+	}
+	for _, f := range floors {
+		floorIDs = append(floorIDs, f.ID)
+	}
+	return
+}
+
+// planChannels plans the radios of the floors of the requested id, see PlanFloorChannels
+func (s *Fiber) planChannels(c *fiber.Ctx, floorIDs func(id uuid.UUID) ([]uuid.UUID, error)) (err error) {
+	var req channelPlanRequest
+	if err = c.BodyParser(&req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
+	}
+	cellSize := location.CELL_SIZE_METER
+	if req.CellSize != nil {
+		cellSize = *req.CellSize
+	}
+	ids, err := floorIDs(req.ID)
+	if err != nil {
+		return
+	}
+
+	var floors []location.PlanFloor
+	var planned []uuid.UUID
+	channels := make(map[uuid.UUID]location.RadioChannel)
+	current := true
+	pending := &matrixPendingError{}
+	for _, id := range ids {
+		f, inputData, m, rows, err := s.getFloorCoverageMatrix(id, cellSize)
+		var floorPending *matrixPendingError
+		if errors.As(err, &floorPending) {
+			pending.JobIDs = append(pending.JobIDs, floorPending.JobIDs...)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		floors = append(floors, location.PlanFloor{Matrix: m, Rows: rows, Invisible: inputData.Calibration().RSSIInvisible})
+		for radio, c := range location.FloorChannels(f) {
+			channels[radio] = c
+		}
+		for _, ap := range f.AccessPoints {
+			planned = append(planned, ap.ID)
+		}
+		current = current && !m.Dirty
+	}
+	if len(pending.JobIDs) > 0 {
+		return sendMatrixPending(c, pending)
+	}
+
+	plan, err := location.NewChannelPlan(floors, channels, planned, req.PlanOptions)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	return c.JSON(fiber.Map{
+		"data":    plan,
+		"current": current,
+	})
+}
+
+// applyChannelPlan applies the changes to the radios of the floors of the requested id, see ApplyFloorChannelPlan
+func (s *Fiber) applyChannelPlan(c *fiber.Ctx, floorIDs func(id uuid.UUID) ([]uuid.UUID, error)) (err error) {
+	var req channelPlanApplyRequest
+	if err = c.BodyParser(&req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
+	}
+	if len(req.Changes) == 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("No changes provided")
+	}
+	// The band of a change is required and only fills in the WiFi field of a radio without one, the settings are checked
+	// against the stored radio, see location.CheckRadioChange
+	radios := make([]*db.Radio, 0, len(req.Changes))
+	for _, change := range req.Changes {
+		if !slices.Contains([]location.Band{location.Band24, location.Band5, location.Band6}, change.Band) {
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Invalid band of radio %v", change.RadioID))
+		}
+		channel, power, wifi := change.PlannedChannel, change.PlannedPower, change.Band.String()
+		radios = append(radios, &db.Radio{ID: change.RadioID, Channel: &channel, Power: &power, WiFi: &wifi})
+	}
+	ids, err := floorIDs(req.ID)
+	if err != nil {
+		return
+	}
+
+	accessPointIDs, err := s.db.ApplyRadioSettings(radios, ids, location.CheckRadioChange)
+	if errors.Is(err, db.ErrRadioOutOfScope) || errors.Is(err, db.ErrRadioSettingsInvalid) {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to apply channel plan")
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to apply channel plan")
+	}
+	for _, apID := range accessPointIDs {
+		s.publishAccessPoint(apID, ActionUpdated)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/config"
+	"location-backend/internal/db"
+	"location-backend/internal/location"
+	"time"
+)
+
+// ObservationInput is an RSSI report of a sensor about a client device
+type ObservationInput struct {
+	ClientMac string    `json:"clientMac"`
+	SensorMac string    `json:"sensorMac"`
+	Band      string    `json:"band"`
+	RSSI      float64   `json:"rssi"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// CreateObservations stores RSSI reports of sensors, the body is an array of observations
+func (s *Fiber) CreateObservations(c *fiber.Ctx) (err error) {
+	var input []ObservationInput
+	if err = c.BodyParser(&input); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
+	}
+
+	now := time.Now()
+	sensors := make(map[string]*db.Sensor)
+	agingTimes := make(map[uuid.UUID]time.Duration)
+	clients := make(map[string]bool)
+	var accepted int
+	for _, in := range input {
+		band, err := location.ParseBand(in.Band)
+		if err != nil || in.ClientMac == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid observation")
+		}
+		if in.Timestamp.IsZero() || in.Timestamp.After(now) {
+			in.Timestamp = now
+		}
+
+		sensorMac := location.NormalizeMac(in.SensorMac)
+		sensor, ok := sensors[sensorMac]
+		if !ok {
+			sensor, err = s.db.GetSensorByMac(sensorMac)
+			if err != nil {
+				sensor = nil
+			} else if s.requireSiteRole(c, db.ResourceSensor, sensor.ID, db.RoleEditor) != nil {
+				log.Debug().Msgf("Observations of sensor %s are not allowed for the user", in.SensorMac)
+				sensor = nil
+			}
+			sensors[sensorMac] = sensor
+		}
+		if sensor == nil {
+			log.Debug().Msgf("Observation of unknown sensor %s ignored", in.SensorMac)
+			continue
+		}
+
+		// Observations stay actual for the aging time of the calibration of the sensor site
+		maxAge, ok := agingTimes[sensor.FloorID]
+		if !ok {
+			cal, err := s.floorCalibration(sensor.FloorID)
+			if err != nil {
+				cal = location.DefaultCalibration
+			}
+			maxAge = cal.InfoAgingTime
+			agingTimes[sensor.FloorID] = maxAge
+		}
+
+		s.tracker.Observe(location.Observation{
+			ClientMac: in.ClientMac,
+			SensorID:  sensor.ID,
+			FloorID:   sensor.FloorID,
+			Band:      band,
+			RSSI:      in.RSSI,
+			Timestamp: in.Timestamp,
+			MaxAge:    maxAge,
+		})
+		clients[location.NormalizeMac(in.ClientMac)] = true
+		accepted++
+	}
+	s.tracker.Purge(now)
+
+	if len(clients) > 0 && s.centrifugo.Enabled() {
+		s.queuePositions(clients)
+	}
+
+	return c.JSON(fiber.Map{
+		"accepted": accepted,
+	})
+}
+
+// GetPosition estimates the position of the client device given by the mac query param.
+// Coordinates of the position and its cells are image pixels of the floor plan. Conflict is returned
+// while the positioning matrix of the floor is generated, see getFingerprints.
+func (s *Fiber) GetPosition(c *fiber.Ctx) (err error) {
+	mac := c.Query("mac")
+	if mac == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid mac")
+	}
+	obs := s.tracker.Recent(mac, time.Now())
+	floorUUID, ok := location.StrongestFloor(obs)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).SendString("Device has not been observed recently")
+	}
+	if err = s.requireSiteRole(c, db.ResourceFloor, floorUUID, db.RoleViewer); err != nil {
+		return
+	}
+
+	f, fp, current, err := s.getFingerprints(floorUUID)
+	if errors.Is(err, errFingerprintsPending) {
+		return c.Status(fiber.StatusConflict).SendString(err.Error())
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get positioning matrix")
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	pos, err := fp.Locate(obs)
+	if err != nil {
+		if errors.Is(err, location.ErrNoMatch) {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+		return
+	}
+
+	toFloorPixels(pos, *f.Scale)
+	return c.JSON(fiber.Map{
+		"data":    pos,
+		"current": current,
+	})
+}
+
+// toFloorPixels converts the position and its cells from meters to image pixels of the floor plan
+func toFloorPixels(pos *location.Position, scale float64) {
+	pos.X, pos.Y = pos.X*scale, pos.Y*scale
+	for i := range pos.Cells {
+		pos.Cells[i].X, pos.Cells[i].Y = pos.Cells[i].X*scale, pos.Cells[i].Y*scale
+	}
+}
+
+// errFingerprintsPending is returned while the positioning matrix of a floor is generated by a job
+var errFingerprintsPending = errors.New("positioning matrix is being generated, retry later")
+
+// getFingerprints returns the positioning matrix of the floor. Requests never generate the matrix: a missing one
+// is queued as a matrix job and errFingerprintsPending is returned, a matrix built for another grid or a dirty
+// one is used until the queued job replaces it and current is false then.
+// Matrices read from the database are kept in memory until they are replaced.
+func (s *Fiber) getFingerprints(floorUUID uuid.UUID) (f *db.Floor, fp *location.Fingerprints, current bool, err error) {
+	f, err = s.getFloorGeometry(floorUUID)
+	if err != nil {
+		return
+	}
+	inputData, err := s.floorInputData(f, db.MatrixKindSensors, location.CELL_SIZE_METER)
+	if err != nil {
+		return
+	}
+	m, err := s.db.GetMatrix(floorUUID, db.MatrixKindSensors, location.CELL_SIZE_METER)
+	if errors.Is(err, pgx.ErrNoRows) {
+		s.queueMatrix(floorUUID, db.MatrixKindSensors, location.CELL_SIZE_METER)
+		return nil, nil, false, errFingerprintsPending
+	}
+	if err != nil {
+		return
+	}
+	current = !m.Dirty && location.MatrixMatches(m, inputData)
+	// A dirty matrix is queued by the invalidation when MATRIX_RECOMPUTE_DELAY is set
+	if !current && (!m.Dirty || config.App.MatrixRecomputeDelay <= 0) {
+		s.queueMatrix(floorUUID, db.MatrixKindSensors, location.CELL_SIZE_METER)
+	}
+
+	s.fingerprintsMu.Lock()
+	defer s.fingerprintsMu.Unlock()
+	if cached, ok := s.fingerprints[floorUUID]; ok && cached.MatrixID == m.ID {
+		return f, cached, current, nil
+	}
+	rows, err := s.db.GetMatrixRows(m.ID)
+	if err != nil {
+		return
+	}
+	fp = location.NewFingerprints(m, rows, inputData.Calibration())
+	s.fingerprints[floorUUID] = fp
+	return
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/db"
+	"strings"
+)
+
+// idSource extracts the resource kind and id of the entity a request acts on
+type idSource func(c *fiber.Ctx) (resource string, id uuid.UUID, err error)
+
+// queryID takes the entity id from the id query param
+func queryID(resource string) idSource {
+	return func(c *fiber.Ctx) (string, uuid.UUID, error) {
+		id, err := uuid.Parse(c.Query("id"))
+		return resource, id, err
+	}
+}
+
+// bodyID takes the entity id from a field of the JSON body or of the form
+func bodyID(resource, field string) idSource {
+	return func(c *fiber.Ctx) (string, uuid.UUID, error) {
+		var value string
+		if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(c.Body(), &body); err != nil {
+				return resource, uuid.Nil, err
+			}
+			if err := json.Unmarshal(body[field], &value); err != nil {
+				return resource, uuid.Nil, err
+			}
+		} else {
+			value = c.FormValue(field)
+		}
+		id, err := uuid.Parse(value)
+		return resource, id, err
+	}
+}
+
+// currentUserID returns the user id of the request JWT
+func currentUserID(c *fiber.Ctx) (userUUID uuid.UUID, err error) {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return userUUID, errors.New("no user token")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return userUUID, errors.New("invalid user token claims")
+	}
+	id, _ := claims["id"].(string)
+	return uuid.Parse(id)
+}
+
+// requireSiteRole checks that the current user has at least the role in the site of the entity.
+// The site id and the user role are stored in the siteId and siteRole locals.
+func (s *Fiber) requireSiteRole(c *fiber.Ctx, resource string, id uuid.UUID, role string) (err error) {
+	userUUID, err := currentUserID(c)
+	if err != nil {
+		return fiber.ErrUnauthorized
+	}
+	siteUUID, err := s.db.GetSiteIDOf(resource, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fiber.NewError(fiber.StatusNotFound, "Not found")
+		}
+		return fiber.ErrInternalServerError
+	}
+	userRole, err := s.db.GetUserSiteRole(userUUID, siteUUID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	if db.RoleLevel(userRole) < db.RoleLevel(role) {
+		log.Debug().Msgf("User %v with role %q denied %s access to site %v", userUUID, userRole, role, siteUUID)
+		return fiber.ErrForbidden
+	}
+	c.Locals("siteId", siteUUID)
+	c.Locals("siteRole", userRole)
+	return
+}
+
+// requireSameSite checks that an entity referenced by the request belongs to the site the request was
+// authorized in, see requireSiteRole. Entities of other sites are reported as missing.
+func (s *Fiber) requireSameSite(c *fiber.Ctx, resource string, id uuid.UUID) error {
+	siteUUID, ok := c.Locals("siteId").(uuid.UUID)
+	if !ok {
+		return fiber.ErrForbidden
+	}
+	refSiteUUID, err := s.db.GetSiteIDOf(resource, id)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fiber.ErrInternalServerError
+	}
+	if err != nil || refSiteUUID != siteUUID {
+		log.Debug().Msgf("Request in site %v references %s %v of another site", siteUUID, resource, id)
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid "+resource)
+	}
+	return nil
+}
+
+// authorize is a middleware allowing the request only for users having at least the role
+// in the site of the entity given by the source
+func (s *Fiber) authorize(role string, source idSource) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		resource, id, err := source(c)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid "+resource+" uuid")
+		}
+		if err = s.requireSiteRole(c, resource, id, role); err != nil {
+			return err
+		}
+		return c.Next()
+	}
+}
+
+// SiteRoleInput grants or revokes a site role of a user given by id or username
+type SiteRoleInput struct {
+	SiteID   uuid.UUID `json:"siteId"`
+	UserID   uuid.UUID `json:"userId"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+}
+
+// resolveUser fills the user id from the username if it is not set
+func (s *Fiber) resolveUser(input *SiteRoleInput) (err error) {
+	if input.UserID != uuid.Nil {
+		return
+	}
+	if input.Username == "" {
+		return errors.New("user is not set")
+	}
+	user, err := s.db.GetUserByUsername(input.Username)
+	if err != nil {
+		return
+	}
+	input.UserID = user.ID
+	return
+}
+
+// GetSiteRoles retrieves the users having a role in the site
+func (s *Fiber) GetSiteRoles(c *fiber.Ctx) (err error) {
+	siteUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse site uuid")
+		return
+	}
+	members, err := s.db.GetSiteMembers(siteUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get site roles")
+		return
+	}
+	return c.JSON(fiber.Map{
+		"data": members,
+	})
+}
+
+// GrantSiteRole sets the role of a user in the site
+func (s *Fiber) GrantSiteRole(c *fiber.Ctx) (err error) {
+	var input SiteRoleInput
+	if err = c.BodyParser(&input); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
+	}
+	if db.RoleLevel(input.Role) == 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid role")
+	}
+	if err = s.resolveUser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid user")
+	}
+	site, err := s.db.GetSite(input.SiteID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get site")
+		return
+	}
+	if site.UserID == input.UserID {
+		return c.Status(fiber.StatusBadRequest).SendString("Site creator is always its owner")
+	}
+
+	if err = s.db.GrantSiteRole(input.UserID, input.SiteID, input.Role); err != nil {
+		log.Error().Err(err).Msg("Failed to grant site role")
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to grant site role")
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// RevokeSiteRole removes the role of a user in the site
+func (s *Fiber) RevokeSiteRole(c *fiber.Ctx) (err error) {
+	var input SiteRoleInput
+	if err = c.BodyParser(&input); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
+	}
+	if err = s.resolveUser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid user")
+	}
+
+	if err = s.db.RevokeSiteRole(input.UserID, input.SiteID); err != nil {
+		log.Error().Err(err).Msg("Failed to revoke site role")
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to revoke site role")
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
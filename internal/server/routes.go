@@ -8,6 +8,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/helmet"
 	"github.com/gofiber/fiber/v2/middleware/monitor"
 	"location-backend/internal/config"
+	"location-backend/internal/db"
 )
 
 func (s *Fiber) RegisterFiberRoutes() {
@@ -40,90 +41,159 @@ func (s *Fiber) RegisterFiberRoutes() {
 	u := v1.Group("/user")
 	u.Post("/register", s.Register)
 	u.Post("/login", s.Login)
+	u.Post("/refresh", s.Refresh)
+	u.Post("/logout", s.Logout)
 
-	v1.Use(jwtware.New(jwtware.Config{SigningKey: jwtware.SigningKey{Key: []byte(config.App.JWTSecret)}})) // TODO: implement refresh token route
+	v1.Use(jwtware.New(jwtware.Config{SigningKey: jwtware.SigningKey{Key: []byte(config.App.JWTSecret)}}))
+
+	u.Get("/sessions", s.GetSessions)
+	u.Patch("/sessions/revoke", s.RevokeSession)
+
+	viewer := func(source idSource) fiber.Handler { return s.authorize(db.RoleViewer, source) }
+	editor := func(source idSource) fiber.Handler { return s.authorize(db.RoleEditor, source) }
+	owner := func(source idSource) fiber.Handler { return s.authorize(db.RoleOwner, source) }
 
 	site := v1.Group("/site")
 	site.Post("/", s.CreateSite)
-	site.Get("/", s.GetSite)
+	site.Get("/", viewer(queryID(db.ResourceSite)), s.GetSite)
 	site.Get("/all", s.GetSites)
 	site.Get("/all/detailed", s.GetSitesDetailed)
-	site.Patch("/", s.PatchUpdateSite)
-	site.Patch("/sd", s.SoftDeleteSite)
-	site.Patch("/restore", s.RestoreSite)
+	site.Patch("/", owner(bodyID(db.ResourceSite, "id")), s.PatchUpdateSite)
+	site.Patch("/sd", owner(queryID(db.ResourceSite)), s.SoftDeleteSite)
+	site.Patch("/restore", owner(queryID(db.ResourceSite)), s.RestoreSite)
+
+	role := v1.Group("/role")
+	role.Get("/all", owner(queryID(db.ResourceSite)), s.GetSiteRoles)
+	role.Post("/", owner(bodyID(db.ResourceSite, "siteId")), s.GrantSiteRole)
+	role.Patch("/revoke", owner(bodyID(db.ResourceSite, "siteId")), s.RevokeSiteRole)
 
 	b := v1.Group("/building")
-	b.Post("/", s.CreateBuilding)
-	b.Get("/", s.GetBuilding)
-	b.Get("/all", s.GetBuildings)
-	b.Patch("/", s.PatchUpdateBuilding)
-	b.Patch("/sd", s.SoftDeleteBuilding)
-	b.Patch("/restore", s.RestoreBuilding)
+	b.Post("/", editor(bodyID(db.ResourceSite, "siteId")), s.CreateBuilding)
+	b.Get("/", viewer(queryID(db.ResourceBuilding)), s.GetBuilding)
+	b.Get("/all", viewer(queryID(db.ResourceSite)), s.GetBuildings)
+	b.Patch("/", editor(bodyID(db.ResourceBuilding, "id")), s.PatchUpdateBuilding)
+	b.Patch("/sd", editor(queryID(db.ResourceBuilding)), s.SoftDeleteBuilding)
+	b.Patch("/restore", editor(queryID(db.ResourceBuilding)), s.RestoreBuilding)
+	b.Post("/plan", viewer(bodyID(db.ResourceBuilding, "id")), s.PlanBuildingChannels)
+	b.Post("/plan/apply", editor(bodyID(db.ResourceBuilding, "id")), s.ApplyBuildingChannelPlan)
 
 	f := v1.Group("/floor")
-	f.Post("/", s.CreateFloor)
-	f.Get("/", s.GetFloor)
-	f.Get("/all", s.GetFloors)
-	f.Patch("/", s.PatchUpdateFloor)
-	f.Patch("/sd", s.SoftDeleteFloor)
-	f.Patch("/restore", s.RestoreFloor)
+	f.Post("/", editor(bodyID(db.ResourceBuilding, "buildingId")), s.CreateFloor)
+	f.Get("/", viewer(queryID(db.ResourceFloor)), s.GetFloor)
+	f.Get("/all", viewer(queryID(db.ResourceBuilding)), s.GetFloors)
+	f.Patch("/", editor(bodyID(db.ResourceFloor, "id")), s.PatchUpdateFloor)
+	f.Patch("/sd", editor(queryID(db.ResourceFloor)), s.SoftDeleteFloor)
+	f.Patch("/restore", editor(queryID(db.ResourceFloor)), s.RestoreFloor)
+	f.Get("/heatmap", viewer(queryID(db.ResourceFloor)), s.GetFloorHeatmap)
+	f.Get("/heatmap/image", viewer(queryID(db.ResourceFloor)), s.GetFloorHeatmapImage)
+	f.Get("/coverage", viewer(queryID(db.ResourceFloor)), s.GetFloorCoverage)
+	f.Get("/interference", viewer(queryID(db.ResourceFloor)), s.GetFloorInterference)
+	f.Post("/plan", viewer(bodyID(db.ResourceFloor, "id")), s.PlanFloorChannels)
+	f.Post("/plan/apply", editor(bodyID(db.ResourceFloor, "id")), s.ApplyFloorChannelPlan)
 
 	wt := v1.Group("/wallType")
-	wt.Post("/", s.CreateWallType)
-	wt.Get("/", s.GetWallType)
-	wt.Get("/all", s.GetWallTypes)
-	wt.Patch("/", s.PatchUpdateWallType)
-	wt.Patch("/sd", s.SoftDeleteWallType)
-	wt.Patch("/restore", s.RestoreWallType)
+	wt.Post("/", editor(bodyID(db.ResourceSite, "siteId")), s.CreateWallType)
+	wt.Get("/", viewer(queryID(db.ResourceWallType)), s.GetWallType)
+	wt.Get("/all", viewer(queryID(db.ResourceSite)), s.GetWallTypes)
+	wt.Patch("/", editor(bodyID(db.ResourceWallType, "id")), s.PatchUpdateWallType)
+	wt.Patch("/sd", editor(queryID(db.ResourceWallType)), s.SoftDeleteWallType)
+	wt.Patch("/restore", editor(queryID(db.ResourceWallType)), s.RestoreWallType)
 
 	w := v1.Group("/wall")
-	w.Post("/", s.CreateWall)
-	w.Get("/", s.GetWall)
-	w.Get("/all", s.GetWalls)
-	w.Patch("/", s.PatchUpdateWall)
-	w.Patch("/sd", s.SoftDeleteWall)
-	w.Patch("/restore", s.RestoreWall)
+	w.Post("/", editor(bodyID(db.ResourceFloor, "floorId")), s.CreateWall)
+	w.Get("/", viewer(queryID(db.ResourceWall)), s.GetWall)
+	w.Get("/all", viewer(queryID(db.ResourceFloor)), s.GetWalls)
+	w.Patch("/", editor(bodyID(db.ResourceWall, "id")), s.PatchUpdateWall)
+	w.Patch("/sd", editor(queryID(db.ResourceWall)), s.SoftDeleteWall)
+	w.Patch("/restore", editor(queryID(db.ResourceWall)), s.RestoreWall)
+
+	o := v1.Group("/obstacle")
+	o.Post("/", editor(bodyID(db.ResourceFloor, "floorId")), s.CreateObstacle)
+	o.Get("/", viewer(queryID(db.ResourceObstacle)), s.GetObstacle)
+	o.Get("/all", viewer(queryID(db.ResourceFloor)), s.GetObstacles)
+	o.Patch("/", editor(bodyID(db.ResourceObstacle, "id")), s.PatchUpdateObstacle)
+	o.Patch("/sd", editor(queryID(db.ResourceObstacle)), s.SoftDeleteObstacle)
+	o.Patch("/restore", editor(queryID(db.ResourceObstacle)), s.RestoreObstacle)
 
 	apt := v1.Group("/apt")
-	apt.Post("/", s.CreateAccessPointType)
-	apt.Get("/", s.GetAccessPointType)
-	apt.Get("/all", s.GetAccessPointTypes)
-	apt.Patch("/sd", s.SoftDeleteAccessPointType)
-	apt.Patch("/restore", s.RestoreAccessPointType)
+	apt.Post("/", editor(bodyID(db.ResourceSite, "siteId")), s.CreateAccessPointType)
+	apt.Get("/", viewer(queryID(db.ResourceAccessPointType)), s.GetAccessPointType)
+	apt.Get("/all", viewer(queryID(db.ResourceSite)), s.GetAccessPointTypes)
+	apt.Patch("/", editor(bodyID(db.ResourceAccessPointType, "id")), s.PatchUpdateAccessPointType)
+	apt.Post("/diagram", editor(bodyID(db.ResourceAccessPointType, "id")), s.UploadAccessPointTypeDiagram)
+	apt.Patch("/sd", editor(queryID(db.ResourceAccessPointType)), s.SoftDeleteAccessPointType)
+	apt.Patch("/restore", editor(queryID(db.ResourceAccessPointType)), s.RestoreAccessPointType)
 
 	rt := v1.Group("/radioTemplate")
-	rt.Post("/", s.CreateRadioTemplate)
-	rt.Get("/", s.GetRadioTemplate)
-	rt.Get("/all", s.GetRadioTemplates)
-	rt.Patch("/", s.PatchUpdateRadioTemplate)
-	rt.Patch("/sd", s.SoftDeleteRadioTemplate)
-	rt.Patch("/restore", s.RestoreRadioTemplate)
+	rt.Post("/", editor(bodyID(db.ResourceAccessPointType, "accessPointTypeId")), s.CreateRadioTemplate)
+	rt.Get("/", viewer(queryID(db.ResourceRadioTemplate)), s.GetRadioTemplate)
+	rt.Get("/all", viewer(queryID(db.ResourceAccessPointType)), s.GetRadioTemplates)
+	rt.Patch("/", editor(bodyID(db.ResourceRadioTemplate, "id")), s.PatchUpdateRadioTemplate)
+	rt.Patch("/sd", editor(queryID(db.ResourceRadioTemplate)), s.SoftDeleteRadioTemplate)
+	rt.Patch("/restore", editor(queryID(db.ResourceRadioTemplate)), s.RestoreRadioTemplate)
 
 	ap := v1.Group("/ap")
-	ap.Post("/", s.CreateAccessPoint)
-	ap.Get("/", s.GetAccessPoint)
-	ap.Get("/detailed", s.GetAccessPointDetailed)
-	ap.Get("/all", s.GetAccessPoints)
-	ap.Get("/all/detailed", s.GetAccessPointsDetailed)
-	ap.Patch("/", s.PatchUpdateAccessPoint)
-	ap.Patch("/sd", s.SoftDeleteAccessPoint)
-	ap.Patch("/restore", s.RestoreAccessPoint)
+	ap.Post("/", editor(bodyID(db.ResourceFloor, "floorId")), s.CreateAccessPoint)
+	ap.Get("/", viewer(queryID(db.ResourceAccessPoint)), s.GetAccessPoint)
+	ap.Get("/detailed", viewer(queryID(db.ResourceAccessPoint)), s.GetAccessPointDetailed)
+	ap.Get("/all", viewer(queryID(db.ResourceFloor)), s.GetAccessPoints)
+	ap.Get("/all/detailed", viewer(queryID(db.ResourceFloor)), s.GetAccessPointsDetailed)
+	ap.Patch("/", editor(bodyID(db.ResourceAccessPoint, "id")), s.PatchUpdateAccessPoint)
+	ap.Patch("/sd", editor(queryID(db.ResourceAccessPoint)), s.SoftDeleteAccessPoint)
+	ap.Patch("/restore", editor(queryID(db.ResourceAccessPoint)), s.RestoreAccessPoint)
 
 	r := v1.Group("/radio")
-	r.Post("/", s.CreateRadio)
-	r.Get("/", s.GetRadio)
-	r.Get("/all", s.GetRadios)
-	r.Patch("/", s.PatchUpdateRadio)
-	r.Patch("/sd", s.SoftDeleteRadio)
-	r.Patch("/restore", s.RestoreRadio)
-
-	//sensor := v1.Group("/sensor")
-	//sensor.Post("/", s.CreateSensor)
-	//sensor.Get("/", s.GetSensor)
-	//sensor.Get("/all", s.GetSensor)
-	//sensor.Patch("/", s.PatchUpdateSensor)
-	//sensor.Patch("/sd", s.SoftDeleteSensor)
-	//sensor.Patch("/restore", s.RestoreSensor)
+	r.Post("/", editor(bodyID(db.ResourceAccessPoint, "accessPointId")), s.CreateRadio)
+	r.Get("/", viewer(queryID(db.ResourceRadio)), s.GetRadio)
+	r.Get("/all", viewer(queryID(db.ResourceAccessPoint)), s.GetRadios)
+	r.Patch("/", editor(bodyID(db.ResourceRadio, "id")), s.PatchUpdateRadio)
+	r.Patch("/sd", editor(queryID(db.ResourceRadio)), s.SoftDeleteRadio)
+	r.Patch("/restore", editor(queryID(db.ResourceRadio)), s.RestoreRadio)
+
+	st := v1.Group("/sensorType")
+	st.Post("/", editor(bodyID(db.ResourceSite, "siteId")), s.CreateSensorType)
+	st.Get("/", viewer(queryID(db.ResourceSensorType)), s.GetSensorType)
+	st.Get("/all", viewer(queryID(db.ResourceSite)), s.GetSensorTypes)
+	st.Patch("/", editor(bodyID(db.ResourceSensorType, "id")), s.PatchUpdateSensorType)
+	st.Post("/diagram", editor(bodyID(db.ResourceSensorType, "id")), s.UploadSensorTypeDiagram)
+	st.Patch("/sd", editor(queryID(db.ResourceSensorType)), s.SoftDeleteSensorType)
+	st.Patch("/restore", editor(queryID(db.ResourceSensorType)), s.RestoreSensorType)
+
+	sensor := v1.Group("/sensor")
+	sensor.Post("/", editor(bodyID(db.ResourceFloor, "floorId")), s.CreateSensor)
+	sensor.Get("/", viewer(queryID(db.ResourceSensor)), s.GetSensor)
+	sensor.Get("/all", viewer(queryID(db.ResourceFloor)), s.GetSensors)
+	sensor.Patch("/", editor(bodyID(db.ResourceSensor, "id")), s.PatchUpdateSensor)
+	sensor.Patch("/sd", editor(queryID(db.ResourceSensor)), s.SoftDeleteSensor)
+	sensor.Patch("/restore", editor(queryID(db.ResourceSensor)), s.RestoreSensor)
+
+	cal := v1.Group("/calibrationProfile")
+	cal.Post("/", editor(bodyID(db.ResourceSite, "siteId")), s.CreateCalibrationProfile)
+	cal.Get("/", viewer(queryID(db.ResourceCalibrationProfile)), s.GetCalibrationProfile)
+	cal.Get("/all", viewer(queryID(db.ResourceSite)), s.GetCalibrationProfiles)
+	cal.Patch("/", editor(bodyID(db.ResourceCalibrationProfile, "id")), s.PatchUpdateCalibrationProfile)
+	cal.Patch("/sd", editor(queryID(db.ResourceCalibrationProfile)), s.SoftDeleteCalibrationProfile)
+	cal.Patch("/restore", editor(queryID(db.ResourceCalibrationProfile)), s.RestoreCalibrationProfile)
+
+	survey := v1.Group("/survey")
+	survey.Post("/", editor(bodyID(db.ResourceFloor, "floorId")), s.CreateSurveyMeasurements)
+	survey.Get("/all", viewer(queryID(db.ResourceFloor)), s.GetSurveyMeasurements)
+	survey.Post("/calibrate", editor(queryID(db.ResourceFloor)), s.CalibrateSurvey)
+	survey.Patch("/sd", editor(queryID(db.ResourceSurveyMeasurement)), s.SoftDeleteSurveyMeasurement)
+	survey.Patch("/restore", editor(queryID(db.ResourceSurveyMeasurement)), s.RestoreSurveyMeasurement)
+
+	job := v1.Group("/job")
+	job.Post("/", editor(bodyID(db.ResourceFloor, "floorId")), s.CreateJob)
+	job.Get("/", viewer(queryID(db.ResourceJob)), s.GetJob)
+	job.Get("/all", viewer(queryID(db.ResourceFloor)), s.GetJobs)
+	job.Patch("/cancel", editor(queryID(db.ResourceJob)), s.CancelJob)
+
+	v1.Post("/observation", s.CreateObservations)
+	v1.Get("/position", s.GetPosition)
+
+	cf := v1.Group("/centrifugo")
+	cf.Get("/token", s.GetCentrifugoToken)
+	cf.Get("/subscription", s.GetCentrifugoSubscriptionToken)
 
 }
 
@@ -1 +1,178 @@
 package server
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/db"
+)
+
+// CreateSensor creates a sensor
+func (s *Fiber) CreateSensor(c *fiber.Ctx) (err error) {
+	sensor := new(db.Sensor)
+	err = c.BodyParser(sensor)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		return err
+	}
+	if err = validateDiagram(sensor.Diagram); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	if sensor.SensorTypeID != nil {
+		if err = s.requireSameSite(c, db.ResourceSensorType, *sensor.SensorTypeID); err != nil {
+			return
+		}
+		st, err := s.db.GetSensorType(*sensor.SensorTypeID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to get sensor type")
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid sensor type")
+		}
+		// The body is decoded again with pointer fields to tell a zero value from an absent one
+		var given db.SensorPatch
+		if err = c.BodyParser(&given); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
+		}
+		inheritSensorType(sensor, &given, st)
+	}
+
+	sensorID, err := s.db.CreateSensor(sensor)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"id": sensorID,
+	})
+}
+
+// inheritSensorType fills the antenna values absent from the body of the sensor with the sensor type defaults,
+// given is the body decoded with pointer fields
+func inheritSensorType(sensor *db.Sensor, given *db.SensorPatch, st *db.SensorType) {
+	if given.RxAntGain == nil && st.RxAntGain != nil {
+		sensor.RxAntGain = *st.RxAntGain
+	}
+	if given.CorrectionFactor24 == nil && st.CorrectionFactor24 != nil {
+		sensor.CorrectionFactor24 = *st.CorrectionFactor24
+	}
+	if given.CorrectionFactor5 == nil && st.CorrectionFactor5 != nil {
+		sensor.CorrectionFactor5 = *st.CorrectionFactor5
+	}
+	if given.CorrectionFactor6 == nil && st.CorrectionFactor6 != nil {
+		sensor.CorrectionFactor6 = *st.CorrectionFactor6
+	}
+	if len(sensor.Diagram) == 0 && len(st.Diagram) > 0 {
+		sensor.Diagram = st.Diagram
+	}
+}
+
+// GetSensor retrieves a sensor
+func (s *Fiber) GetSensor(c *fiber.Ctx) (err error) {
+	sensorID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse sensor uuid")
+		return
+	}
+	sensor, err := s.db.GetSensor(sensorID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get sensor")
+		return
+	}
+	return c.JSON(fiber.Map{
+		"data": sensor,
+	})
+}
+
+// GetSensors retrieves sensors
+func (s *Fiber) GetSensors(c *fiber.Ctx) (err error) {
+	floorUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse floor uuid")
+		return
+	}
+	sensors, err := s.db.GetSensors(floorUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get sensors")
+		return
+	}
+	return c.JSON(fiber.Map{
+		"data": sensors,
+	})
+}
+
+// SoftDeleteSensor soft delete a sensor
+func (s *Fiber) SoftDeleteSensor(c *fiber.Ctx) (err error) {
+	sensorID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse sensor uuid")
+		return
+	}
+	isDeleted, err := s.db.IsSensorSoftDeleted(sensorID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get soft deleted sensor")
+		return
+	}
+	if !isDeleted {
+		err = s.db.SoftDeleteSensor(sensorID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to soft delete a sensor")
+			return
+		}
+	} else {
+		return c.Status(fiber.StatusBadRequest).SendString("Sensor has already been soft deleted")
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// RestoreSensor restore a sensor
+func (s *Fiber) RestoreSensor(c *fiber.Ctx) (err error) {
+	sensorID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse sensor uuid")
+		return
+	}
+	isDeleted, err := s.db.IsSensorSoftDeleted(sensorID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get soft deleted sensor")
+		return
+	}
+	if isDeleted {
+		err = s.db.RestoreSensor(sensorID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to restore a sensor")
+			return
+		}
+	} else {
+		return c.Status(fiber.StatusBadRequest).SendString("Sensor has not been soft deleted")
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// PatchUpdateSensor patch updates a sensor based on provided fields
+func (s *Fiber) PatchUpdateSensor(c *fiber.Ctx) error {
+	var sensor db.SensorPatch
+	if err := c.BodyParser(&sensor); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
+	}
+	if err := validateDiagram(sensor.Diagram); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	if sensor.SensorTypeID != nil {
+		if err := s.requireSameSite(c, db.ResourceSensorType, *sensor.SensorTypeID); err != nil {
+			return err
+		}
+	}
+
+	err := s.db.PatchUpdateSensor(&sensor)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).SendString("Sensor not found")
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to update sensor")
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update sensor")
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
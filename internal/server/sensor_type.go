@@ -1 +1,183 @@
 package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/db"
+	"location-backend/internal/location"
+)
+
+// CreateSensorType creates a sensor type
+func (s *Fiber) CreateSensorType(c *fiber.Ctx) (err error) {
+	st := new(db.SensorType)
+	err = c.BodyParser(st)
+	if err != nil {
+		return err
+	}
+	if err = validateDiagram(st.Diagram); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	sensorTypeID, err := s.db.CreateSensorType(st)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"id": sensorTypeID,
+	})
+}
+
+// GetSensorType retrieves a sensor type
+func (s *Fiber) GetSensorType(c *fiber.Ctx) (err error) {
+	sensorTypeID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse sensor type uuid")
+		return
+	}
+	st, err := s.db.GetSensorType(sensorTypeID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get sensor type")
+		return
+	}
+	return c.JSON(fiber.Map{
+		"data": st,
+	})
+}
+
+// GetSensorTypes retrieves sensor types
+func (s *Fiber) GetSensorTypes(c *fiber.Ctx) (err error) {
+	siteUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse site uuid")
+		return
+	}
+	st, err := s.db.GetSensorTypes(siteUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get sensor type")
+		return
+	}
+	return c.JSON(fiber.Map{
+		"data": st,
+	})
+}
+
+// SoftDeleteSensorType soft delete a sensor type
+func (s *Fiber) SoftDeleteSensorType(c *fiber.Ctx) (err error) {
+	sensorTypeID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse sensor type uuid")
+		return
+	}
+	isDeleted, err := s.db.IsSensorTypeSoftDeleted(sensorTypeID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get soft deleted sensor type")
+		return
+	}
+	if !isDeleted {
+		err = s.db.SoftDeleteSensorType(sensorTypeID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to soft delete a sensor type")
+			return
+		}
+	} else {
+		return c.Status(fiber.StatusBadRequest).SendString("Sensor type has already been soft deleted")
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// RestoreSensorType restore a sensor type
+func (s *Fiber) RestoreSensorType(c *fiber.Ctx) (err error) {
+	sensorTypeID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse sensor type uuid")
+		return
+	}
+	isDeleted, err := s.db.IsSensorTypeSoftDeleted(sensorTypeID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get soft deleted sensor type")
+		return
+	}
+	if isDeleted {
+		err = s.db.RestoreSensorType(sensorTypeID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to restore a sensor type")
+			return
+		}
+	} else {
+		return c.Status(fiber.StatusBadRequest).SendString("Sensor type has not been soft deleted")
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// PatchUpdateSensorType patch updates a sensor type based on provided fields
+func (s *Fiber) PatchUpdateSensorType(c *fiber.Ctx) error {
+	var input db.SensorType
+	if err := c.BodyParser(&input); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
+	}
+	if err := validateDiagram(input.Diagram); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	log.Debug().Msgf("Updating sensor type: %v", input)
+	if err := s.db.PatchUpdateSensorType(&input); err != nil {
+		log.Error().Err(err).Msg("Failed to update sensor type")
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update sensor type")
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// UploadSensorTypeDiagram replaces the radiation diagram of a sensor type with a pattern file, see diagramFromFile
+func (s *Fiber) UploadSensorTypeDiagram(c *fiber.Ctx) (err error) {
+	sensorTypeID, err := uuid.Parse(c.FormValue("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse sensor type uuid")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid sensor type UUID")
+	}
+	diagram, err := diagramFromFile(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	if err = s.db.PatchUpdateSensorType(&db.SensorType{ID: sensorTypeID, Diagram: diagram}); err != nil {
+		log.Error().Err(err).Msg("Failed to update sensor type diagram")
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update sensor type")
+	}
+	return c.JSON(fiber.Map{
+		"data": diagram,
+	})
+}
+
+// validateDiagram checks that the radiation diagram is empty or a valid antenna pattern
+func validateDiagram(raw json.RawMessage) error {
+	_, err := location.ParseAntennaPattern(raw)
+	return err
+}
+
+// diagramFromFile parses the antenna pattern uploaded as the "file" form field in the Planet format (.msi, .ant)
+// into a valid radiation diagram
+func diagramFromFile(c *fiber.Ctx) (json.RawMessage, error) {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return nil, errors.New("pattern file is required")
+	}
+	file, err := fh.Open()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to open file")
+		return nil, err
+	}
+	defer file.Close()
+
+	d, err := location.ParsePlanetPattern(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern file %s: %w", fh.Filename, err)
+	}
+	if _, err = location.NewAntennaPattern(d); err != nil {
+		return nil, fmt.Errorf("invalid pattern file %s: %w", fh.Filename, err)
+	}
+	return json.Marshal(d)
+}
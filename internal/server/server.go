@@ -2,19 +2,41 @@ package server
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"location-backend/internal/centrifugo"
 	"location-backend/internal/db"
+	"location-backend/internal/location"
+	"sync"
 )
 
 type Fiber struct {
-	App *fiber.App
-	db  db.Service
+	App        *fiber.App
+	db         db.Service
+	centrifugo *centrifugo.Client
+
+	tracker        *location.Tracker
+	fingerprintsMu sync.Mutex
+	fingerprints   map[uuid.UUID]*location.Fingerprints // Positioning matrices by floor
+
+	positionsMu      sync.Mutex
+	positionsPending map[string]bool // Clients whose positions are to be published, see queuePositions
+	positionsReady   chan struct{}
+
+	jobWorkers sync.WaitGroup // Running job workers, see StartJobWorkers
 }
 
-func New(db db.Service) *Fiber {
+func New(db db.Service, centrifugo *centrifugo.Client) *Fiber {
 	server := &Fiber{
-		App: fiber.New(),
-		db:  db,
+		App:          fiber.New(),
+		db:           db,
+		centrifugo:   centrifugo,
+		tracker:      location.NewTracker(),
+		fingerprints: make(map[uuid.UUID]*location.Fingerprints),
+
+		positionsPending: make(map[string]bool),
+		positionsReady:   make(chan struct{}, 1),
 	}
+	go server.runPositionPublisher()
 
 	return server
 }
@@ -22,6 +22,9 @@ func (s *Fiber) CreateSite(c *fiber.Ctx) (err error) {
 	if err != nil {
 		return err
 	}
+	if err = validatePropagationModel(siteInput.PropagationModel); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
 
 	siteID, err := s.db.CreateSite(userUUID, siteInput)
 	if err != nil {
@@ -79,28 +82,32 @@ func (s *Fiber) GetSitesDetailed(c *fiber.Ctx) (err error) {
 	}
 	sites, err := s.db.GetSites(userUUID)
 	for _, site := range sites {
-		log.Debug().Msgf("Site: %s", site)
+		log.Debug().Msgf("Site: %v", site)
 		buildings, err := s.db.GetBuildings(site.ID)
 		if err != nil {
 			continue
 		}
 
 		for _, building := range buildings {
-			log.Debug().Msgf("Building: %s", building)
+			log.Debug().Msgf("Building: %v", building)
 			floors, err := s.db.GetFloors(building.ID)
 			if err != nil {
 				continue
 			}
 
 			for _, floor := range floors {
-				log.Debug().Msgf("Floor: %s", floor)
+				log.Debug().Msgf("Floor: %v", floor)
 				aps, err := s.db.GetAccessPointsDetailed(floor.ID)
 				if err != nil {
 					continue
 				}
 				walls, err := s.db.GetWallsDetailed(floor.ID)
+				sensors, err := s.db.GetSensors(floor.ID)
+				obstacles, err := s.db.GetObstacles(floor.ID)
 				floor.AccessPoints = aps
 				floor.Walls = walls
+				floor.Sensors = sensors
+				floor.Obstacles = obstacles
 			}
 			building.Floors = floors
 		}
@@ -118,6 +125,12 @@ func (s *Fiber) GetSitesDetailed(c *fiber.Ctx) (err error) {
 		}
 		site.AccessPointTypes = accessPointTypes
 
+		sensorTypes, err := s.db.GetSensorTypes(site.ID)
+		if err != nil {
+			continue
+		}
+		site.SensorTypes = sensorTypes
+
 	}
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get sites")
@@ -183,6 +196,9 @@ func (s *Fiber) PatchUpdateSite(c *fiber.Ctx) error {
 		log.Error().Err(err).Msg("Failed to parse request body")
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
 	}
+	if err := validatePropagationModel(input.PropagationModel); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
 
 	if err := s.db.PatchUpdateSite(&input); err != nil {
 		log.Error().Err(err).Msg("Failed to update site")
@@ -0,0 +1,340 @@
+package server
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"location-backend/internal/db"
+	"location-backend/internal/location"
+	. "math"
+	"time"
+)
+
+// SurveyMeasurementInput is a walk-test measurement, coordinates are image pixels of the floor plan
+type SurveyMeasurementInput struct {
+	X             float64    `json:"x"`
+	Y             float64    `json:"y"`
+	Band          string     `json:"band"`
+	RSSI          float64    `json:"rssi"`
+	MeasuredAt    time.Time  `json:"measuredAt"`
+	SensorID      *uuid.UUID `json:"sensorId"`
+	AccessPointID *uuid.UUID `json:"accessPointId"`
+}
+
+// SurveyInput is a walk test of a floor
+type SurveyInput struct {
+	FloorID      uuid.UUID                `json:"floorId"`
+	Measurements []SurveyMeasurementInput `json:"measurements"`
+}
+
+// CreateSurveyMeasurements stores the measurements of a walk test, each one is of either a sensor or an access point
+func (s *Fiber) CreateSurveyMeasurements(c *fiber.Ctx) (err error) {
+	var input SurveyInput
+	if err = c.BodyParser(&input); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
+	}
+
+	ms := make([]*db.SurveyMeasurement, 0, len(input.Measurements))
+	checked := make(map[uuid.UUID]bool)
+	for _, in := range input.Measurements {
+		band, err := location.ParseBand(in.Band)
+		if err != nil || (in.SensorID == nil) == (in.AccessPointID == nil) {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid measurement")
+		}
+		// Measured emitters must be of the surveyed site
+		if in.SensorID != nil && !checked[*in.SensorID] {
+			if err = s.requireSameSite(c, db.ResourceSensor, *in.SensorID); err != nil {
+				return err
+			}
+			checked[*in.SensorID] = true
+		}
+		if in.AccessPointID != nil && !checked[*in.AccessPointID] {
+			if err = s.requireSameSite(c, db.ResourceAccessPoint, *in.AccessPointID); err != nil {
+				return err
+			}
+			checked[*in.AccessPointID] = true
+		}
+		ms = append(ms, &db.SurveyMeasurement{
+			X:             in.X,
+			Y:             in.Y,
+			Band:          int(band),
+			RSSI:          in.RSSI,
+			MeasuredAt:    in.MeasuredAt,
+			SensorID:      in.SensorID,
+			AccessPointID: in.AccessPointID,
+			FloorID:       input.FloorID,
+		})
+	}
+
+	count, err := s.db.CreateSurveyMeasurements(ms)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Failed to create survey measurements")
+	}
+	return c.JSON(fiber.Map{
+		"accepted": count,
+	})
+}
+
+// GetSurveyMeasurements retrieves the survey measurements of a floor
+func (s *Fiber) GetSurveyMeasurements(c *fiber.Ctx) (err error) {
+	floorUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse floor uuid")
+		return
+	}
+	ms, err := s.db.GetSurveyMeasurements(floorUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get survey measurements")
+		return
+	}
+	return c.JSON(fiber.Map{
+		"data": ms,
+	})
+}
+
+// SoftDeleteSurveyMeasurement soft delete a survey measurement
+func (s *Fiber) SoftDeleteSurveyMeasurement(c *fiber.Ctx) (err error) {
+	measurementID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse survey measurement uuid")
+		return
+	}
+	isDeleted, err := s.db.IsSurveyMeasurementSoftDeleted(measurementID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get soft deleted survey measurement")
+		return
+	}
+	if !isDeleted {
+		err = s.db.SoftDeleteSurveyMeasurement(measurementID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to soft delete a survey measurement")
+			return
+		}
+	} else {
+		return c.Status(fiber.StatusBadRequest).SendString("Survey measurement has already been soft deleted")
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// RestoreSurveyMeasurement restore a survey measurement
+func (s *Fiber) RestoreSurveyMeasurement(c *fiber.Ctx) (err error) {
+	measurementID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse survey measurement uuid")
+		return
+	}
+	isDeleted, err := s.db.IsSurveyMeasurementSoftDeleted(measurementID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get soft deleted survey measurement")
+		return
+	}
+	if isDeleted {
+		err = s.db.RestoreSurveyMeasurement(measurementID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to restore a survey measurement")
+			return
+		}
+	} else {
+		return c.Status(fiber.StatusBadRequest).SendString("Survey measurement has not been soft deleted")
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// SurveyCalibration is the result of fitting the floor predictions to its survey
+type SurveyCalibration struct {
+	Samples int                  `json:"samples"`
+	Skipped int                  `json:"skipped"` // Measurements without a visible prediction
+	Fits    []location.SurveyFit `json:"fits"`
+	Applied bool                 `json:"applied"`
+}
+
+// CalibrateSurvey fits the correction offsets of every sensor and access point and the path-loss exponent
+// of every band of the floor requested by id to its survey measurements, see calibrateSurvey.
+// With apply=true the offsets are added to the correction factors of the sensors and access points and the exponents
+// are stored on the floor, see applySurveyFits. A floor whose matrices are missing or stale is answered Accepted
+// with the ids of the queued matrix jobs, see sendMatrixPending.
+func (s *Fiber) CalibrateSurvey(c *fiber.Ctx) (err error) {
+	floorUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse floor uuid")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid floor UUID")
+	}
+	result, err := s.calibrateSurvey(floorUUID, c.QueryBool("apply", false))
+	if err != nil {
+		return sendMatrixPending(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"data": result,
+	})
+}
+
+// calibrateSurvey fits the predictions of the floor to its survey measurements, see location.FitSurvey.
+// Sensor measurements are compared with the positioning matrix and access point measurements with the coverage matrix.
+// With apply the fits are stored, see applySurveyFits. Both matrices must be current, the stale or missing ones
+// are queued and a *matrixPendingError is returned, see ensureMatrix.
+func (s *Fiber) calibrateSurvey(floorUUID uuid.UUID, apply bool) (result *SurveyCalibration, err error) {
+	ms, err := s.db.GetSurveyMeasurements(floorUUID)
+	if err != nil {
+		return
+	}
+	if len(ms) == 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Floor has no survey measurements")
+	}
+	f, err := s.getFloorGeometry(floorUUID)
+	if err != nil {
+		return
+	}
+
+	predictions := make(map[predictionKey]*db.MatrixRow)
+	var model location.PropagationModel
+	var cal location.Calibration
+	pending := &matrixPendingError{}
+	for _, kind := range []string{db.MatrixKindSensors, db.MatrixKindCoverage} {
+		inputData, err := s.floorInputData(f, kind, location.CELL_SIZE_METER)
+		if err != nil {
+			return nil, err
+		}
+		// Measurements are fitted against the current geometry only
+		m, rows, err := s.getFloorMatrix(floorUUID, kind, inputData, true)
+		var kindPending *matrixPendingError
+		if errors.As(err, &kindPending) {
+			pending.JobIDs = append(pending.JobIDs, kindPending.JobIDs...)
+			continue
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to get %s matrix", kind)
+			return nil, err
+		}
+		for _, r := range rows {
+			predictions[predictionKey{x: int(Round(r.X / m.CellSize)), y: int(Round(r.Y / m.CellSize)), id: r.SensorID}] = r
+		}
+		model, cal = inputData.Model(), inputData.Calibration()
+	}
+	if len(pending.JobIDs) > 0 {
+		return nil, pending
+	}
+
+	result = &SurveyCalibration{}
+	var samples []location.SurveySample
+	// Pixels to cells of the matrices
+	k := 1 / (*f.Scale * location.CELL_SIZE_METER)
+	for _, meas := range ms {
+		id := meas.AccessPointID
+		if meas.SensorID != nil {
+			id = meas.SensorID
+		}
+		row, ok := predictions[predictionKey{x: int(Round(meas.X * k)), y: int(Round(meas.Y * k)), id: *id}]
+		if !ok {
+			result.Skipped++
+			continue
+		}
+		band := location.Band(meas.Band)
+		predicted := location.MatrixRowRSSI(row, band)
+		if predicted < cal.RSSICutoff {
+			result.Skipped++
+			continue
+		}
+		samples = append(samples, location.SurveySample{
+			EmitterID: *id,
+			Band:      band,
+			Measured:  meas.RSSI,
+			Predicted: predicted,
+			Distance:  row.Distance,
+		})
+	}
+	result.Samples = len(samples)
+	result.Fits = location.FitSurvey(samples, model)
+
+	if apply && len(result.Fits) > 0 {
+		if err = s.applySurveyFits(f, model, result.Fits); err != nil {
+			log.Error().Err(err).Msg("Failed to apply survey calibration")
+			return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to apply survey calibration")
+		}
+		result.Applied = true
+	}
+	return
+}
+
+// predictionKey identifies the matrix row of an emitter in a cell
+type predictionKey struct {
+	x, y int
+	id   uuid.UUID
+}
+
+// applySurveyFits stores the fits in one transaction: the offsets are added to the correction factors of the sensors
+// and access points of the floor and the fitted exponents replace those of the model of the floor when it has
+// adjustable ones, see location.FloorModel
+func (s *Fiber) applySurveyFits(f *db.Floor, model location.PropagationModel, fits []location.SurveyFit) error {
+	var sensors []*db.SensorPatch
+	for _, sensor := range f.Sensors {
+		patch := &db.SensorPatch{ID: sensor.ID}
+		factors := map[location.Band]**float64{
+			location.Band24: &patch.CorrectionFactor24,
+			location.Band5:  &patch.CorrectionFactor5,
+			location.Band6:  &patch.CorrectionFactor6,
+		}
+		current := map[location.Band]float64{
+			location.Band24: sensor.CorrectionFactor24,
+			location.Band5:  sensor.CorrectionFactor5,
+			location.Band6:  sensor.CorrectionFactor6,
+		}
+		if addSurveyOffsets(sensor.ID, fits, factors, current) {
+			sensors = append(sensors, patch)
+		}
+	}
+	var accessPoints []*db.AccessPoint
+	for _, ap := range f.AccessPoints {
+		patch := &db.AccessPoint{ID: ap.ID}
+		factors := map[location.Band]**float64{
+			location.Band24: &patch.CorrectionFactor24,
+			location.Band5:  &patch.CorrectionFactor5,
+			location.Band6:  &patch.CorrectionFactor6,
+		}
+		current := make(map[location.Band]float64)
+		for band, factor := range map[location.Band]*float64{
+			location.Band24: ap.CorrectionFactor24,
+			location.Band5:  ap.CorrectionFactor5,
+			location.Band6:  ap.CorrectionFactor6,
+		} {
+			if factor != nil {
+				current[band] = *factor
+			}
+		}
+		if addSurveyOffsets(ap.ID, fits, factors, current) {
+			accessPoints = append(accessPoints, patch)
+		}
+	}
+
+	floor := &db.Floor{ID: f.ID}
+	exponents := map[location.Band]**float64{
+		location.Band24: &floor.PathLossExponent24,
+		location.Band5:  &floor.PathLossExponent5,
+		location.Band6:  &floor.PathLossExponent6,
+	}
+	for _, fit := range fits {
+		if fit.Exponent == nil || fit.ExponentDelta == 0 {
+			continue
+		}
+		if _, ok := location.WithExponent(model, fit.Band, *fit.Exponent); ok {
+			*exponents[fit.Band] = fit.Exponent
+		}
+	}
+	return s.db.ApplySurveyCalibration(floor, sensors, accessPoints)
+}
+
+// addSurveyOffsets sets the factors of the emitter to the current ones plus the offsets fitted in every band,
+// it tells whether an offset was set
+func addSurveyOffsets(id uuid.UUID, fits []location.SurveyFit, factors map[location.Band]**float64, current map[location.Band]float64) (changed bool) {
+	for _, fit := range fits {
+		offset, ok := fit.Offsets[id]
+		if !ok || offset == 0 {
+			continue
+		}
+		factor := current[fit.Band] + offset
+		*factors[fit.Band] = &factor
+		changed = true
+	}
+	return
+}
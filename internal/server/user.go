@@ -1,14 +1,32 @@
 package server
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 	"location-backend/internal/config"
+	"location-backend/internal/db"
 	"time"
 )
 
+const (
+	accessTokenTTL     = 15 * time.Minute
+	refreshTokenTTL    = 30 * 24 * time.Hour
+	refreshTokenCookie = "refresh_token"
+)
+
+type RefreshInput struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
 type LoginInput struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -34,23 +52,7 @@ func (s *Fiber) Login(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusUnauthorized)
 	}
 
-	// Create the Claims
-	claims := jwt.MapClaims{
-		"id":       user.ID,
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 72).Unix(),
-	}
-
-	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Generate encoded token and send it as response.
-	t, err := token.SignedString([]byte(config.App.JWTSecret))
-	if err != nil {
-		return c.SendStatus(fiber.StatusInternalServerError)
-	}
-
-	return c.JSON(fiber.Map{"token": t})
+	return s.issueTokens(c, user.ID, user.Username, uuid.Nil)
 }
 
 func (s *Fiber) HashPassword(password string) (string, error) {
@@ -81,3 +83,201 @@ func (s *Fiber) Register(c *fiber.Ctx) error {
 
 	return c.JSON(fiber.Map{"id": userID})
 }
+
+// issueTokens signs an access token and creates a refresh token of the family, a new family is started if familyID is nil
+func (s *Fiber) issueTokens(c *fiber.Ctx, userID uuid.UUID, username string, familyID uuid.UUID) error {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	expiry := time.Now().Add(refreshTokenTTL)
+	rt := &db.RefreshToken{
+		Token:    hashToken(refreshToken),
+		Expiry:   &expiry,
+		UserID:   userID,
+		FamilyID: familyID,
+		Device:   c.Get(fiber.HeaderUserAgent),
+		IP:       c.IP(),
+	}
+	if _, err = s.db.CreateRefreshToken(rt); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	return s.sendTokens(c, userID, username, rt.FamilyID, refreshToken, expiry)
+}
+
+// sendTokens signs an access token of the session and sends it with the refresh token
+func (s *Fiber) sendTokens(c *fiber.Ctx, userID uuid.UUID, username string, familyID uuid.UUID, refreshToken string, expiry time.Time) error {
+	// Create the Claims
+	claims := jwt.MapClaims{
+		"id":       userID,
+		"username": username,
+		"sid":      familyID,
+		"exp":      time.Now().Add(accessTokenTTL).Unix(),
+	}
+
+	// Create token
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	// Generate encoded token and send it as response.
+	t, err := token.SignedString([]byte(config.App.JWTSecret))
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    refreshToken,
+		Path:     "/api/v1/user",
+		Expires:  expiry,
+		Secure:   config.App.IsProduction,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+	return c.JSON(fiber.Map{"token": t, "refreshToken": refreshToken})
+}
+
+// newRefreshToken generates a random refresh token
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hash a refresh token is stored by
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestRefreshToken returns the refresh token of the body or of the cookie
+func requestRefreshToken(c *fiber.Ctx) string {
+	input := new(RefreshInput)
+	if err := c.BodyParser(input); err == nil && input.RefreshToken != "" {
+		return input.RefreshToken
+	}
+	return c.Cookies(refreshTokenCookie)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh token.
+// Presenting an already rotated token revokes the whole token family, as the token has leaked.
+func (s *Fiber) Refresh(c *fiber.Ctx) error {
+	refreshToken := requestRefreshToken(c)
+	if refreshToken == "" {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+	rt, err := s.db.GetRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+	if rt.RevokedAt != nil {
+		log.Warn().Msgf("Reuse of refresh token of family %v, revoking the family", rt.FamilyID)
+		s.db.RevokeRefreshTokenFamily(rt.FamilyID)
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+	if rt.Expiry == nil || rt.Expiry.Before(time.Now()) {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+	user, err := s.db.GetUserByID(rt.UserID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	nextToken, err := newRefreshToken()
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	expiry := time.Now().Add(refreshTokenTTL)
+	next := &db.RefreshToken{
+		Token:    hashToken(nextToken),
+		Expiry:   &expiry,
+		UserID:   rt.UserID,
+		FamilyID: rt.FamilyID,
+		Device:   c.Get(fiber.HeaderUserAgent),
+		IP:       c.IP(),
+	}
+	if _, err = s.db.RotateRefreshToken(rt.ID, next); err != nil {
+		if errors.Is(err, db.ErrRefreshTokenReused) {
+			log.Warn().Msgf("Concurrent reuse of refresh token of family %v, revoking the family", rt.FamilyID)
+			s.db.RevokeRefreshTokenFamily(rt.FamilyID)
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	return s.sendTokens(c, user.ID, user.Username, rt.FamilyID, nextToken, expiry)
+}
+
+// Logout revokes the session of the refresh token
+func (s *Fiber) Logout(c *fiber.Ctx) error {
+	c.ClearCookie(refreshTokenCookie)
+	refreshToken := requestRefreshToken(c)
+	if refreshToken == "" {
+		return c.SendStatus(fiber.StatusOK)
+	}
+	rt, err := s.db.GetRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		return c.SendStatus(fiber.StatusOK)
+	}
+	if err = s.db.RevokeRefreshTokenFamily(rt.FamilyID); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// currentSessionID returns the session id of the request JWT
+func currentSessionID(c *fiber.Ctx) (sessionUUID uuid.UUID) {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return
+	}
+	sid, _ := claims["sid"].(string)
+	sessionUUID, _ = uuid.Parse(sid)
+	return
+}
+
+// GetSessions retrieves the active sessions of the current user
+func (s *Fiber) GetSessions(c *fiber.Ctx) (err error) {
+	userUUID, err := currentUserID(c)
+	if err != nil {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+	sessions, err := s.db.GetSessions(userUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get sessions")
+		return
+	}
+	current := currentSessionID(c)
+	for _, session := range sessions {
+		session.Current = session.ID == current
+	}
+	return c.JSON(fiber.Map{
+		"data": sessions,
+	})
+}
+
+// RevokeSession revokes a session of the current user, an unknown or revoked session is not found
+func (s *Fiber) RevokeSession(c *fiber.Ctx) (err error) {
+	userUUID, err := currentUserID(c)
+	if err != nil {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+	sessionUUID, err := uuid.Parse(c.Query("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse session uuid")
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid session uuid")
+	}
+	err = s.db.RevokeSession(userUUID, sessionUUID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).SendString("Session not found or already revoked")
+		}
+		log.Error().Err(err).Msg("Failed to revoke session")
+		return
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
@@ -14,11 +16,18 @@ func (s *Fiber) CreateWall(c *fiber.Ctx) (err error) {
 	if err != nil {
 		return err
 	}
+	if err = validateHeights(w.ZBottom, w.ZTop); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	if err = s.requireSameSite(c, db.ResourceWallType, w.WallTypeID); err != nil {
+		return
+	}
 
 	wallID, err := s.db.CreateWall(w)
 	if err != nil {
 		return err
 	}
+	s.publishWall(wallID, ActionCreated)
 	return c.JSON(fiber.Map{
 		"id": wallID,
 	})
@@ -71,11 +80,18 @@ func (s *Fiber) SoftDeleteWall(c *fiber.Ctx) (err error) {
 		return
 	}
 	if !isDeleted {
+		// Deleted walls are not retrieved, the wall is read for its floor before the delete
+		w, err := s.db.GetWall(wallID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to get wall")
+			return err
+		}
 		err = s.db.SoftDeleteWall(wallID)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to soft delete a wall")
-			return
+			return err
 		}
+		s.publishFloor(w.FloorID, EventWall, ActionDeleted, w.ID, w)
 	} else {
 		return c.Status(fiber.StatusBadRequest).SendString("Wall has already been soft deleted")
 	}
@@ -100,6 +116,7 @@ func (s *Fiber) RestoreWall(c *fiber.Ctx) (err error) {
 			log.Error().Err(err).Msg("Failed to restore a wall")
 			return
 		}
+		s.publishWall(wallID, ActionRestored)
 	} else {
 		return c.Status(fiber.StatusBadRequest).SendString("Wall has not been soft deleted")
 	}
@@ -113,11 +130,61 @@ func (s *Fiber) PatchUpdateWall(c *fiber.Ctx) error {
 		log.Error().Err(err).Msg("Failed to parse request body")
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid input")
 	}
+	input.ResetZTop = isExplicitNull(c.Body(), "zTop")
+	if input.ZBottom != nil || input.ZTop != nil || input.ResetZTop {
+		// The heights are validated merged with the stored ones
+		current, err := s.db.GetWall(input.ID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString("Wall not found")
+		}
+		if err = validateHeights(mergeHeights(current.ZBottom, current.ZTop, input.ZBottom, input.ZTop, input.ResetZTop)); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+	}
 
 	if err := s.db.PatchUpdateWall(&input); err != nil {
 		log.Error().Err(err).Msg("Failed to update wall")
 		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update wall")
 	}
+	s.publishWall(input.ID, ActionUpdated)
 
 	return c.SendStatus(fiber.StatusOK)
 }
+
+// validateHeights checks the heights of a wall or an obstacle, the top is above the floor and above the bottom when set
+func validateHeights(zBottom, zTop *float64) error {
+	if zBottom != nil && *zBottom < 0 {
+		return errors.New("zBottom must not be negative")
+	}
+	if zTop != nil && *zTop <= 0 {
+		return errors.New("zTop must be above the floor, null reaches the ceiling")
+	}
+	if zBottom != nil && zTop != nil && *zTop <= *zBottom {
+		return errors.New("zTop must be above zBottom")
+	}
+	return nil
+}
+
+// mergeHeights returns the heights of a wall or an obstacle after a patch, resetTop makes it reach the ceiling
+func mergeHeights(zBottom, zTop, patchBottom, patchTop *float64, resetTop bool) (*float64, *float64) {
+	if patchBottom != nil {
+		zBottom = patchBottom
+	}
+	if patchTop != nil {
+		zTop = patchTop
+	}
+	if resetTop {
+		zTop = nil
+	}
+	return zBottom, zTop
+}
+
+// isExplicitNull tells whether the JSON object sets the field to null, unlike leaving the field out
+func isExplicitNull(body []byte, field string) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return false
+	}
+	value, ok := fields[field]
+	return ok && string(value) == "null"
+}
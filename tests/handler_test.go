@@ -3,6 +3,7 @@ package tests
 import (
 	"github.com/gofiber/fiber/v2"
 	"io"
+	"location-backend/internal/server"
 	"net/http"
 	"testing"
 )
@@ -11,7 +12,7 @@ func TestHandler(t *testing.T) {
 	// Create a Fiber app for testing
 	app := fiber.New()
 	// Inject the Fiber app into the server
-	s := &server.FiberServer{App: app}
+	s := &server.Fiber{App: app}
 	// Define a route in the Fiber app
 	app.Get("/", s.HelloWorldHandler)
 	// Create a test HTTP request